@@ -2,46 +2,96 @@
 """
 Manual decryption script for aws-s3-backup encrypted files
 Usage: python3 decrypt_manual.py encrypted_file.enc password
+
+Supports password-encrypted files in the ENC2 format (scrypt or argon2id, parameters
+read from the header) and in the legacy v1 format (scrypt parameters are tried).
 """
 
 import sys
 import os
+import json
+import struct
+import base64
+from cryptography.hazmat.primitives import hashes
+from cryptography.hazmat.primitives.kdf.hkdf import HKDF
 from cryptography.hazmat.primitives.kdf.scrypt import Scrypt
 from cryptography.hazmat.primitives.ciphers.aead import AESGCM
 from cryptography.hazmat.backends import default_backend
 
+VERSIONED_MAGIC = b"ENC2"
+
 def decrypt_file(encrypted_file, password):
     """Decrypt a file encrypted by aws-s3-backup"""
-    
+
     # Read encrypted data
     with open(encrypted_file, 'rb') as f:
         data = f.read()
-    
+
     # Minimum size check
     if len(data) < 32 + 12 + 16:  # salt(32) + nonce(12) + tag(16)
         raise ValueError("File too short to be valid encrypted data")
-    
+
+    if data[:4] == VERSIONED_MAGIC:
+        return decrypt_versioned(data, password.encode())
+    return decrypt_legacy(data, password.encode())
+
+def decrypt_versioned(data, password):
+    """Decrypt the ENC2 format: magic | header length | JSON header | nonce | ciphertext"""
+
+    header_size = struct.unpack(">I", data[4:8])[0]
+    prefix = data[:8 + header_size]
+    header = json.loads(prefix[8:])
+    payload = data[len(prefix):]
+
+    for stanza in header["stanzas"]:
+        if stanza["type"] != "scrypt":
+            continue  # X25519 stanzas need the identity and this tool
+        kdf = stanza.get("kdf") or {"algorithm": "scrypt", "n": 131072, "r": 8, "p": 1}
+        salt = base64.b64decode(stanza["salt"])
+        wrap_key = derive_key(kdf, password, salt)
+        if "subkeySalt" in stanza:
+            # Per-file subkey of the master key of the backup run
+            wrap_key = HKDF(algorithm=hashes.SHA256(), length=32, salt=base64.b64decode(stanza["subkeySalt"]),
+                            info=b"aws-s3-backup password file key").derive(wrap_key)
+        try:
+            file_key = AESGCM(wrap_key).decrypt(bytes(12), base64.b64decode(stanza["wrappedKey"]), None)
+        except Exception:
+            continue
+        return AESGCM(file_key).decrypt(payload[:12], payload[12:], prefix)
+
+    raise ValueError("No password stanza matches the password")
+
+def derive_key(kdf, password, salt):
+    """Derive a key with the KDF parameters recorded in the header"""
+
+    if kdf["algorithm"] == "scrypt":
+        return Scrypt(salt=salt, length=32, n=kdf["n"], r=kdf["r"], p=kdf["p"], backend=default_backend()).derive(password)
+    if kdf["algorithm"] == "argon2id":
+        # Requires cryptography >= 44
+        from cryptography.hazmat.primitives.kdf.argon2 import Argon2id
+        return Argon2id(salt=salt, length=32, iterations=kdf["time"], lanes=kdf["threads"], memory_cost=kdf["memoryKiB"]).derive(password)
+    raise ValueError(f"Unsupported KDF {kdf['algorithm']}")
+
+def decrypt_legacy(data, password):
+    """Decrypt the v1 format, its scrypt parameters are not recorded"""
+
     # Extract salt (last 32 bytes) and ciphertext
     salt = data[-32:]
     ciphertext_with_nonce = data[:-32]
-    
-    # Try new parameters first (N=131072)
-    try:
-        return try_decrypt_with_params(ciphertext_with_nonce, password.encode(), salt, 131072)
-    except:
-        pass
-    
-    # Try legacy parameters (N=32768)
-    try:
-        return try_decrypt_with_params(ciphertext_with_nonce, password.encode(), salt, 32768)
-    except:
-        pass
-    
-    raise ValueError("Decryption failed with both parameter sets")
 
-def try_decrypt_with_params(ciphertext_with_nonce, password, salt, N):
+    # N=131072 (new) or 32768 (legacy), p depended on the CPU count (1-6)
+    for N in (131072, 32768):
+        for p in range(1, 7):
+            try:
+                return try_decrypt_with_params(ciphertext_with_nonce, password, salt, N, p)
+            except Exception:
+                pass
+
+    raise ValueError("Decryption failed with all parameter sets")
+
+def try_decrypt_with_params(ciphertext_with_nonce, password, salt, N, p):
     """Try decryption with specific scrypt parameters"""
-    
+
     # Derive key using scrypt
     kdf = Scrypt(
         algorithm=None,
@@ -49,47 +99,47 @@ def try_decrypt_with_params(ciphertext_with_nonce, password, salt, N):
         salt=salt,
         n=N,
         r=8,
-        p=1,  # Simplified for manual decryption
+        p=p,
         backend=default_backend()
     )
     key = kdf.derive(password)
-    
+
     # Extract nonce (first 12 bytes) and ciphertext
     nonce = ciphertext_with_nonce[:12]
     ciphertext = ciphertext_with_nonce[12:]
-    
+
     # Decrypt using AES-GCM
     aesgcm = AESGCM(key)
     plaintext = aesgcm.decrypt(nonce, ciphertext, None)
-    
+
     return plaintext
 
 def main():
     if len(sys.argv) != 3:
         print("Usage: python3 decrypt_manual.py encrypted_file.enc password")
         sys.exit(1)
-    
+
     encrypted_file = sys.argv[1]
     password = sys.argv[2]
-    
+
     if not os.path.exists(encrypted_file):
         print(f"Error: File {encrypted_file} not found")
         sys.exit(1)
-    
+
     try:
         # Decrypt the file
         decrypted_data = decrypt_file(encrypted_file, password)
-        
+
         # Write decrypted data
         output_file = encrypted_file.replace('.enc', '')
         with open(output_file, 'wb') as f:
             f.write(decrypted_data)
-        
+
         print(f"✅ Successfully decrypted: {output_file}")
-        
+
     except Exception as e:
         print(f"❌ Decryption failed: {e}")
         sys.exit(1)
 
 if __name__ == "__main__":
-    main()
\ No newline at end of file
+    main()
@@ -14,7 +14,7 @@ OUTPUT_FILE="${ENCRYPTED_FILE%.enc}"
 echo "⚠️  OpenSSL method requires manual implementation of scrypt key derivation"
 echo "This is complex - use the Python script instead: python3 decrypt_manual.py"
 echo ""
-echo "File format details:"
+echo "File format details (v1, files starting with ENC2 are described in README.md):"
 echo "- Algorithm: AES-256-GCM"
 echo "- Key derivation: scrypt (N=131072 or 32768, r=8, p=1-6)"
 echo "- Salt: Last 32 bytes of file"
@@ -28,4 +28,4 @@ echo "3. Extract ciphertext and tag from remaining data"
 echo "4. Derive key using scrypt with extracted salt"
 echo "5. Decrypt using AES-256-GCM with derived key and nonce"
 echo ""
-echo "💡 Recommendation: Use decrypt_manual.py instead for easier decryption"
\ No newline at end of file
+echo "💡 Recommendation: Use 'aws-s3-backup decrypt' or decrypt_manual.py instead for easier decryption"
\ No newline at end of file
@@ -1,11 +1,16 @@
 package config
 
 import (
-	"encoding/json"
+	"cmp"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
@@ -25,14 +30,66 @@ const (
 	DefaultRestoreExpiresAfterDays = 3
 	DefaultArchiveSplitMB          = 250
 	DefaultCleanupTmpStorage       = true
+	DefaultLogLevel                = "info"
+	DefaultLogFormat               = "plain"
+	DefaultSMTPPort                = 587
+	DefaultHookTimeoutMinutes      = 60
+	DefaultLockStaleMinutes        = 15
+)
+
+// Stream source types
+const (
+	StreamCommand = "command"
+	StreamStdin   = "stdin"
+)
+
+// Notification types and events
+const (
+	NotifyWebhook        = "webhook"
+	NotifySMTP           = "smtp"
+	NotifyOnSuccess      = "success"
+	NotifyOnFailure      = "failure"
+	NotifyOnWarning      = "warning"
+	NotifyOnRestoreReady = "restore-ready"
+)
+
+// Process exit codes
+const (
+	ExitSuccess        = 0
+	ExitFailure        = 1
+	ExitPartialFailure = 2
+	ExitCancelled      = 3
 )
 
 // File extensions
 const (
 	ArchiveExtension = "tar.gz"
+	StreamExtension  = "gz"
 	EncryptionExt    = "enc"
 )
 
+// Key derivation functions for EncryptionSecret
+const (
+	KDFScrypt   = "scrypt"
+	KDFArgon2id = "argon2id"
+)
+
+// S3 server-side encryption modes
+const (
+	SSEAES256   = "AES256"
+	SSEKMS      = "aws:kms"
+	SSECustomer = "SSE-C"
+)
+
+// S3 Object Lock retention modes, the date format of ObjectLockRetainUntil and the values of -legalHold
+const (
+	ObjectLockGovernance = "GOVERNANCE"
+	ObjectLockCompliance = "COMPLIANCE"
+	ObjectLockDateFormat = "2006-01-02"
+	LegalHoldOn          = "on"
+	LegalHoldOff         = "off"
+)
+
 // S3 lifecycle defaults
 const (
 	DefaultAbortIncompleteMultipartUploadDays = 2
@@ -53,24 +110,158 @@ type Config struct {
 	AutoRetryDownloadMinutes   int64
 	RestoreExpiresAfterDays    int64
 	DryRun                     bool
+	UploadLimitKBps            int64
+	DownloadLimitKBps          int64
+	TransferWindow             string
+	LogLevel                   string
+	LogFormat                  string
+	LogFile                    string
+	ReportFile                 string
+	MetricsFile                string
+	MetricsListen              string
+	StateFile                  string
+	PreCommand                 string
+	PostCommand                string
+	OnErrorCommand             string
+	HookTimeoutMinutes         int64
+	ForceUnlock                bool
+	LegalHold                  string
+	NoLock                     bool
+	LockStaleMinutes           int64
+	DecryptionSecret           string
+	IdentityFile               string
+	KeyringFile                string
+	NewEncryptionSecret        string
+	NewEncryptionKeyID         string
+	NewKDF                     string
+	SSECustomerKey             string
+	Endpoint                   string
+	PathStyle                  bool
+	CABundle                   string
+	NoRegionDiscovery          bool
+	StripPrefix                string
+	Flat                       bool
+	MappingFile                string
+	Notifications              []Notification
 }
 
-// Task represents a single backup task from JSON input
+// Task represents a single backup task from the input file
 type Task struct {
-	S3Bucket                  string   `json:"S3Bucket"`
-	S3Prefix                  string   `json:"S3Prefix"`
-	TrimBeginningOfPathInS3   string   `json:"TrimBeginningOfPathInS3"`
-	StorageClass              string   `json:"StorageClass"`
-	ArchiveSplitEachMB        string   `json:"ArchiveSplitEachMB"`
-	TmpStorageToBuildArchives string   `json:"TmpStorageToBuildArchives"`
-	CleanupTmpStorage         string   `json:"CleanupTmpStorage"`
-	EncryptionSecret          string   `json:"EncryptionSecret"`
-	Content                   []string `json:"Content"`
-}
-
-// Tasks wraps multiple Task objects for JSON parsing
+	S3Bucket                  string         `json:"S3Bucket" yaml:"S3Bucket" toml:"S3Bucket"`
+	S3Prefix                  string         `json:"S3Prefix" yaml:"S3Prefix" toml:"S3Prefix"`
+	TrimBeginningOfPathInS3   string         `json:"TrimBeginningOfPathInS3" yaml:"TrimBeginningOfPathInS3" toml:"TrimBeginningOfPathInS3"`
+	StorageClass              string         `json:"StorageClass" yaml:"StorageClass" toml:"StorageClass"`
+	ArchiveSplitEachMB        Int            `json:"ArchiveSplitEachMB,omitzero" yaml:"ArchiveSplitEachMB,omitempty" toml:"ArchiveSplitEachMB,omitempty"`
+	TmpStorageToBuildArchives string         `json:"TmpStorageToBuildArchives" yaml:"TmpStorageToBuildArchives" toml:"TmpStorageToBuildArchives"`
+	CleanupTmpStorage         Bool           `json:"CleanupTmpStorage,omitzero" yaml:"CleanupTmpStorage,omitempty" toml:"CleanupTmpStorage,omitempty"`
+	EncryptionSecret          string         `json:"EncryptionSecret" yaml:"EncryptionSecret" toml:"EncryptionSecret"`
+	EncryptionKeyID           string         `json:"EncryptionKeyID,omitempty" yaml:"EncryptionKeyID,omitempty" toml:"EncryptionKeyID,omitempty"`
+	KDF                       string         `json:"KDF,omitempty" yaml:"KDF,omitempty" toml:"KDF,omitempty"`
+	Recipients                []string       `json:"Recipients,omitempty" yaml:"Recipients,omitempty" toml:"Recipients,omitempty"`
+	ObfuscateObjectKeys       Bool           `json:"ObfuscateObjectKeys,omitzero" yaml:"ObfuscateObjectKeys,omitempty" toml:"ObfuscateObjectKeys,omitempty"`
+	ServerSideEncryption      string         `json:"ServerSideEncryption,omitempty" yaml:"ServerSideEncryption,omitempty" toml:"ServerSideEncryption,omitempty"`
+	SSEKMSKeyID               string         `json:"SSEKMSKeyID,omitempty" yaml:"SSEKMSKeyID,omitempty" toml:"SSEKMSKeyID,omitempty"`
+	SSEBucketKey              Bool           `json:"SSEBucketKey,omitzero" yaml:"SSEBucketKey,omitempty" toml:"SSEBucketKey,omitempty"`
+	SSECustomerKey            string         `json:"SSECustomerKey,omitempty" yaml:"SSECustomerKey,omitempty" toml:"SSECustomerKey,omitempty"`
+	ObjectLockMode            string         `json:"ObjectLockMode,omitempty" yaml:"ObjectLockMode,omitempty" toml:"ObjectLockMode,omitempty"`
+	ObjectLockRetentionDays   Int            `json:"ObjectLockRetentionDays,omitzero" yaml:"ObjectLockRetentionDays,omitempty" toml:"ObjectLockRetentionDays,omitempty"`
+	ObjectLockRetainUntil     string         `json:"ObjectLockRetainUntil,omitempty" yaml:"ObjectLockRetainUntil,omitempty" toml:"ObjectLockRetainUntil,omitempty"`
+	ObjectLockLegalHold       Bool           `json:"ObjectLockLegalHold,omitzero" yaml:"ObjectLockLegalHold,omitempty" toml:"ObjectLockLegalHold,omitempty"`
+	UploadLimitKBps           Int            `json:"UploadLimitKBps,omitzero" yaml:"UploadLimitKBps,omitempty" toml:"UploadLimitKBps,omitempty"`
+	TransferWindow            string         `json:"TransferWindow,omitempty" yaml:"TransferWindow,omitempty" toml:"TransferWindow,omitempty"`
+	Schedule                  string         `json:"Schedule,omitempty" yaml:"Schedule,omitempty" toml:"Schedule,omitempty"`
+	ScheduleJitterMinutes     Int            `json:"ScheduleJitterMinutes,omitzero" yaml:"ScheduleJitterMinutes,omitempty" toml:"ScheduleJitterMinutes,omitempty"`
+	PreCommand                string         `json:"PreCommand,omitempty" yaml:"PreCommand,omitempty" toml:"PreCommand,omitempty"`
+	PostCommand               string         `json:"PostCommand,omitempty" yaml:"PostCommand,omitempty" toml:"PostCommand,omitempty"`
+	OnErrorCommand            string         `json:"OnErrorCommand,omitempty" yaml:"OnErrorCommand,omitempty" toml:"OnErrorCommand,omitempty"`
+	HookTimeoutMinutes        Int            `json:"HookTimeoutMinutes,omitzero" yaml:"HookTimeoutMinutes,omitempty" toml:"HookTimeoutMinutes,omitempty"`
+	Content                   []string       `json:"Content" yaml:"Content" toml:"Content"`
+	Streams                   []StreamSource `json:"Streams,omitempty" yaml:"Streams,omitempty" toml:"Streams,omitempty"`
+	Destinations              []Destination  `json:"Destinations,omitempty" yaml:"Destinations,omitempty" toml:"Destinations,omitempty"`
+	Profile                   string         `json:"Profile,omitempty" yaml:"Profile,omitempty" toml:"Profile,omitempty"`
+	Region                    string         `json:"Region,omitempty" yaml:"Region,omitempty" toml:"Region,omitempty"`
+	RoleArn                   string         `json:"RoleArn,omitempty" yaml:"RoleArn,omitempty" toml:"RoleArn,omitempty"`
+	ExternalId                string         `json:"ExternalId,omitempty" yaml:"ExternalId,omitempty" toml:"ExternalId,omitempty"`
+	MFASerial                 string         `json:"MFASerial,omitempty" yaml:"MFASerial,omitempty" toml:"MFASerial,omitempty"`
+	MFAToken                  string         `json:"MFAToken,omitempty" yaml:"MFAToken,omitempty" toml:"MFAToken,omitempty"`
+}
+
+// Destination is an additional bucket the parts of a task are uploaded to, e.g. in another region or account.
+// Empty fields use the settings of the task.
+type Destination struct {
+	S3Bucket     string `json:"S3Bucket" yaml:"S3Bucket" toml:"S3Bucket"`
+	Region       string `json:"Region,omitempty" yaml:"Region,omitempty" toml:"Region,omitempty"`
+	StorageClass string `json:"StorageClass,omitempty" yaml:"StorageClass,omitempty" toml:"StorageClass,omitempty"`
+	Profile      string `json:"Profile,omitempty" yaml:"Profile,omitempty" toml:"Profile,omitempty"`
+	RoleArn      string `json:"RoleArn,omitempty" yaml:"RoleArn,omitempty" toml:"RoleArn,omitempty"`
+	ExternalId   string `json:"ExternalId,omitempty" yaml:"ExternalId,omitempty" toml:"ExternalId,omitempty"`
+	MFASerial    string `json:"MFASerial,omitempty" yaml:"MFASerial,omitempty" toml:"MFASerial,omitempty"`
+	MFAToken     string `json:"MFAToken,omitempty" yaml:"MFAToken,omitempty" toml:"MFAToken,omitempty"`
+}
+
+// Access holds the credentials settings of a task or destination, empty fields use the ones of the run.
+// RoleArn is assumed with the credentials of Profile, MFAToken is a secret reference (default: prompt).
+type Access struct {
+	Profile    string
+	Region     string
+	RoleArn    string
+	ExternalId string
+	MFASerial  string
+	MFAToken   string
+}
+
+// Access returns the credentials settings of the destination
+func (d Destination) Access() Access {
+	return Access{Profile: d.Profile, Region: d.Region, RoleArn: d.RoleArn, ExternalId: d.ExternalId, MFASerial: d.MFASerial, MFAToken: d.MFAToken}
+}
+
+// Validate checks that role settings are only used with a role to assume
+func (a Access) Validate() error {
+	if a.RoleArn != "" && !strings.HasPrefix(a.RoleArn, "arn:") {
+		return fmt.Errorf("RoleArn '%s' must be an ARN like arn:aws:iam::123456789012:role/backup", a.RoleArn)
+	}
+	if (a.ExternalId != "" || a.MFASerial != "") && a.RoleArn == "" {
+		return fmt.Errorf("ExternalId and MFASerial require RoleArn")
+	}
+	if a.MFAToken != "" && a.MFASerial == "" {
+		return fmt.Errorf("MFAToken requires MFASerial")
+	}
+	return nil
+}
+
+// StreamSource is a backup source read from the output of a command or from stdin
+type StreamSource struct {
+	Type       string `json:"Type" yaml:"Type" toml:"Type"`
+	Command    string `json:"Command,omitempty" yaml:"Command,omitempty" toml:"Command,omitempty"`
+	ObjectName string `json:"ObjectName" yaml:"ObjectName" toml:"ObjectName"`
+}
+
+// Notification configures a webhook or SMTP notifier from the input file
+type Notification struct {
+	Type         string            `json:"Type" yaml:"Type" toml:"Type"`
+	On           []string          `json:"On,omitempty" yaml:"On,omitempty" toml:"On,omitempty"`
+	URL          string            `json:"URL,omitempty" yaml:"URL,omitempty" toml:"URL,omitempty"`
+	Method       string            `json:"Method,omitempty" yaml:"Method,omitempty" toml:"Method,omitempty"`
+	Headers      map[string]string `json:"Headers,omitempty" yaml:"Headers,omitempty" toml:"Headers,omitempty"`
+	BodyTemplate string            `json:"BodyTemplate,omitempty" yaml:"BodyTemplate,omitempty" toml:"BodyTemplate,omitempty"`
+	SMTPHost     string            `json:"SMTPHost,omitempty" yaml:"SMTPHost,omitempty" toml:"SMTPHost,omitempty"`
+	SMTPPort     Int               `json:"SMTPPort,omitzero" yaml:"SMTPPort,omitempty" toml:"SMTPPort,omitempty"`
+	SMTPUsername string            `json:"SMTPUsername,omitempty" yaml:"SMTPUsername,omitempty" toml:"SMTPUsername,omitempty"`
+	SMTPPassword string            `json:"SMTPPassword,omitempty" yaml:"SMTPPassword,omitempty" toml:"SMTPPassword,omitempty"`
+	From         string            `json:"From,omitempty" yaml:"From,omitempty" toml:"From,omitempty"`
+	To           []string          `json:"To,omitempty" yaml:"To,omitempty" toml:"To,omitempty"`
+}
+
+// PathMapping restores the objects below an S3 prefix to a local directory instead of -destination
+type PathMapping struct {
+	Prefix string `json:"Prefix" yaml:"Prefix" toml:"Prefix"`
+	Target string `json:"Target" yaml:"Target" toml:"Target"`
+}
+
+// Tasks wraps multiple Task objects for parsing the input file
 type Tasks struct {
-	Tasks []Task `json:"tasks"`
+	Tasks         []Task         `json:"tasks" yaml:"tasks" toml:"tasks"`
+	Notifications []Notification `json:"notifications,omitempty" yaml:"notifications,omitempty" toml:"notifications,omitempty"`
 }
 
 // Validate checks if the configuration is valid
@@ -84,21 +275,78 @@ func (c *Config) Validate() error {
 	if err := c.validateRetrySettings(); err != nil {
 		return err
 	}
+	if err := c.validateBandwidthSettings(); err != nil {
+		return err
+	}
+	if err := c.validateHookSettings(); err != nil {
+		return err
+	}
+	if err := c.validateLockSettings(); err != nil {
+		return err
+	}
+	if err := c.validateEndpointSettings(); err != nil {
+		return err
+	}
 	return c.validateRestoreSettings()
 }
 
 // validateMode checks if the operation mode is valid
 func (c *Config) validateMode() error {
-	if c.Mode != "backup" && c.Mode != "restore" {
-		return fmt.Errorf("❌ invalid mode '%s', must be 'backup' or 'restore'", c.Mode)
+	if c.Mode != "backup" && c.Mode != "restore" && c.Mode != "daemon" && c.Mode != "validate" && c.Mode != "keygen" && c.Mode != "rekey" {
+		return fmt.Errorf("❌ invalid mode '%s', must be 'backup', 'restore', 'daemon', 'validate', 'keygen' or 'rekey'", c.Mode)
+	}
+	if c.Mode == "keygen" && c.IdentityFile == "" {
+		return fmt.Errorf("❌ identity parameter required for keygen mode")
+	}
+	if c.Mode == "rekey" && !c.ForceUnlock {
+		return c.validateRekeySettings()
+	}
+	return nil
+}
+
+// validateRekeySettings checks the parameters to re-encrypt a prefix under a new secret
+func (c *Config) validateRekeySettings() error {
+	if c.Bucket == "" {
+		return fmt.Errorf("❌ bucket parameter required for rekey mode (prefix is optional)")
+	}
+	if c.NewEncryptionSecret == "" {
+		return fmt.Errorf("❌ newEncryptionSecret parameter required for rekey mode")
+	}
+	if err := (Task{EncryptionSecret: c.NewEncryptionSecret, EncryptionKeyID: c.NewEncryptionKeyID, KDF: c.NewKDF}).Validate(); err != nil {
+		return fmt.Errorf("❌ invalid new key settings: %w", err)
 	}
 	return nil
 }
 
 // validateBackupRequirements checks backup-specific configuration
 func (c *Config) validateBackupRequirements() error {
-	if c.Mode == "backup" && c.InputFile == "" {
-		return fmt.Errorf("❌ json parameter required for backup mode")
+	if c.ForceUnlock {
+		return c.validateForceUnlock()
+	}
+	if c.LegalHold != "" {
+		return c.validateLegalHold()
+	}
+	if (c.Mode == "backup" || c.Mode == "daemon" || c.Mode == "validate") && c.InputFile == "" {
+		return fmt.Errorf("❌ json parameter required for %s mode", c.Mode)
+	}
+	return nil
+}
+
+// validateForceUnlock checks the parameters to remove a lock
+func (c *Config) validateForceUnlock() error {
+	if c.Bucket == "" {
+		return fmt.Errorf("❌ bucket parameter required for forceUnlock (prefix is optional)")
+	}
+	return nil
+}
+
+// validateLegalHold checks the parameters to set or clear the legal hold of the objects of a prefix
+func (c *Config) validateLegalHold() error {
+	if c.Bucket == "" {
+		return fmt.Errorf("❌ bucket parameter required for legalHold (prefix is optional)")
+	}
+	if c.LegalHold != LegalHoldOn && c.LegalHold != LegalHoldOff {
+		return fmt.Errorf("❌ invalid legalHold '%s', must be '%s' or '%s'", c.LegalHold, LegalHoldOn, LegalHoldOff)
 	}
 	return nil
 }
@@ -111,23 +359,68 @@ func (c *Config) validateRetrySettings() error {
 	return nil
 }
 
+// validateBandwidthSettings checks bandwidth limit configuration
+func (c *Config) validateBandwidthSettings() error {
+	if c.UploadLimitKBps < 0 || c.DownloadLimitKBps < 0 {
+		return fmt.Errorf("❌ uploadLimitKBps and downloadLimitKBps must not be negative")
+	}
+	return nil
+}
+
+// validateHookSettings checks hook configuration
+func (c *Config) validateHookSettings() error {
+	if c.HookTimeoutMinutes < 0 {
+		return fmt.Errorf("❌ hookTimeoutMinutes must not be negative")
+	}
+	return nil
+}
+
+// validateLockSettings checks repository lock configuration
+func (c *Config) validateLockSettings() error {
+	if c.LockStaleMinutes < 0 || c.LockStaleMinutes == 1 {
+		return fmt.Errorf("❌ lockStaleMinutes must be 2 or higher (0 = default of %d)", DefaultLockStaleMinutes)
+	}
+	return nil
+}
+
+// validateEndpointSettings checks the S3-compatible endpoint and its CA bundle
+func (c *Config) validateEndpointSettings() error {
+	if c.Endpoint != "" {
+		endpoint, err := url.Parse(c.Endpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return fmt.Errorf("❌ invalid endpoint '%s', use http(s)://host[:port]", c.Endpoint)
+		}
+	}
+	if c.CABundle != "" {
+		if _, err := os.Stat(c.CABundle); err != nil {
+			return fmt.Errorf("❌ caBundle file not found: %s", c.CABundle)
+		}
+	}
+	return nil
+}
+
 // validateRestoreSettings checks restore-specific configuration
 func (c *Config) validateRestoreSettings() error {
 	if c.RestoreExpiresAfterDays < 1 {
 		return fmt.Errorf("❌ restoreExpiresAfterDays must be 1 or higher")
 	}
+	if c.MappingFile != "" {
+		if _, err := os.Stat(c.MappingFile); err != nil {
+			return fmt.Errorf("❌ mapping file not found: %s", c.MappingFile)
+		}
+	}
 	return nil
 }
 
-// LoadTasks reads and parses tasks from a JSON file
+// LoadTasks reads, strictly decodes and validates the tasks of a JSON, YAML or TOML input file
 func LoadTasks(inputFile string) ([]Task, error) {
 	data, err := readInputFile(inputFile)
 	if err != nil {
 		return nil, err
 	}
 
-	tasks, err := parseTasksJSON(data)
-	if err != nil {
+	var tasks Tasks
+	if err := decodeInput(InputFormat(inputFile), data, &tasks, true); err != nil {
 		return nil, err
 	}
 
@@ -135,10 +428,280 @@ func LoadTasks(inputFile string) ([]Task, error) {
 		return nil, fmt.Errorf("❌ no tasks found in input file")
 	}
 
+	// All invalid tasks are reported at once
+	var errs []error
+	for i, task := range tasks.Tasks {
+		if err := task.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("❌ task %d: %w", i+1, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := validateStreams(tasks.Tasks); err != nil {
+		return nil, err
+	}
+
 	return tasks.Tasks, nil
 }
 
-// readInputFile reads the JSON input file
+// LoadNotifications reads and validates the notifiers of an input file (backup tasks or restore list)
+func LoadNotifications(inputFile string) ([]Notification, error) {
+	data, err := readInputFile(inputFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var input struct {
+		Notifications []Notification `json:"notifications" yaml:"notifications" toml:"notifications"`
+	}
+	if err := decodeInput(InputFormat(inputFile), data, &input, false); err != nil {
+		return nil, err
+	}
+
+	for i, notification := range input.Notifications {
+		if err := notification.Validate(); err != nil {
+			return nil, fmt.Errorf("❌ notification %d: %w", i+1, err)
+		}
+	}
+	return input.Notifications, nil
+}
+
+// LoadPathMappings reads and validates the restore mappings of a JSON, YAML or TOML file
+func LoadPathMappings(mappingFile string) ([]PathMapping, error) {
+	data, err := readInputFile(mappingFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var input struct {
+		Mappings []PathMapping `json:"mappings" yaml:"mappings" toml:"mappings"`
+	}
+	if err := decodeInput(InputFormat(mappingFile), data, &input, true); err != nil {
+		return nil, err
+	}
+
+	if len(input.Mappings) == 0 {
+		return nil, fmt.Errorf("❌ no mappings found in mapping file")
+	}
+	prefixes := make(map[string]bool)
+	for i, mapping := range input.Mappings {
+		prefix := strings.Trim(mapping.Prefix, "/")
+		if prefix == "" || mapping.Target == "" {
+			return nil, fmt.Errorf("❌ mapping %d: Prefix and Target are required", i+1)
+		}
+		if prefixes[prefix] {
+			return nil, fmt.Errorf("❌ mapping %d: Prefix '%s' is mapped more than once", i+1, mapping.Prefix)
+		}
+		prefixes[prefix] = true
+	}
+	return input.Mappings, nil
+}
+
+// Validate checks the value ranges of the task settings
+func (t Task) Validate() error {
+	if t.EncryptionSecret != "" && len(t.Recipients) > 0 {
+		return fmt.Errorf("EncryptionSecret and Recipients cannot be used together")
+	}
+	if t.EncryptionKeyID != "" && t.EncryptionSecret == "" {
+		return fmt.Errorf("EncryptionKeyID requires EncryptionSecret")
+	}
+	if strings.ContainsAny(t.EncryptionKeyID, " \t#") {
+		return fmt.Errorf("EncryptionKeyID must not contain spaces or '#'")
+	}
+	if t.KDF != "" && t.KDF != KDFScrypt && t.KDF != KDFArgon2id {
+		return fmt.Errorf("KDF must be '%s' or '%s'", KDFScrypt, KDFArgon2id)
+	}
+	if t.KDF != "" && t.EncryptionSecret == "" {
+		return fmt.Errorf("KDF requires EncryptionSecret")
+	}
+	if t.ObfuscateObjectKeys.Or(false) && t.EncryptionSecret == "" {
+		return fmt.Errorf("ObfuscateObjectKeys requires EncryptionSecret")
+	}
+	if err := t.validateServerSideEncryption(); err != nil {
+		return err
+	}
+	if err := t.validateObjectLock(); err != nil {
+		return err
+	}
+	if err := t.validateDestinations(); err != nil {
+		return err
+	}
+	if t.ArchiveSplitEachMB.IsSet() && t.ArchiveSplitEachMB.Or(0) <= 0 {
+		return fmt.Errorf("ArchiveSplitEachMB must be positive")
+	}
+	if t.UploadLimitKBps.Or(0) < 0 {
+		return fmt.Errorf("UploadLimitKBps must not be negative")
+	}
+	if t.ScheduleJitterMinutes.Or(0) < 0 {
+		return fmt.Errorf("ScheduleJitterMinutes must not be negative")
+	}
+	if t.HookTimeoutMinutes.Or(0) < 0 {
+		return fmt.Errorf("HookTimeoutMinutes must not be negative")
+	}
+	return nil
+}
+
+// validateServerSideEncryption checks that the S3 encryption settings fit the mode
+func (t Task) validateServerSideEncryption() error {
+	switch t.ServerSideEncryption {
+	case "", SSEAES256, SSEKMS, SSECustomer:
+	default:
+		return fmt.Errorf("ServerSideEncryption must be '%s', '%s' or '%s'", SSEAES256, SSEKMS, SSECustomer)
+	}
+	if (t.SSEKMSKeyID != "" || t.SSEBucketKey.IsSet()) && t.ServerSideEncryption != SSEKMS {
+		return fmt.Errorf("SSEKMSKeyID and SSEBucketKey require ServerSideEncryption '%s'", SSEKMS)
+	}
+	if (t.SSECustomerKey != "") != (t.ServerSideEncryption == SSECustomer) {
+		return fmt.Errorf("ServerSideEncryption '%s' requires SSECustomerKey and vice versa", SSECustomer)
+	}
+	return nil
+}
+
+// validateDestinations checks that every destination has its own bucket and valid credentials settings
+func (t Task) validateDestinations() error {
+	// Role settings are not combined with the role of the task, see UploadDestinations
+	for _, destination := range t.Destinations {
+		if (destination.ExternalId != "" || destination.MFASerial != "" || destination.MFAToken != "") && destination.RoleArn == "" {
+			return fmt.Errorf("ExternalId, MFASerial and MFAToken of a destination require its own RoleArn")
+		}
+	}
+
+	buckets := make(map[string]bool)
+	for i, destination := range t.UploadDestinations() {
+		if destination.S3Bucket == "" && i > 0 {
+			return fmt.Errorf("every entry of Destinations requires S3Bucket")
+		}
+		if buckets[destination.S3Bucket] {
+			return fmt.Errorf("bucket '%s' is used more than once in S3Bucket and Destinations", destination.S3Bucket)
+		}
+		buckets[destination.S3Bucket] = true
+		if err := destination.Access().Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UploadDestinations returns the bucket of the task followed by its additional destinations,
+// empty settings of a destination are filled in from the task. The credentials are one unit, a destination
+// with its own Profile or RoleArn inherits none of the credentials settings of the task.
+func (t Task) UploadDestinations() []Destination {
+	task := Destination{
+		S3Bucket: t.S3Bucket, Region: t.Region, StorageClass: t.StorageClass,
+		Profile: t.Profile, RoleArn: t.RoleArn, ExternalId: t.ExternalId, MFASerial: t.MFASerial, MFAToken: t.MFAToken,
+	}
+	destinations := []Destination{task}
+	for _, destination := range t.Destinations {
+		destination.Region = cmp.Or(destination.Region, task.Region)
+		destination.StorageClass = cmp.Or(destination.StorageClass, task.StorageClass)
+		if destination.Profile == "" && destination.RoleArn == "" {
+			destination.Profile, destination.RoleArn, destination.ExternalId = task.Profile, task.RoleArn, task.ExternalId
+			destination.MFASerial, destination.MFAToken = task.MFASerial, task.MFAToken
+		}
+		destinations = append(destinations, destination)
+	}
+	return destinations
+}
+
+// validateObjectLock checks that a retention mode has exactly one retention period
+func (t Task) validateObjectLock() error {
+	switch t.ObjectLockMode {
+	case "", ObjectLockGovernance, ObjectLockCompliance:
+	default:
+		return fmt.Errorf("ObjectLockMode must be '%s' or '%s'", ObjectLockGovernance, ObjectLockCompliance)
+	}
+	hasDays, hasDate := t.ObjectLockRetentionDays.IsSet(), t.ObjectLockRetainUntil != ""
+	if t.ObjectLockMode != "" && hasDays == hasDate {
+		return fmt.Errorf("ObjectLockMode requires either ObjectLockRetentionDays or ObjectLockRetainUntil")
+	}
+	if t.ObjectLockMode == "" && (hasDays || hasDate) {
+		return fmt.Errorf("ObjectLockRetentionDays and ObjectLockRetainUntil require ObjectLockMode")
+	}
+	if hasDays && t.ObjectLockRetentionDays.Or(0) < 1 {
+		return fmt.Errorf("ObjectLockRetentionDays must be 1 or higher")
+	}
+	if _, err := time.Parse(ObjectLockDateFormat, t.ObjectLockRetainUntil); hasDate && err != nil {
+		return fmt.Errorf("ObjectLockRetainUntil must be a date like 2030-12-31")
+	}
+	return nil
+}
+
+// UsesObjectLock reports whether the objects of a task are uploaded with retention or legal hold
+func (t Task) UsesObjectLock() bool {
+	return t.ObjectLockMode != "" || t.ObjectLockLegalHold.Or(false)
+}
+
+// Validate checks if the notifier has all required settings
+func (n Notification) Validate() error {
+	switch strings.ToLower(n.Type) {
+	case NotifyWebhook:
+		if n.URL == "" {
+			return fmt.Errorf("URL is required for webhook notifications")
+		}
+	case NotifySMTP:
+		if n.SMTPHost == "" || n.From == "" || len(n.To) == 0 {
+			return fmt.Errorf("SMTPHost, From and To are required for smtp notifications")
+		}
+		if port := n.SMTPPort.Or(DefaultSMTPPort); port < 1 || port > 65535 {
+			return fmt.Errorf("invalid SMTPPort %d", port)
+		}
+	default:
+		return fmt.Errorf("invalid type '%s', must be webhook or smtp", n.Type)
+	}
+
+	for _, event := range n.On {
+		switch strings.ToLower(event) {
+		case NotifyOnSuccess, NotifyOnFailure, NotifyOnWarning, NotifyOnRestoreReady:
+		default:
+			return fmt.Errorf("invalid event '%s', must be success, failure, warning or restore-ready", event)
+		}
+	}
+	return nil
+}
+
+// NotifiesOn reports whether the notifier fires for an event, all events if On is empty
+func (n Notification) NotifiesOn(event string) bool {
+	if len(n.On) == 0 {
+		return true
+	}
+	for _, on := range n.On {
+		if strings.EqualFold(on, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateStreams checks the stream sources of all tasks, stdin can only be read once
+func validateStreams(tasks []Task) error {
+	stdinSources := 0
+	for _, task := range tasks {
+		for _, stream := range task.Streams {
+			if stream.ObjectName == "" {
+				return fmt.Errorf("❌ ObjectName is required for stream sources")
+			}
+			switch strings.ToLower(stream.Type) {
+			case StreamCommand:
+				if stream.Command == "" {
+					return fmt.Errorf("❌ Command is required for stream source '%s'", stream.ObjectName)
+				}
+			case StreamStdin:
+				stdinSources++
+			default:
+				return fmt.Errorf("❌ invalid stream type '%s' for '%s', must be command or stdin", stream.Type, stream.ObjectName)
+			}
+		}
+	}
+
+	if stdinSources > 1 {
+		return fmt.Errorf("❌ only one stream source can read from stdin")
+	}
+	return nil
+}
+
+// readInputFile reads the input file
 func readInputFile(inputFile string) ([]byte, error) {
 	data, err := os.ReadFile(inputFile)
 	if err != nil {
@@ -147,45 +710,54 @@ func readInputFile(inputFile string) ([]byte, error) {
 	return data, nil
 }
 
-// parseTasksJSON parses JSON data into Tasks struct
-func parseTasksJSON(data []byte) (*Tasks, error) {
-	var tasks Tasks
-	if err := json.Unmarshal(data, &tasks); err != nil {
-		return nil, fmt.Errorf("❌ failed to parse JSON: %w", err)
-	}
-	return &tasks, nil
+// storageClasses maps the StorageClass values of the input file to AWS S3 storage classes
+var storageClasses = map[string]types.StorageClass{
+	"STANDARD":                   types.StorageClassStandard,
+	"STANDARD_IA":                types.StorageClassStandardIa,
+	"ONEZONE_IA":                 types.StorageClassOnezoneIa,
+	"INTELLIGENT_TIERING":        types.StorageClassIntelligentTiering,
+	"DEEP_ARCHIVE":               types.StorageClassDeepArchive,
+	"GLACIER_IR":                 types.StorageClassGlacierIr,
+	"GLACIER":                    types.StorageClassGlacier,
+	"GLACIER_FLEXIBLE_RETRIEVAL": types.StorageClassGlacier,
+	"EXPRESS_ONEZONE":            types.StorageClassExpressOnezone,
+	"REDUCED_REDUNDANCY":         types.StorageClassReducedRedundancy,
 }
 
+// bucketNamePattern matches the allowed characters of S3 bucket names
+var bucketNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*[a-z0-9]$`)
+
 // ParseStorageClass converts string to AWS S3 storage class type
 func ParseStorageClass(storageClass string) types.StorageClass {
-	storageClassMap := map[string]types.StorageClass{
-		"STANDARD":                   types.StorageClassStandard,
-		"STANDARD_IA":                types.StorageClassStandardIa,
-		"ONEZONE_IA":                 types.StorageClassOnezoneIa,
-		"DEEP_ARCHIVE":               types.StorageClassDeepArchive,
-		"GLACIER_IR":                 types.StorageClassGlacierIr,
-		"GLACIER":                    types.StorageClassGlacier,
-		"GLACIER_FLEXIBLE_RETRIEVAL": types.StorageClassGlacier,
-		"EXPRESS_ONEZONE":            types.StorageClassExpressOnezone,
-		"REDUCED_REDUNDANCY":         types.StorageClassReducedRedundancy,
-	}
-
-	if class, exists := storageClassMap[storageClass]; exists {
+	if class, exists := storageClasses[storageClass]; exists {
 		return class
 	}
 	return types.StorageClassStandard
 }
 
-// ParseCleanupFlag converts string to boolean for cleanup setting
-func ParseCleanupFlag(cleanup string) bool {
-	switch strings.ToLower(cleanup) {
-	case "true", "yes":
-		return true
-	case "false", "no":
-		return false
-	default:
-		return DefaultCleanupTmpStorage
+// IsKnownStorageClass reports whether a StorageClass value is supported, empty means STANDARD
+func IsKnownStorageClass(storageClass string) bool {
+	_, exists := storageClasses[storageClass]
+	return storageClass == "" || exists
+}
+
+// ValidateBucketName checks a name against the S3 general purpose bucket naming rules
+func ValidateBucketName(bucket string) error {
+	switch {
+	case len(bucket) < 3 || len(bucket) > 63:
+		return fmt.Errorf("bucket name '%s' must be 3 to 63 characters long", bucket)
+	case !bucketNamePattern.MatchString(bucket):
+		return fmt.Errorf("bucket name '%s' may only contain lowercase letters, digits, dots and hyphens and must begin and end with a letter or digit", bucket)
+	case strings.Contains(bucket, ".."):
+		return fmt.Errorf("bucket name '%s' must not contain two adjacent dots", bucket)
+	case net.ParseIP(bucket) != nil:
+		return fmt.Errorf("bucket name '%s' must not be formatted as an IP address", bucket)
+	case strings.HasPrefix(bucket, "xn--") || strings.HasPrefix(bucket, "sthree-"):
+		return fmt.Errorf("bucket name '%s' uses a reserved prefix", bucket)
+	case strings.HasSuffix(bucket, "-s3alias") || strings.HasSuffix(bucket, "--ol-s3"):
+		return fmt.Errorf("bucket name '%s' uses a reserved suffix", bucket)
 	}
+	return nil
 }
 
 // ParseArchiveSplitMB converts string to int64 for archive split size
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Input file formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// unknownFieldPattern matches the unknown field errors of the YAML decoder
+var unknownFieldPattern = regexp.MustCompile(`field (\S+) not found in type \S+`)
+
+// InputFormat returns the format of an input file by its extension, unknown extensions are JSON
+func InputFormat(inputFile string) string {
+	switch strings.ToLower(filepath.Ext(inputFile)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// decodeInput decodes the input file data into v. In strict mode unknown fields are errors
+// reported with their line number. JSON uses its own decoder, the YAML decoder rejects valid JSON escapes.
+func decodeInput(format string, data []byte, v any, strict bool) error {
+	switch format {
+	case FormatTOML:
+		return decodeTOML(data, v, strict)
+	case FormatJSON:
+		return decodeJSON(data, v, strict)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(strict)
+	err := decoder.Decode(v)
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages := make([]string, len(typeErr.Errors))
+		for i, message := range typeErr.Errors {
+			messages[i] = unknownFieldPattern.ReplaceAllString(message, `unknown field "$1"`)
+		}
+		return fmt.Errorf("❌ invalid %s input file: %s", format, strings.Join(messages, "; "))
+	}
+	return fmt.Errorf("❌ failed to parse %s input file: %s", format, strings.TrimPrefix(err.Error(), "yaml: "))
+}
+
+// decodeJSON decodes JSON data, errors are reported with the line of the decoder offset. Unknown field
+// errors have no offset, their line is the first one that defines the key.
+func decodeJSON(data []byte, v any, strict bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(v)
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("❌ failed to parse json input file: line %d: %s", offsetLine(data, syntaxErr.Offset), syntaxErr)
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("❌ invalid json input file: line %d: cannot use %s as %s for field %q",
+			offsetLine(data, typeErr.Offset), typeErr.Value, typeErr.Type, typeErr.Field)
+	}
+	if quoted, found := strings.CutPrefix(err.Error(), "json: unknown field "); found {
+		name, _ := strconv.Unquote(quoted)
+		return fmt.Errorf("❌ invalid json input file: line %d: unknown field %q", jsonKeyLine(data, name), name)
+	}
+	return fmt.Errorf("❌ invalid json input file: %s", strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// offsetLine returns the line of a byte offset in data
+func offsetLine(data []byte, offset int64) int {
+	offset = min(max(offset, 0), int64(len(data)))
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// jsonKeyLine returns the first line that defines a key, the JSON decoder does not expose key positions
+func jsonKeyLine(data []byte, name string) int {
+	pattern := regexp.MustCompile(`"` + regexp.QuoteMeta(name) + `"\s*:`)
+	if loc := pattern.FindIndex(data); loc != nil {
+		return offsetLine(data, int64(loc[0]))
+	}
+	return 0
+}
+
+// decodeTOML decodes TOML data, in strict mode all keys that do not match a field are errors
+func decodeTOML(data []byte, v any, strict bool) error {
+	metadata, err := toml.Decode(string(data), v)
+	if err != nil {
+		return fmt.Errorf("❌ failed to parse toml input file: %s", strings.TrimPrefix(err.Error(), "toml: "))
+	}
+	if !strict {
+		return nil
+	}
+
+	var messages []string
+	for _, key := range metadata.Undecoded() {
+		name := key[len(key)-1]
+		messages = append(messages, fmt.Sprintf("line %d: unknown field %q", tomlKeyLine(data, name), key.String()))
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("❌ invalid toml input file: %s", strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// tomlKeyLine returns the first line that defines a key or table, the TOML decoder does not expose key positions
+func tomlKeyLine(data []byte, name string) int {
+	quoted := regexp.QuoteMeta(name)
+	pattern := regexp.MustCompile(`^\s*(\[\[?\s*)?([\w.-]+\.)?("` + quoted + `"|'` + quoted + `'|` + quoted + `)\s*(=|\]|\.)`)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		if pattern.MatchString(scanner.Text()) {
+			return line
+		}
+	}
+	return 0
+}
+
+// EncodeTasks encodes tasks in the format of an input file
+func EncodeTasks(format string, tasks *Tasks) ([]byte, error) {
+	switch format {
+	case FormatYAML:
+		return yaml.Marshal(tasks)
+	case FormatTOML:
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(tasks); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return json.MarshalIndent(tasks, "", "  ")
+	}
+}
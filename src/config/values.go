@@ -0,0 +1,210 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Int is an optional integer setting of the input file, it also accepts the legacy quoted form ("250")
+type Int struct {
+	value int64
+	set   bool
+}
+
+// Bool is an optional boolean setting of the input file, it also accepts the legacy quoted forms ("true", "yes")
+type Bool struct {
+	value bool
+	set   bool
+}
+
+// NewInt returns a set Int
+func NewInt(value int64) Int {
+	return Int{value: value, set: true}
+}
+
+// IsSet reports whether the setting is present in the input file
+func (i Int) IsSet() bool {
+	return i.set
+}
+
+// Or returns the value or def if the setting is not set
+func (i Int) Or(def int64) int64 {
+	if !i.set {
+		return def
+	}
+	return i.value
+}
+
+// IsZero reports whether the setting is unset, unset settings are omitted when encoding
+func (i Int) IsZero() bool {
+	return !i.set
+}
+
+// parse sets the value from its string form, an empty string leaves it unset
+func (i *Int) parse(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		*i = Int{}
+		return nil
+	}
+	value, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer '%s'", s)
+	}
+	*i = NewInt(value)
+	return nil
+}
+
+// UnmarshalJSON accepts numbers, numeric strings and null
+func (i *Int) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return i.parse(s)
+	}
+	if string(data) == "null" {
+		*i = Int{}
+		return nil
+	}
+	return i.parse(string(data))
+}
+
+// MarshalJSON encodes the value as a number
+func (i Int) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(i.value, 10)), nil
+}
+
+// UnmarshalYAML accepts numbers and numeric strings
+func (i *Int) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expected an integer", node.Line)
+	}
+	if node.Tag == "!!null" {
+		*i = Int{}
+		return nil
+	}
+	if err := i.parse(node.Value); err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	return nil
+}
+
+// MarshalYAML encodes the value as a number
+func (i Int) MarshalYAML() (any, error) {
+	return i.value, nil
+}
+
+// UnmarshalTOML accepts integers and numeric strings
+func (i *Int) UnmarshalTOML(data any) error {
+	switch value := data.(type) {
+	case int64:
+		*i = NewInt(value)
+		return nil
+	case string:
+		return i.parse(value)
+	default:
+		return fmt.Errorf("expected an integer, got %v", data)
+	}
+}
+
+// MarshalTOML encodes the value as an integer
+func (i Int) MarshalTOML() ([]byte, error) {
+	return []byte(strconv.FormatInt(i.value, 10)), nil
+}
+
+// NewBool returns a set Bool
+func NewBool(value bool) Bool {
+	return Bool{value: value, set: true}
+}
+
+// IsSet reports whether the setting is present in the input file
+func (b Bool) IsSet() bool {
+	return b.set
+}
+
+// Or returns the value or def if the setting is not set
+func (b Bool) Or(def bool) bool {
+	if !b.set {
+		return def
+	}
+	return b.value
+}
+
+// IsZero reports whether the setting is unset, unset settings are omitted when encoding
+func (b Bool) IsZero() bool {
+	return !b.set
+}
+
+// parse sets the value from its string form (true, yes, false, no), an empty string leaves it unset
+func (b *Bool) parse(s string) error {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		*b = Bool{}
+	case "true", "yes":
+		*b = NewBool(true)
+	case "false", "no":
+		*b = NewBool(false)
+	default:
+		return fmt.Errorf("invalid boolean '%s', must be true, false, yes or no", s)
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts booleans, their string forms and null
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return b.parse(s)
+	}
+	if string(data) == "null" {
+		*b = Bool{}
+		return nil
+	}
+	return b.parse(string(data))
+}
+
+// MarshalJSON encodes the value as a boolean
+func (b Bool) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatBool(b.value)), nil
+}
+
+// UnmarshalYAML accepts booleans and their string forms
+func (b *Bool) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: expected a boolean", node.Line)
+	}
+	if node.Tag == "!!null" {
+		*b = Bool{}
+		return nil
+	}
+	if err := b.parse(node.Value); err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	return nil
+}
+
+// MarshalYAML encodes the value as a boolean
+func (b Bool) MarshalYAML() (any, error) {
+	return b.value, nil
+}
+
+// UnmarshalTOML accepts booleans and their string forms
+func (b *Bool) UnmarshalTOML(data any) error {
+	switch value := data.(type) {
+	case bool:
+		*b = NewBool(value)
+		return nil
+	case string:
+		return b.parse(value)
+	default:
+		return fmt.Errorf("expected a boolean, got %v", data)
+	}
+}
+
+// MarshalTOML encodes the value as a boolean
+func (b Bool) MarshalTOML() ([]byte, error) {
+	return []byte(strconv.FormatBool(b.value)), nil
+}
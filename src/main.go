@@ -5,7 +5,13 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/rtitz/aws-s3-backup/config"
@@ -16,12 +22,17 @@ import (
 // main is the application entry point
 func main() {
 	if err := run(); err != nil {
-		log.Fatalf("Error: %v", err)
+		slog.Error(fmt.Sprintf("Error: %v", err))
+		os.Exit(services.ExitCode(err))
 	}
 }
 
 // run orchestrates the main application flow
 func run() error {
+	if len(os.Args) > 1 && services.IsOfflineCommand(os.Args[1]) {
+		return runOfflineCommand(os.Args[1], os.Args[2:])
+	}
+
 	flags := parseFlags()
 
 	if flags.version {
@@ -29,13 +40,40 @@ func run() error {
 		return nil
 	}
 
+	utils.SetProgressEnabled(!flags.noProgress)
+
+	closeLog, err := utils.SetupLogging(flags.logLevel, flags.logFormat, flags.logFile)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
 	cfg := buildConfig(flags)
 	if err := validateAndShowHelp(cfg); err != nil {
 		return err
 	}
+	utils.SetS3Endpoint(utils.S3Endpoint{
+		URL:               cfg.Endpoint,
+		PathStyle:         cfg.PathStyle,
+		CABundle:          cfg.CABundle,
+		NoRegionDiscovery: cfg.NoRegionDiscovery,
+	})
+
+	if cfg.InputFile != "" {
+		if cfg.Notifications, err = config.LoadNotifications(cfg.InputFile); err != nil {
+			return err
+		}
+	}
 
 	fmt.Printf("%s %s\n\n", config.AppName, config.AppVersion)
-	ctx := context.Background()
+	if cfg.MetricsListen != "" {
+		if err := utils.StartMetricsServer(cfg.MetricsListen); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	return executeMode(ctx, cfg, flags)
 }
@@ -55,6 +93,38 @@ func buildConfig(flags *appFlags) *config.Config {
 		AutoRetryDownloadMinutes:   flags.autoRetryDownloadMinutes,
 		RestoreExpiresAfterDays:    flags.restoreExpiresAfterDays,
 		DryRun:                     flags.dryRun,
+		UploadLimitKBps:            flags.uploadLimitKBps,
+		DownloadLimitKBps:          flags.downloadLimitKBps,
+		TransferWindow:             flags.transferWindow,
+		LogLevel:                   flags.logLevel,
+		LogFormat:                  flags.logFormat,
+		LogFile:                    flags.logFile,
+		ReportFile:                 flags.reportFile,
+		MetricsFile:                flags.metricsFile,
+		MetricsListen:              flags.metricsListen,
+		StateFile:                  flags.stateFile,
+		PreCommand:                 flags.preCommand,
+		PostCommand:                flags.postCommand,
+		OnErrorCommand:             flags.onErrorCommand,
+		HookTimeoutMinutes:         flags.hookTimeoutMinutes,
+		ForceUnlock:                flags.forceUnlock,
+		LegalHold:                  strings.ToLower(flags.legalHold),
+		NoLock:                     flags.noLock,
+		LockStaleMinutes:           flags.lockStaleMinutes,
+		DecryptionSecret:           flags.decryptionSecret,
+		IdentityFile:               flags.identityFile,
+		KeyringFile:                flags.keyringFile,
+		NewEncryptionSecret:        flags.newEncryptionSecret,
+		NewEncryptionKeyID:         flags.newEncryptionKeyID,
+		NewKDF:                     flags.newKDF,
+		SSECustomerKey:             flags.sseCustomerKey,
+		Endpoint:                   flags.endpoint,
+		PathStyle:                  flags.pathStyle,
+		CABundle:                   flags.caBundle,
+		NoRegionDiscovery:          flags.noRegionDiscovery,
+		StripPrefix:                flags.stripPrefix,
+		Flat:                       flags.flat,
+		MappingFile:                flags.mappingFile,
 	}
 }
 
@@ -70,12 +140,24 @@ func validateAndShowHelp(cfg *config.Config) error {
 	return nil
 }
 
-// executeMode runs the appropriate operation mode (backup or restore)
+// executeMode runs the appropriate operation mode (backup, restore, daemon, validate, keygen or rekey)
 func executeMode(ctx context.Context, cfg *config.Config, flags *appFlags) error {
+	// Validation and key generation work offline (no AWS auth needed)
+	if cfg.Mode == "validate" {
+		return executeValidate(cfg)
+	}
+	if cfg.Mode == "keygen" {
+		return executeKeygen(cfg)
+	}
+
 	// Handle dry-run backup mode (no AWS auth needed)
-	if cfg.Mode == "backup" && cfg.DryRun {
+	if cfg.Mode == "backup" && cfg.DryRun && !cfg.ForceUnlock && cfg.LegalHold == "" {
 		return handleDryRunBackup(ctx, cfg)
 	}
+	if cfg.Mode == "daemon" && cfg.DryRun {
+		log.Println("⚠️  [DRY-RUN] Skipping AWS authentication - no S3 operations will be performed")
+		return executeDaemon(ctx, aws.Config{}, cfg)
+	}
 
 	// Get AWS configuration
 	awsCfg, err := getAWSConfig(ctx, cfg)
@@ -83,24 +165,65 @@ func executeMode(ctx context.Context, cfg *config.Config, flags *appFlags) error
 		return err
 	}
 
+	if cfg.ForceUnlock {
+		return utils.ForceUnlock(ctx, awsCfg, cfg.Bucket, cfg.Prefix)
+	}
+	if cfg.LegalHold != "" {
+		return utils.SetLegalHolds(ctx, awsCfg, cfg.Bucket, cfg.Prefix, cfg.LegalHold == config.LegalHoldOn, cfg.DryRun)
+	}
+
 	// Execute the appropriate mode
 	switch cfg.Mode {
 	case "backup":
 		return executeBackup(ctx, awsCfg, cfg)
 	case "restore":
 		return executeRestore(ctx, awsCfg, cfg, flags)
+	case "daemon":
+		return executeDaemon(ctx, awsCfg, cfg)
+	case "rekey":
+		return executeRekey(ctx, awsCfg, cfg)
 	default:
 		return fmt.Errorf("❌ invalid mode: %s", cfg.Mode)
 	}
 }
 
+// executeValidate checks the input file and prints all problems found
+func executeValidate(cfg *config.Config) error {
+	problems := services.ValidateInput(cfg.InputFile)
+	if len(problems) == 0 {
+		log.Printf("✅ %s is valid", cfg.InputFile)
+		return nil
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	return fmt.Errorf("❌ %s has %d problems", cfg.InputFile, len(problems))
+}
+
+// executeKeygen creates a new identity file and prints its public key for the Recipients of backup tasks
+func executeKeygen(cfg *config.Config) error {
+	identity, err := utils.GenerateIdentity()
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteIdentityFile(cfg.IdentityFile, identity); err != nil {
+		return err
+	}
+
+	log.Printf("🔑 Identity written to %s, keep it offline and safe", cfg.IdentityFile)
+	fmt.Printf("Public key: %s\n", identity.Recipient())
+	return nil
+}
+
 // handleDryRunBackup executes backup in dry-run mode without AWS operations
 func handleDryRunBackup(ctx context.Context, cfg *config.Config) error {
 	log.Println("⚠️  [DRY-RUN] Skipping AWS authentication - no S3 operations will be performed")
 	log.Println("⚠️  [DRY-RUN] No bucket validation or AWS connectivity checks performed")
 	log.Println("⚠️  [DRY-RUN] Ensure bucket exists and credentials work before real backup")
 	backupService := services.NewBackupService(aws.Config{})
-	return backupService.ProcessBackup(ctx, cfg.InputFile, cfg.DryRun)
+	err := backupService.ProcessBackup(ctx, cfg.InputFile, cfg.DryRun)
+	return finishRun(ctx, cfg, backupService.Report(), err)
 }
 
 // getAWSConfig creates and validates AWS configuration
@@ -138,8 +261,38 @@ func showAuthenticationHelp(cfg *config.Config, err error) {
 
 // executeBackup runs the backup operation
 func executeBackup(ctx context.Context, awsCfg aws.Config, cfg *config.Config) error {
+	windows, err := utils.ParseTransferWindows(cfg.TransferWindow)
+	if err != nil {
+		return err
+	}
+
 	backupService := services.NewBackupService(awsCfg)
-	return backupService.ProcessBackup(ctx, cfg.InputFile, cfg.DryRun)
+	backupService.SetTransferLimits(cfg.UploadLimitKBps, windows)
+	backupService.SetLocking(cfg.NoLock, cfg.LockStaleMinutes)
+	err = backupService.ProcessBackup(ctx, cfg.InputFile, cfg.DryRun)
+	return finishRun(ctx, cfg, backupService.Report(), err)
+}
+
+// executeDaemon runs the scheduled backup tasks until the process is stopped, SIGHUP reloads the input file
+func executeDaemon(ctx context.Context, awsCfg aws.Config, cfg *config.Config) error {
+	windows, err := utils.ParseTransferWindows(cfg.TransferWindow)
+	if err != nil {
+		return err
+	}
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	daemon := services.NewDaemon(awsCfg, cfg.InputFile, cfg.StateFile, cfg.DryRun,
+		func(ctx context.Context, notifications []config.Notification, report *services.RunReport, err error) {
+			runCfg := *cfg
+			runCfg.Notifications = notifications
+			finishRun(ctx, &runCfg, report, err)
+		})
+	daemon.SetTransferLimits(cfg.UploadLimitKBps, windows)
+	daemon.SetLocking(cfg.NoLock, cfg.LockStaleMinutes)
+	return daemon.Run(ctx, reload)
 }
 
 // executeRestore runs the restore operation
@@ -147,15 +300,238 @@ func executeRestore(ctx context.Context, awsCfg aws.Config, cfg *config.Config,
 	if cfg.DryRun {
 		log.Println("⚠️  [DRY-RUN] Skipping AWS authentication - using local directory as bucket")
 		restoreService := services.NewRestoreService(aws.Config{})
-		return restoreService.ProcessRestore(ctx, cfg.Bucket, cfg.Prefix, cfg.InputFile, 
+		restoreService.SetHooks(restoreHooks(cfg))
+		if err := setRestoreLayout(restoreService, cfg); err != nil {
+			return err
+		}
+		restoreService.SetDecryptionSecret(cfg.DecryptionSecret)
+		if err := setRestoreKeys(ctx, restoreService, cfg); err != nil {
+			return err
+		}
+		err := restoreService.ProcessRestore(ctx, cfg.Bucket, cfg.Prefix, cfg.InputFile, 
 			cfg.DownloadLocation, cfg.DryRun, flags.skipDecompression, cfg.RetrievalMode, 
 			int32(cfg.RestoreExpiresAfterDays), int(cfg.AutoRetryDownloadMinutes), cfg.RestoreWithoutConfirmation)
+		return finishRun(ctx, cfg, restoreService.Report(), err)
 	}
 	
+	windows, err := utils.ParseTransferWindows(cfg.TransferWindow)
+	if err != nil {
+		return err
+	}
+
 	restoreService := services.NewRestoreService(awsCfg)
-	return restoreService.ProcessRestore(ctx, cfg.Bucket, cfg.Prefix, cfg.InputFile, 
+	restoreService.SetTransferLimits(cfg.DownloadLimitKBps, windows)
+	restoreService.SetNotifications(cfg.Notifications)
+	restoreService.SetHooks(restoreHooks(cfg))
+	if err := setRestoreLayout(restoreService, cfg); err != nil {
+		return err
+	}
+	restoreService.SetDecryptionSecret(cfg.DecryptionSecret)
+	if err := setRestoreKeys(ctx, restoreService, cfg); err != nil {
+		return err
+	}
+	sse, err := customerKeyEncryption(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	restoreService.SetServerSideEncryption(sse)
+	err = restoreService.ProcessRestore(ctx, cfg.Bucket, cfg.Prefix, cfg.InputFile, 
 		cfg.DownloadLocation, cfg.DryRun, flags.skipDecompression, cfg.RetrievalMode, 
 		int32(cfg.RestoreExpiresAfterDays), int(cfg.AutoRetryDownloadMinutes), cfg.RestoreWithoutConfirmation)
+	return finishRun(ctx, cfg, restoreService.Report(), err)
+}
+
+// setRestoreLayout sets the prefix to strip, flat mode and the mapping file of a restore if configured
+func setRestoreLayout(restoreService *services.RestoreService, cfg *config.Config) error {
+	layout := services.RestoreLayout{StripPrefix: cfg.StripPrefix, Flat: cfg.Flat}
+	if cfg.MappingFile != "" {
+		mappings, err := config.LoadPathMappings(cfg.MappingFile)
+		if err != nil {
+			return err
+		}
+		layout.Mappings = mappings
+	}
+	restoreService.SetLayout(layout)
+	return nil
+}
+
+// setRestoreKeys loads the identity and keyring files of a restore if configured
+func setRestoreKeys(ctx context.Context, restoreService *services.RestoreService, cfg *config.Config) error {
+	keys, err := loadDecryptionKeys(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	restoreService.SetDecryptionKeys(keys)
+	return nil
+}
+
+// loadDecryptionKeys loads the keyring and identity files if configured
+func loadDecryptionKeys(ctx context.Context, cfg *config.Config) (utils.DecryptionKeys, error) {
+	var keys utils.DecryptionKeys
+	if cfg.KeyringFile != "" {
+		var err error
+		if keys, err = utils.LoadKeyring(ctx, cfg.KeyringFile); err != nil {
+			return keys, err
+		}
+	}
+	if cfg.IdentityFile != "" {
+		identities, err := utils.LoadIdentities(cfg.IdentityFile)
+		if err != nil {
+			return keys, err
+		}
+		keys.Identities = append(keys.Identities, identities...)
+	}
+	return keys, nil
+}
+
+// executeRekey re-encrypts the encrypted objects of -bucket and -prefix under the new secret
+func executeRekey(ctx context.Context, awsCfg aws.Config, cfg *config.Config) error {
+	oldKeys, err := loadDecryptionKeys(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.DecryptionSecret != "" || oldKeys.IsEmpty() {
+		password, err := readDecryptionPassword(ctx, cfg, "Enter the current decryption password")
+		if err != nil {
+			return err
+		}
+		oldKeys.Passwords = append(oldKeys.Passwords, password)
+	}
+
+	newPassword, err := utils.ResolveSecret(ctx, cfg.NewEncryptionSecret)
+	if err != nil {
+		return err
+	}
+	if err := utils.ValidateEncryptionPassword(newPassword); err != nil {
+		return err
+	}
+	kdf, err := utils.DefaultKDF(cfg.NewKDF)
+	if err != nil {
+		return err
+	}
+	windows, err := utils.ParseTransferWindows(cfg.TransferWindow)
+	if err != nil {
+		return err
+	}
+	sse, err := customerKeyEncryption(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	rekeyService := services.NewRekeyService(awsCfg, oldKeys, newPassword, utils.NewPasswordEncryptor(newPassword, cfg.NewEncryptionKeyID, kdf))
+	rekeyService.SetTransferLimits(cfg.UploadLimitKBps, cfg.DownloadLimitKBps, windows)
+	rekeyService.SetLocking(cfg.NoLock, cfg.LockStaleMinutes)
+	rekeyService.SetGlacierRestore(cfg.RetrievalMode, int32(cfg.RestoreExpiresAfterDays), int(cfg.AutoRetryDownloadMinutes))
+	rekeyService.SetServerSideEncryption(sse)
+	err = rekeyService.ProcessRekey(ctx, cfg.Bucket, cfg.Prefix, cfg.DryRun)
+	return finishRun(ctx, cfg, rekeyService.Report(), err)
+}
+
+// customerKeyEncryption resolves -sseCustomerKey, nil if not set
+func customerKeyEncryption(ctx context.Context, cfg *config.Config) (*utils.ServerSideEncryption, error) {
+	if cfg.SSECustomerKey == "" {
+		return nil, nil
+	}
+	return utils.NewCustomerKeyEncryption(ctx, cfg.SSECustomerKey)
+}
+
+// readDecryptionPassword resolves -decryptionSecret or prompts for the password
+func readDecryptionPassword(ctx context.Context, cfg *config.Config, prompt string) (string, error) {
+	if cfg.DecryptionSecret != "" {
+		return utils.ResolveSecret(ctx, cfg.DecryptionSecret)
+	}
+	fmt.Printf("🔐 %s: ", prompt)
+	var password string
+	fmt.Scanln(&password)
+	if password == "" {
+		return "", fmt.Errorf("❌ decryption password required")
+	}
+	return password, nil
+}
+
+// runOfflineCommand runs decrypt, combine or extract on local files, no AWS credentials are needed
+func runOfflineCommand(command string, args []string) error {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	cfg := &config.Config{}
+	fs.StringVar(&cfg.DecryptionSecret, "decryptionSecret", "", "Decrypt: decryption secret reference (env:VAR, file:/path, cmd:command or keyring:account) instead of a password prompt")
+	fs.StringVar(&cfg.IdentityFile, "identity", "", "Decrypt: identity file with X25519 private keys for files encrypted to Recipients")
+	fs.StringVar(&cfg.KeyringFile, "keyring", "", "Decrypt: keyring file with passwords by key ID and identities")
+	fs.StringVar(&cfg.DownloadLocation, "destination", "", "Extract: directory to extract to instead of the directory of each archive")
+	noProgress := fs.Bool("noProgress", false, "Disable progress bars and periodic progress lines")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [parameters] <files>\n\nParameters:\n\n", filepath.Base(os.Args[0]), command)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("❌ no files given for %s", command)
+	}
+
+	utils.SetProgressEnabled(!*noProgress)
+	closeLog, err := utils.SetupLogging(config.DefaultLogLevel, config.DefaultLogFormat, "")
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch command {
+	case services.CommandDecrypt:
+		keys, err := loadDecryptionKeys(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		if cfg.DecryptionSecret != "" || keys.IsEmpty() {
+			password, err := readDecryptionPassword(ctx, cfg, "Enter decryption password")
+			if err != nil {
+				return err
+			}
+			keys.Passwords = append(keys.Passwords, password)
+		}
+		return services.DecryptFiles(fs.Args(), keys)
+	case services.CommandCombine:
+		return services.CombineFiles(fs.Args())
+	default:
+		return services.ExtractFiles(fs.Args(), cfg.DownloadLocation)
+	}
+}
+
+// restoreHooks returns the hook commands configured for restore runs
+func restoreHooks(cfg *config.Config) services.Hooks {
+	return services.Hooks{
+		PreCommand:     cfg.PreCommand,
+		PostCommand:    cfg.PostCommand,
+		OnErrorCommand: cfg.OnErrorCommand,
+		Timeout:        time.Duration(cfg.HookTimeoutMinutes) * time.Minute,
+	}
+}
+
+// finishRun writes the run report and metrics if requested, sends notifications and passes the run error through
+func finishRun(ctx context.Context, cfg *config.Config, report *services.RunReport, runErr error) error {
+	services.RecordReportMetrics(utils.DefaultMetrics, report)
+
+	if cfg.MetricsFile != "" {
+		utils.DefaultMetrics.KeepPreviousSamples(cfg.MetricsFile, utils.MetricTaskLastSuccess)
+		if err := utils.DefaultMetrics.WriteTextfile(cfg.MetricsFile); err != nil {
+			slog.Error(err.Error(), "event", utils.EventMetrics, "file", cfg.MetricsFile, "error", err)
+		} else {
+			log.Printf("📈 Metrics written: %s", cfg.MetricsFile)
+		}
+	}
+
+	if cfg.ReportFile != "" {
+		if err := report.WriteFile(cfg.ReportFile); err != nil {
+			slog.Error(err.Error(), "event", utils.EventReport, "file", cfg.ReportFile, "error", err)
+		} else {
+			log.Printf("📄 Report written: %s (status: %s)", cfg.ReportFile, report.Status)
+		}
+	}
+
+	services.NotifyRun(ctx, cfg.Notifications, report)
+	return runErr
 }
 
 type appFlags struct {
@@ -173,15 +549,48 @@ type appFlags struct {
 	version                    bool
 	dryRun                     bool
 	skipDecompression          bool
+	uploadLimitKBps            int64
+	downloadLimitKBps          int64
+	transferWindow             string
+	noProgress                 bool
+	logLevel                   string
+	logFormat                  string
+	logFile                    string
+	reportFile                 string
+	metricsFile                string
+	metricsListen              string
+	stateFile                  string
+	preCommand                 string
+	postCommand                string
+	onErrorCommand             string
+	hookTimeoutMinutes         int64
+	forceUnlock                bool
+	legalHold                  string
+	noLock                     bool
+	lockStaleMinutes           int64
+	decryptionSecret           string
+	identityFile               string
+	keyringFile                string
+	newEncryptionSecret        string
+	newEncryptionKeyID         string
+	newKDF                     string
+	sseCustomerKey             string
+	endpoint                   string
+	pathStyle                  bool
+	caBundle                   string
+	noRegionDiscovery          bool
+	stripPrefix                string
+	flat                       bool
+	mappingFile                string
 }
 
 // parseFlags parses command line arguments and returns application flags
 func parseFlags() *appFlags {
 	flags := &appFlags{}
-	flag.StringVar(&flags.mode, "mode", config.DefaultMode, "Operation mode (backup or restore)")
+	flag.StringVar(&flags.mode, "mode", config.DefaultMode, "Operation mode (backup, restore, daemon, validate, keygen or rekey)")
 	flag.StringVar(&flags.bucket, "bucket", "", "S3 bucket name for restore mode")
 	flag.StringVar(&flags.prefix, "prefix", "", "S3 object prefix filter for restore mode")
-	flag.StringVar(&flags.inputFile, "json", "", "JSON file with input parameters")
+	flag.StringVar(&flags.inputFile, "json", "", "Input file with tasks (JSON, YAML or TOML)")
 	flag.StringVar(&flags.downloadLocation, "destination", "", "Download location for restore mode")
 	flag.StringVar(&flags.retrievalMode, "retrievalMode", config.DefaultRetrievalMode, "Retrieval mode (bulk, standard, or expedited) for Glacier objects")
 	flag.BoolVar(&flags.restoreWithoutConfirmation, "restoreWithoutConfirmation", false, "Skip confirmation for Glacier restores")
@@ -192,6 +601,39 @@ func parseFlags() *appFlags {
 	flag.BoolVar(&flags.version, "version", false, "Print version")
 	flag.BoolVar(&flags.dryRun, "dryrun", false, "Test mode - skip S3 uploads")
 	flag.BoolVar(&flags.skipDecompression, "skipDecompression", false, "Skip archive decompression during restore")
+	flag.Int64Var(&flags.uploadLimitKBps, "uploadLimitKBps", 0, "Upload bandwidth limit in KB/s (0 = unlimited)")
+	flag.Int64Var(&flags.downloadLimitKBps, "downloadLimitKBps", 0, "Download bandwidth limit in KB/s (0 = unlimited)")
+	flag.StringVar(&flags.transferWindow, "transferWindow", "", "Allowed daily transfer windows in local time, e.g. 22:00-06:00,12:00-13:00")
+	flag.BoolVar(&flags.noProgress, "noProgress", false, "Disable progress bars and periodic progress lines")
+	flag.StringVar(&flags.logLevel, "logLevel", config.DefaultLogLevel, "Log level (debug, info, warn or error)")
+	flag.StringVar(&flags.logFormat, "logFormat", config.DefaultLogFormat, "Log format (plain, text or json)")
+	flag.StringVar(&flags.logFile, "logFile", "", "Additionally write log output to this file")
+	flag.StringVar(&flags.reportFile, "report", "", "Write a JSON report with per-task and per-object results to this file")
+	flag.StringVar(&flags.metricsFile, "metricsFile", "", "Write Prometheus metrics to this file (node_exporter textfile collector)")
+	flag.StringVar(&flags.metricsListen, "metricsListen", "", "Serve Prometheus metrics on this address while running, e.g. :9108")
+	flag.StringVar(&flags.stateFile, "stateFile", "", "Daemon mode: file with the last-run state of scheduled tasks (default: json file name with .state.json)")
+	flag.StringVar(&flags.preCommand, "preCommand", "", "Restore mode: command run before the restore, a non-zero exit code aborts it")
+	flag.StringVar(&flags.postCommand, "postCommand", "", "Restore mode: command run after the restore, a non-zero exit code fails it")
+	flag.StringVar(&flags.onErrorCommand, "onErrorCommand", "", "Restore mode: command run if the restore fails")
+	flag.Int64Var(&flags.hookTimeoutMinutes, "hookTimeoutMinutes", config.DefaultHookTimeoutMinutes, "Timeout for restore hook commands in minutes (0 = no timeout)")
+	flag.BoolVar(&flags.forceUnlock, "forceUnlock", false, "Remove the lock object of -bucket and -prefix left by a crashed backup and exit")
+	flag.StringVar(&flags.legalHold, "legalHold", "", "Set (on) or clear (off) the Object Lock legal hold of all objects below -bucket and -prefix and exit")
+	flag.BoolVar(&flags.noLock, "noLock", false, "Do not lock the S3 prefix during backups (for storage without conditional writes)")
+	flag.Int64Var(&flags.lockStaleMinutes, "lockStaleMinutes", config.DefaultLockStaleMinutes, "Minutes without heartbeat after which a lock is considered stale")
+	flag.StringVar(&flags.decryptionSecret, "decryptionSecret", "", "Restore mode: decryption secret reference (env:VAR, file:/path, cmd:command or keyring:account) instead of a password prompt")
+	flag.StringVar(&flags.identityFile, "identity", "", "Restore mode: identity file with X25519 private keys for files encrypted to Recipients; keygen mode: identity file to create")
+	flag.StringVar(&flags.keyringFile, "keyring", "", "Restore mode: keyring file with passwords by key ID and identities, the matching key of each file is used")
+	flag.StringVar(&flags.newEncryptionSecret, "newEncryptionSecret", "", "Rekey mode: new secret or secret reference the objects are re-encrypted with")
+	flag.StringVar(&flags.newEncryptionKeyID, "newEncryptionKeyID", "", "Rekey mode: key ID recorded for the new secret (see EncryptionKeyID)")
+	flag.StringVar(&flags.newKDF, "newKDF", "", "Rekey mode: key derivation function for the new secret (scrypt or argon2id)")
+	flag.StringVar(&flags.sseCustomerKey, "sseCustomerKey", "", "Restore and rekey mode: base64 SSE-C key or secret reference for objects stored with ServerSideEncryption SSE-C")
+	flag.StringVar(&flags.endpoint, "endpoint", "", "URL of an S3-compatible storage (MinIO, Ceph, Wasabi, Garage) instead of AWS S3, e.g. https://minio.example.com:9000")
+	flag.BoolVar(&flags.pathStyle, "pathStyle", false, "Use path-style addressing (endpoint/bucket/key) instead of bucket subdomains")
+	flag.StringVar(&flags.caBundle, "caBundle", "", "PEM file with additional CA certificates to trust, e.g. for an on-prem endpoint")
+	flag.BoolVar(&flags.noRegionDiscovery, "noRegionDiscovery", false, "Use -region for all buckets instead of looking up their region")
+	flag.StringVar(&flags.stripPrefix, "stripPrefix", "", "Restore mode: leading key path removed from restored files, e.g. backup/home")
+	flag.BoolVar(&flags.flat, "flat", false, "Restore mode: restore all files directly into -destination without the directories of their keys")
+	flag.StringVar(&flags.mappingFile, "mapping", "", "Restore mode: file with mappings of S3 prefixes to local directories (JSON, YAML or TOML)")
 	flag.Parse()
 
 	flags.mode = strings.ToLower(flags.mode)
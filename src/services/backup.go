@@ -2,11 +2,16 @@ package services
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,8 +22,28 @@ import (
 )
 
 type BackupService struct {
-	cfg     aws.Config
-	summary *BackupSummary
+	cfg             aws.Config
+	summary         *BackupSummary
+	report          *RunReport
+	currentTask     *TaskReport
+	throttle        *utils.Throttle
+	noLock          bool
+	lockStaleAfter  time.Duration
+	encryptor       *utils.Encryptor
+	namer           *utils.ObjectNamer
+	manifest        *utils.Manifest
+	sse             *utils.ServerSideEncryption
+	objectLock      *utils.ObjectLock
+	destinations    []*destination
+	configs         *utils.ConfigCache
+}
+
+// destination is a bucket the current task uploads to, err is set once an upload to it failed
+type destination struct {
+	bucket       string
+	storageClass types.StorageClass
+	cfg          aws.Config
+	err          error
 }
 
 type BackupSummary struct {
@@ -35,12 +60,66 @@ type BackupSummary struct {
 
 func NewBackupService(cfg aws.Config) *BackupService {
 	return &BackupService{
-		cfg:     cfg,
-		summary: &BackupSummary{},
+		cfg:            cfg,
+		summary:        &BackupSummary{},
+		report:         newRunReport("backup", false),
+		lockStaleAfter: config.DefaultLockStaleMinutes * time.Minute,
+		configs:        utils.NewConfigCache(cfg),
+	}
+}
+
+// Report returns the machine-readable report of the last run
+func (s *BackupService) Report() *RunReport {
+	return s.report
+}
+
+// SetTransferLimits sets the global upload bandwidth limit and transfer windows (tasks may override them)
+func (s *BackupService) SetTransferLimits(uploadLimitKBps int64, windows []utils.TransferWindow) {
+	s.throttle = utils.NewThrottle(uploadLimitKBps, windows)
+}
+
+// SetThrottle sets the global upload throttle, services that run at the same time share its bandwidth
+func (s *BackupService) SetThrottle(throttle *utils.Throttle) {
+	s.throttle = throttle
+}
+
+// SetLocking disables the repository lock or sets the age of a heartbeat after which a lock is stale (0 = default)
+func (s *BackupService) SetLocking(noLock bool, staleMinutes int64) {
+	if staleMinutes == 0 {
+		staleMinutes = config.DefaultLockStaleMinutes
 	}
+	s.noLock = noLock
+	s.lockStaleAfter = time.Duration(staleMinutes) * time.Minute
 }
 
+// ProcessBackup runs all tasks of the input file, the returned error is a *RunError if the run did not succeed
 func (s *BackupService) ProcessBackup(ctx context.Context, inputFile string, dryRun bool) error {
+	tasks, err := config.LoadTasks(inputFile)
+	if err != nil {
+		s.report = newRunReport("backup", dryRun)
+		return s.report.finish(fmt.Errorf("failed to load tasks: %w", err))
+	}
+	return s.ProcessTasks(ctx, tasks, inputFile, dryRun)
+}
+
+// ProcessTasks runs the given tasks of the input file, the returned error is a *RunError if the run did not succeed
+func (s *BackupService) ProcessTasks(ctx context.Context, tasks []config.Task, inputFile string, dryRun bool) error {
+	s.summary = &BackupSummary{}
+	s.report = newRunReport("backup", dryRun)
+	err := s.processBackup(ctx, tasks, inputFile, dryRun)
+
+	s.report.Totals = ReportTotals{
+		Succeeded: s.summary.SuccessfulUploads,
+		Failed:    s.summary.FailedUploads,
+		Skipped:   s.summary.SkippedFiles,
+		Warnings:  s.summary.Warnings,
+		Files:     s.summary.TotalFiles,
+		Bytes:     s.summary.TotalBytes,
+	}
+	return s.report.finish(err)
+}
+
+func (s *BackupService) processBackup(ctx context.Context, tasks []config.Task, inputFile string, dryRun bool) error {
 	startTime := time.Now()
 	
 	fmt.Printf("\nMODE: BACKUP\n")
@@ -50,11 +129,6 @@ func (s *BackupService) ProcessBackup(ctx context.Context, inputFile string, dry
 		fmt.Printf("REGION: %s\n\n", s.cfg.Region)
 	}
 
-	tasks, err := config.LoadTasks(inputFile)
-	if err != nil {
-		return fmt.Errorf("failed to load tasks: %w", err)
-	}
-
 	// Validate buckets exist before processing (skip in dry-run)
 	if !dryRun {
 		if err := s.validateBuckets(ctx, tasks); err != nil {
@@ -62,13 +136,19 @@ func (s *BackupService) ProcessBackup(ctx context.Context, inputFile string, dry
 		}
 	}
 
+	// Checked before any task runs, as tasks with different secrets cannot share the encrypted copy
+	configEncryptor, err := s.configCopyEncryptor(ctx, tasks)
+	if err != nil {
+		return err
+	}
+
 	for _, task := range tasks {
 		if err := s.processTask(ctx, task, dryRun); err != nil {
 			return fmt.Errorf("❌ failed to process task: %w", err)
 		}
 	}
 
-	if err := s.uploadAdditionalFiles(ctx, tasks, inputFile, dryRun); err != nil {
+	if err := s.uploadAdditionalFiles(ctx, tasks, inputFile, configEncryptor, dryRun); err != nil {
 		return err
 	}
 
@@ -78,30 +158,392 @@ func (s *BackupService) ProcessBackup(ctx context.Context, inputFile string, dry
 }
 
 func (s *BackupService) processTask(ctx context.Context, task config.Task, dryRun bool) error {
-	// Validate encryption password if provided
-	if err := utils.ValidateEncryptionPassword(task.EncryptionSecret); err != nil {
+	taskStart := time.Now()
+	s.currentTask = &TaskReport{
+		Bucket:       task.S3Bucket,
+		Prefix:       task.S3Prefix,
+		StorageClass: task.StorageClass,
+		Content:      task.Content,
+		Objects:      []*ObjectReport{},
+	}
+	s.report.Tasks = append(s.report.Tasks, s.currentTask)
+	s.destinations = s.taskDestinations(task)
+
+	err := s.runLockedTask(ctx, task, dryRun)
+
+	s.currentTask.DurationSeconds = durationSeconds(taskStart)
+	s.currentTask.Status = StatusSuccess
+	if err != nil {
+		s.currentTask.Status = StatusFailed
+		s.currentTask.Error = err.Error()
+	} else if failedErr := s.failedDestinations(); failedErr != nil {
+		s.currentTask.Status = StatusPartial
+		s.currentTask.Error = failedErr.Error()
+	}
+	return err
+}
+
+// taskDestinations returns the bucket of a task followed by its additional destinations,
+// each with the config of its credentials and region from the validation of the buckets
+func (s *BackupService) taskDestinations(task config.Task) []*destination {
+	var destinations []*destination
+	for _, target := range task.UploadDestinations() {
+		destinations = append(destinations, &destination{
+			bucket:       target.S3Bucket,
+			storageClass: config.ParseStorageClass(target.StorageClass),
+			cfg:          s.configs.Bucket(target.S3Bucket, target.Access()),
+		})
+	}
+	return destinations
+}
+
+// failDestination marks a destination as failed, the error is only returned if no other destination is left
+func (s *BackupService) failDestination(target *destination, err error) error {
+	target.err = err
+	for _, other := range s.destinations {
+		if other.err == nil {
+			slog.Warn(fmt.Sprintf("⚠️ Destination s3://%s failed, continuing with the other destinations: %v", target.bucket, err),
+				"event", utils.EventUpload, "bucket", target.bucket, "error", err)
+			s.summary.Warnings++
+			return nil
+		}
+	}
+	return err
+}
+
+// failedDestinations returns an error that lists the failed destinations of the current task, nil if there are none
+func (s *BackupService) failedDestinations() error {
+	var failed []string
+	for _, target := range s.destinations {
+		if target.err != nil {
+			failed = append(failed, fmt.Sprintf("s3://%s: %v", target.bucket, target.err))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("❌ upload to %d of %d destinations failed: %s", len(failed), len(s.destinations), strings.Join(failed, "; "))
+}
+
+// destinationLabel names the bucket in upload logs of tasks with several destinations
+func (s *BackupService) destinationLabel(target *destination) string {
+	if len(s.destinations) < 2 {
+		return ""
+	}
+	return " → s3://" + target.bucket
+}
+
+// runLockedTask runs a task while holding the lock of its prefix, so no other writer uploads to it at the same time
+func (s *BackupService) runLockedTask(ctx context.Context, task config.Task, dryRun bool) error {
+	if dryRun || s.noLock {
+		return s.runTaskWithHooks(ctx, task, dryRun)
+	}
+
+	// Every destination is locked, so no other writer uploads to any of them. The task runs under the
+	// context of the last lock, which is cancelled as soon as any of the locks is lost.
+	var locks []*utils.Lock
+	lockCtx := ctx
+	for _, target := range s.destinations {
+		lock, nextCtx, err := utils.AcquireLock(lockCtx, target.cfg, target.bucket, task.S3Prefix, "backup", s.lockStaleAfter)
+		if err != nil {
+			s.releaseLocks(ctx, locks)
+			return err
+		}
+		locks = append(locks, lock)
+		lockCtx = nextCtx
+	}
+
+	err := s.runTaskWithHooks(lockCtx, task, dryRun)
+	if cause := context.Cause(lockCtx); errors.Is(cause, utils.ErrLockLost) {
+		err = cause
+	}
+	s.releaseLocks(ctx, locks)
+	return err
+}
+
+// releaseLocks releases the locks of a task, failures are only warnings
+func (s *BackupService) releaseLocks(ctx context.Context, locks []*utils.Lock) {
+	for _, lock := range locks {
+		if releaseErr := lock.Release(ctx); releaseErr != nil {
+			slog.Warn(fmt.Sprintf("⚠️ %v", releaseErr), "event", utils.EventLock, "bucket", lock.Bucket(), "key", lock.Key(), "error", releaseErr)
+			s.summary.Warnings++
+		}
+	}
+}
+
+// runTaskWithHooks runs a task between its pre and post hooks
+func (s *BackupService) runTaskWithHooks(ctx context.Context, task config.Task, dryRun bool) error {
+	hooks := Hooks{
+		PreCommand:     task.PreCommand,
+		PostCommand:    task.PostCommand,
+		OnErrorCommand: task.OnErrorCommand,
+		Timeout:        time.Duration(task.HookTimeoutMinutes.Or(config.DefaultHookTimeoutMinutes)) * time.Minute,
+	}
+	env := map[string]string{
+		"MODE":    "backup",
+		"BUCKET":  task.S3Bucket,
+		"PREFIX":  task.S3Prefix,
+		"CONTENT": strings.Join(task.Content, string(os.PathListSeparator)),
+		"DRY_RUN": strconv.FormatBool(dryRun),
+	}
+
+	return runWithHooks(ctx, hooks, env, dryRun, func() error {
+		return s.runTask(ctx, task, dryRun)
+	})
+}
+
+func (s *BackupService) runTask(ctx context.Context, task config.Task, dryRun bool) error {
+	encryptor, secret, err := s.taskEncryptor(ctx, task)
+	if err != nil {
+		return err
+	}
+	s.encryptor = encryptor
+	if s.sse, err = taskServerSideEncryption(ctx, task); err != nil {
 		return err
 	}
+	if s.objectLock, err = taskObjectLock(task); err != nil {
+		return err
+	}
+	if err := s.setObjectNames(ctx, task, secret); err != nil {
+		return err
+	}
+
+	splitMB := task.ArchiveSplitEachMB.Or(config.DefaultArchiveSplitMB)
+	cleanupTmp := task.CleanupTmpStorage.Or(config.DefaultCleanupTmpStorage)
 
-	splitMB, err := config.ParseArchiveSplitMB(task.ArchiveSplitEachMB)
+	throttle, err := s.taskThrottle(task)
 	if err != nil {
 		return err
 	}
 
-	storageClass := config.ParseStorageClass(task.StorageClass)
-	cleanupTmp := config.ParseCleanupFlag(task.CleanupTmpStorage)
+	err = s.processSources(ctx, task, splitMB, cleanupTmp, throttle, dryRun)
+	if s.manifest != nil {
+		// Also record the objects uploaded before a failure
+		if manifestErr := s.uploadManifest(ctx, task.S3Prefix, throttle, dryRun); err == nil {
+			err = manifestErr
+		}
+	}
+	s.reportObjectLocks(ctx, s.currentTask.Objects)
+	return err
+}
 
+// reportObjectLocks reports the retention and legal hold S3 returns for the uploaded and skipped objects of a task
+// with Object Lock instead of its settings, skipped objects may have been uploaded with other settings
+func (s *BackupService) reportObjectLocks(ctx context.Context, objects []*ObjectReport) {
+	if s.objectLock == nil {
+		return
+	}
+	for _, object := range objects {
+		if object.Status != ObjectUploaded && object.Status != ObjectSkipped {
+			continue
+		}
+		target := s.destinations[0]
+		for _, other := range s.destinations {
+			if other.bucket == object.Bucket {
+				target = other
+			}
+		}
+		lock, err := utils.GetObjectLock(ctx, target.cfg, object.Bucket, object.Key, s.sse)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ Failed to read the Object Lock of %s: %v", object.Key, err),
+				"event", utils.EventUpload, "bucket", object.Bucket, "key", object.Key, "error", err)
+			object.ObjectLock = "unknown"
+			continue
+		}
+		object.ObjectLock = lock.String()
+	}
+}
+
+// processSources uploads the content paths and streams of a task
+func (s *BackupService) processSources(ctx context.Context, task config.Task, splitMB int64, cleanupTmp bool, throttle *utils.Throttle, dryRun bool) error {
 	for _, contentPath := range task.Content {
-		if err := s.processContent(ctx, task, contentPath, splitMB, storageClass, cleanupTmp, dryRun); err != nil {
+		if err := s.processContent(ctx, task, contentPath, splitMB, cleanupTmp, throttle, dryRun); err != nil {
 			s.summary.FailedUploads++
 			return fmt.Errorf("failed to process content %s: %w", contentPath, err)
 		}
 	}
 
+	for _, stream := range task.Streams {
+		if err := s.processStream(ctx, task, stream, splitMB, cleanupTmp, throttle, dryRun); err != nil {
+			s.summary.FailedUploads++
+			return fmt.Errorf("failed to process stream %s: %w", stream.ObjectName, err)
+		}
+	}
+
+	return nil
+}
+
+// taskEncryptor returns the encryptor for the recipients or the resolved and validated secret of a task
+// and the resolved secret, nil without encryption
+func (s *BackupService) taskEncryptor(ctx context.Context, task config.Task) (*utils.Encryptor, string, error) {
+	if len(task.Recipients) > 0 {
+		recipients, err := utils.ParseRecipients(task.Recipients)
+		if err != nil {
+			return nil, "", err
+		}
+		return utils.NewRecipientEncryptor(recipients), "", nil
+	}
+
+	secret, err := utils.ResolveSecret(ctx, task.EncryptionSecret)
+	if err != nil {
+		return nil, "", err
+	}
+	if secret == "" {
+		return nil, "", nil
+	}
+	if err := utils.ValidateEncryptionPassword(secret); err != nil {
+		return nil, "", err
+	}
+	kdf, err := utils.DefaultKDF(task.KDF)
+	if err != nil {
+		return nil, "", err
+	}
+	return utils.NewPasswordEncryptor(secret, task.EncryptionKeyID, kdf), secret, nil
+}
+
+// taskServerSideEncryption returns the S3 encryption of the objects of a task, nil for the bucket default
+func taskServerSideEncryption(ctx context.Context, task config.Task) (*utils.ServerSideEncryption, error) {
+	return utils.NewServerSideEncryption(ctx, task.ServerSideEncryption, task.SSEKMSKeyID, task.SSEBucketKey.Or(false), task.SSECustomerKey)
+}
+
+// taskObjectLock returns the retention and legal hold of the objects of a task, nil without Object Lock
+func taskObjectLock(task config.Task) (*utils.ObjectLock, error) {
+	return utils.NewObjectLock(task.ObjectLockMode, task.ObjectLockRetentionDays.Or(0), task.ObjectLockRetainUntil, task.ObjectLockLegalHold.Or(false))
+}
+
+// setObjectNames prepares the obfuscated object keys and the manifest of a task with ObfuscateObjectKeys
+func (s *BackupService) setObjectNames(ctx context.Context, task config.Task, secret string) error {
+	s.namer, s.manifest = nil, nil
+	if !task.ObfuscateObjectKeys.Or(false) {
+		return nil
+	}
+
+	key, err := s.nameKey(ctx, task, secret)
+	if err != nil {
+		return err
+	}
+	s.namer = utils.NewObjectNamer(key, task.S3Prefix)
+	s.manifest = utils.NewManifest()
+	s.manifest.NameKey = key
+	return nil
+}
+
+// nameKey returns the name key of the latest manifest in the first destination, so existing objects keep their
+// names. The first run of a prefix creates a random key, manifests without a key derive it from the secret.
+func (s *BackupService) nameKey(ctx context.Context, task config.Task, secret string) ([]byte, error) {
+	primary := s.destinations[0]
+	objects, err := utils.ListObjects(ctx, primary.cfg, primary.bucket, utils.ManifestKeyPrefix(task.S3Prefix))
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to list the manifests of s3://%s/%s: %w", primary.bucket, task.S3Prefix, err)
+	}
+	var latest string
+	for _, obj := range objects {
+		if key := aws.ToString(obj.Key); utils.IsManifestKey(key) && key > latest {
+			latest = key
+		}
+	}
+	if latest == "" {
+		return utils.NewNameKey()
+	}
+
+	data, err := utils.GetObjectData(ctx, primary.cfg, primary.bucket, latest, s.sse, nil)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to download manifest %s: %w", latest, err)
+	}
+	manifest, err := utils.DecryptManifest(data, utils.DecryptionKeys{Passwords: []string{secret}})
+	if err != nil {
+		// A new secret without rekey starts new names, as the objects are encrypted under the new secret anyway
+		slog.Warn(fmt.Sprintf("⚠️ Manifest %s does not decrypt with the secret of the task, objects are uploaded under new names: %v", latest, err),
+			"event", utils.EventDecrypt, "bucket", primary.bucket, "key", latest, "error", err)
+		return utils.NewNameKey()
+	}
+	if len(manifest.NameKey) > 0 {
+		return manifest.NameKey, nil
+	}
+
+	kdf, err := utils.DefaultKDF(task.KDF)
+	if err != nil {
+		return nil, err
+	}
+	return utils.DeriveNameKey(secret, kdf, task.S3Bucket, task.S3Prefix)
+}
+
+// objectKey returns the key an object is stored under, obfuscated keys are recorded in the manifest of the run
+func (s *BackupService) objectKey(key string) string {
+	if s.namer == nil {
+		return key
+	}
+	obfuscated := s.namer.ObjectKey(key)
+	s.manifest.Objects[obfuscated] = key
+	return obfuscated
+}
+
+// uploadManifest uploads the encrypted manifest with the original keys of the obfuscated objects of a task run
+// to every destination that has not failed
+func (s *BackupService) uploadManifest(ctx context.Context, prefix string, throttle *utils.Throttle, dryRun bool) error {
+	if len(s.manifest.Objects) == 0 {
+		return nil
+	}
+	key := utils.ManifestKey(prefix, s.manifest.Created)
+	data, err := utils.EncryptManifest(s.manifest, s.encryptor)
+	if err != nil {
+		return fmt.Errorf("❌ failed to encrypt manifest: %w", err)
+	}
+
+	for _, target := range s.destinations {
+		if target.err != nil {
+			continue
+		}
+		if err := s.putManifest(ctx, target, key, data, throttle, dryRun); err != nil {
+			if err := s.failDestination(target, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// putManifest uploads the encrypted manifest to a destination
+func (s *BackupService) putManifest(ctx context.Context, target *destination, key string, data []byte, throttle *utils.Throttle, dryRun bool) error {
+	bucket := target.bucket
+	size := int64(len(data))
+	s.summary.TotalFiles++
+	objectStart := time.Now()
+
+	if dryRun {
+		log.Printf("⬆️  [DRY-RUN] Would upload manifest of %d objects to s3://%s/%s", len(s.manifest.Objects), bucket, key)
+		s.summary.SuccessfulUploads++
+		s.recordObject(bucket, key, "", size, ObjectDryRun, objectStart, nil)
+		return nil
+	}
+
+	err := utils.RetryWithBackoff(ctx, func() error {
+		return utils.PutObjectData(ctx, target.cfg, bucket, key, data, types.StorageClassStandard, s.sse, s.objectLock, throttle)
+	}, fmt.Sprintf("Upload manifest %s", key))
+	if err != nil {
+		s.summary.FailedUploads++
+		s.recordObject(bucket, key, "", size, ObjectFailed, objectStart, err)
+		return fmt.Errorf("❌ failed to upload manifest %s: %w", key, err)
+	}
+	slog.Info(fmt.Sprintf("🗺️ Manifest uploaded: %s (%d objects)%s", key, len(s.manifest.Objects), s.destinationLabel(target)),
+		"event", utils.EventUpload, "bucket", bucket, "key", key, "size", size, "status", "success")
+	s.summary.SuccessfulUploads++
+	s.summary.TotalBytes += size
+	s.recordObject(bucket, key, "", size, ObjectUploaded, objectStart, nil)
 	return nil
 }
 
-func (s *BackupService) processContent(ctx context.Context, task config.Task, contentPath string, splitMB int64, storageClass types.StorageClass, cleanupTmp bool, dryRun bool) error {
+// taskThrottle builds the upload throttle for a task below the global one, so the lower bandwidth limit wins
+// and the global limit is shared with other tasks. Task windows replace global ones.
+func (s *BackupService) taskThrottle(task config.Task) (*utils.Throttle, error) {
+	windows, err := utils.ParseTransferWindows(task.TransferWindow)
+	if err != nil {
+		return nil, err
+	}
+	return s.throttle.Chain(task.UploadLimitKBps.Or(0), windows), nil
+}
+
+func (s *BackupService) processContent(ctx context.Context, task config.Task, contentPath string, splitMB int64, cleanupTmp bool, throttle *utils.Throttle, dryRun bool) error {
 	prepStart := time.Now()
 	
 	if err := os.MkdirAll(task.TmpStorageToBuildArchives, os.ModePerm); err != nil {
@@ -116,7 +558,7 @@ func (s *BackupService) processContent(ctx context.Context, task config.Task, co
 		return fmt.Errorf("failed to build archive: %w", err)
 	}
 
-	parts, err := s.prepareParts(fullArchivePath, splitMB, task.EncryptionSecret)
+	parts, err := s.prepareParts(fullArchivePath, splitMB, s.encryptor)
 	if err != nil {
 		return fmt.Errorf("failed to prepare parts: %w", err)
 	}
@@ -126,7 +568,54 @@ func (s *BackupService) processContent(ctx context.Context, task config.Task, co
 	// Track preparation time
 	s.summary.PreparationTime += time.Since(prepStart)
 	
-	if err := s.uploadParts(ctx, parts, task.S3Bucket, s3Path, storageClass, dryRun); err != nil {
+	if err := s.uploadParts(ctx, parts, s3Path, throttle, dryRun); err != nil {
+		return fmt.Errorf("failed to upload parts: %w", err)
+	}
+
+	if dryRun {
+		if cleanupTmp {
+			log.Printf("🧽 [DRY-RUN] Skipping cleanup of temporary files - files kept for inspection")
+		}
+	} else if cleanupTmp {
+		s.cleanupFiles(parts)
+	}
+
+	return nil
+}
+
+// processStream compresses a command output or stdin stream and uploads it like an archive
+func (s *BackupService) processStream(ctx context.Context, task config.Task, stream config.StreamSource, splitMB int64, cleanupTmp bool, throttle *utils.Throttle, dryRun bool) error {
+	archivePath := filepath.Join(task.TmpStorageToBuildArchives, path.Base(utils.NormalizePath(stream.ObjectName))) + "." + config.StreamExtension
+	if dryRun {
+		// Commands can have side effects and stdin can only be read once, a dry-run does neither
+		source := "stdin"
+		if !strings.EqualFold(stream.Type, config.StreamStdin) {
+			source = fmt.Sprintf("command '%s'", stream.Command)
+		}
+		log.Printf("🌊 [DRY-RUN] Would run %s and upload its output to s3://%s/%s%s", source, task.S3Bucket,
+			s.buildStreamS3Path(task, stream.ObjectName), filepath.Base(archivePath))
+		return nil
+	}
+
+	prepStart := time.Now()
+
+	if err := os.MkdirAll(task.TmpStorageToBuildArchives, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	if _, err := utils.CompressStreamSource(ctx, stream, archivePath); err != nil {
+		os.Remove(archivePath)
+		return err
+	}
+
+	parts, err := s.prepareParts(archivePath, splitMB, s.encryptor)
+	if err != nil {
+		return fmt.Errorf("failed to prepare parts: %w", err)
+	}
+
+	s.summary.PreparationTime += time.Since(prepStart)
+
+	if err := s.uploadParts(ctx, parts, s.buildStreamS3Path(task, stream.ObjectName), throttle, dryRun); err != nil {
 		return fmt.Errorf("failed to upload parts: %w", err)
 	}
 
@@ -141,7 +630,7 @@ func (s *BackupService) processContent(ctx context.Context, task config.Task, co
 	return nil
 }
 
-func (s *BackupService) prepareParts(archivePath string, splitMB int64, encryptionSecret string) ([]string, error) {
+func (s *BackupService) prepareParts(archivePath string, splitMB int64, encryptor *utils.Encryptor) ([]string, error) {
 	parts, err := utils.SplitFile(archivePath, splitMB)
 	if err != nil {
 		return nil, err
@@ -149,26 +638,25 @@ func (s *BackupService) prepareParts(archivePath string, splitMB int64, encrypti
 
 	if len(parts) > 1 {
 		os.Remove(archivePath)
-		// Create simple how-to file
-		howToFile := archivePath + "-HowToBuild.txt"
-		err := os.WriteFile(howToFile, []byte("Use 'cat parts > combined' to rebuild"), 0644)
+		// Create simple how-to file, restores check the number of parts against it
+		howToFile, err := utils.WriteHowToBuild(archivePath, len(parts))
 		if err != nil {
-			return nil, fmt.Errorf("failed to create how-to file: %w", err)
+			return nil, err
 		}
 		parts = append(parts, howToFile)
 	}
 
-	if encryptionSecret != "" {
-		return s.encryptParts(parts, encryptionSecret)
+	if encryptor != nil {
+		return s.encryptParts(parts, encryptor)
 	}
 
 	return parts, nil
 }
 
-func (s *BackupService) encryptParts(parts []string, secret string) ([]string, error) {
+func (s *BackupService) encryptParts(parts []string, encryptor *utils.Encryptor) ([]string, error) {
 	var encryptedParts []string
 	for _, part := range parts {
-		encryptedFile, err := utils.EncryptFile(part, secret)
+		encryptedFile, err := encryptor.EncryptFile(part)
 		if err != nil {
 			return nil, fmt.Errorf("failed to encrypt %s: %w", part, err)
 		}
@@ -188,7 +676,23 @@ func (s *BackupService) buildS3Path(task config.Task, contentPath string) string
 	return utils.NormalizePath(task.S3Prefix) + "/" + trimmedPath
 }
 
-func (s *BackupService) uploadParts(ctx context.Context, parts []string, bucket, s3Path string, storageClass types.StorageClass, dryRun bool) error {
+// buildStreamS3Path returns the S3 path (with trailing slash) for a stream object name below the task prefix
+func (s *BackupService) buildStreamS3Path(task config.Task, objectName string) string {
+	var elements []string
+	if task.S3Prefix != "" {
+		elements = append(elements, utils.NormalizePath(task.S3Prefix))
+	}
+	if dir := path.Dir(utils.NormalizePath(objectName)); dir != "." && dir != "/" {
+		elements = append(elements, strings.Trim(dir, "/"))
+	}
+
+	if len(elements) == 0 {
+		return ""
+	}
+	return strings.Join(elements, "/") + "/"
+}
+
+func (s *BackupService) uploadParts(ctx context.Context, parts []string, s3Path string, throttle *utils.Throttle, dryRun bool) error {
 	uploadStart := time.Now()
 	defer func() {
 		s.summary.UploadTime += time.Since(uploadStart)
@@ -203,61 +707,143 @@ func (s *BackupService) uploadParts(ctx context.Context, parts []string, bucket,
 		if err != nil {
 			return fmt.Errorf("failed to get size: %w", err)
 		}
-		sizeFloat := float64(size) / (1024 * 1024) // MB
-		unit := "MB"
 
-		s3Key := s3Path + filepath.Base(part)
-		s.summary.TotalFiles++
-		
-		// Check if object already exists in S3 - REQUIRED for safety
-		if !dryRun {
-			var exists bool
-			err := utils.RetryWithBackoff(ctx, func() error {
-				var checkErr error
-				exists, checkErr = utils.CheckObjectExists(ctx, s.cfg, bucket, s3Key)
-				return checkErr
-			}, fmt.Sprintf("Check existence of %s", s3Key))
-			
-			if err != nil {
-				s.summary.FailedUploads++
-				return fmt.Errorf("❌ Cannot verify object existence for %s: %w. Upload aborted to prevent overwriting existing data", s3Key, err)
-			}
-			if exists {
-				log.Printf("⏭️ Skipping (%d/%d): %s (already exists in S3)", i+1, len(parts), filepath.Base(part))
-				s.summary.SkippedFiles++
+		// The part is built once and uploaded to every destination that has not failed yet
+		s3Key := s.objectKey(s3Path + filepath.Base(part))
+		for _, target := range s.destinations {
+			if target.err != nil {
 				continue
 			}
+			if err := s.uploadPart(ctx, target, part, s3Key, size, fmt.Sprintf("%d/%d", i+1, len(parts)), throttle, dryRun); err != nil {
+				if err := s.failDestination(target, err); err != nil {
+					return err
+				}
+			}
 		}
+	}
+	return nil
+}
+
+// uploadPart uploads a part to a destination unless it already exists there
+func (s *BackupService) uploadPart(ctx context.Context, target *destination, part, s3Key string, size int64, position string, throttle *utils.Throttle, dryRun bool) error {
+	bucket := target.bucket
+	sizeFloat := float64(size) / (1024 * 1024) // MB
+	unit := "MB"
+	s.summary.TotalFiles++
+	objectStart := time.Now()
+	
+	// Check if object already exists in S3 - REQUIRED for safety
+	if !dryRun {
+		var exists bool
+		err := utils.RetryWithBackoff(ctx, func() error {
+			var checkErr error
+			exists, checkErr = utils.CheckObjectExists(ctx, target.cfg, bucket, s3Key, s.sse)
+			return checkErr
+		}, fmt.Sprintf("Check existence of %s", s3Key))
 		
-		s.summary.TotalBytes += size
-		if dryRun {
-			log.Printf("⬆️  [DRY-RUN] Would upload (%d/%d): %s (%.2f %s) to s3://%s/%s", i+1, len(parts), part, sizeFloat, unit, bucket, s3Key)
-			s.summary.SuccessfulUploads++
-		} else {
-			log.Printf("⬆️ Uploading (%d/%d): %s (%.2f %s)", i+1, len(parts), part, sizeFloat, unit)
-			
-			// Retry upload with exponential backoff for network errors
-			err := utils.RetryWithBackoff(ctx, func() error {
-				return utils.UploadFile(ctx, s.cfg, part, bucket, s3Key, storageClass)
-			}, fmt.Sprintf("Upload %s", filepath.Base(part)))
-			
-			if err != nil {
-				s.summary.FailedUploads++
-				return fmt.Errorf("❌ failed to upload %s: %w", part, err)
-			}
-			log.Printf("✅ Upload successful: %s", filepath.Base(part))
-			s.summary.SuccessfulUploads++
+		if err != nil {
+			s.summary.FailedUploads++
+			s.recordObject(bucket, s3Key, part, size, ObjectFailed, objectStart, err)
+			return fmt.Errorf("❌ Cannot verify object existence for %s: %w. Upload aborted to prevent overwriting existing data", s3Key, err)
 		}
+		if exists {
+			slog.Info(fmt.Sprintf("⏭️ Skipping (%s): %s%s (already exists in S3)", position, filepath.Base(part), s.destinationLabel(target)),
+				"event", utils.EventSkip, "bucket", bucket, "key", s3Key, "size", size)
+			s.summary.SkippedFiles++
+			s.recordObject(bucket, s3Key, part, size, ObjectSkipped, objectStart, nil)
+			return nil
+		}
+	}
+	
+	s.summary.TotalBytes += size
+	if dryRun {
+		slog.Info(fmt.Sprintf("⬆️  [DRY-RUN] Would upload (%s): %s (%.2f %s) to s3://%s/%s", position, part, sizeFloat, unit, bucket, s3Key),
+			"event", utils.EventUpload, "bucket", bucket, "key", s3Key, "size", size, "dryRun", true)
+		s.summary.SuccessfulUploads++
+		s.recordObject(bucket, s3Key, part, size, ObjectDryRun, objectStart, nil)
+		return nil
 	}
+
+	slog.Info(fmt.Sprintf("⬆️ Uploading (%s): %s (%.2f %s)%s", position, part, sizeFloat, unit, s.destinationLabel(target)),
+		"event", utils.EventUpload, "bucket", bucket, "key", s3Key, "size", size, "storageClass", target.storageClass, "sse", s.sse.String())
+	
+	// Retry upload with exponential backoff for network errors
+	err := utils.RetryWithBackoff(ctx, func() error {
+		return utils.UploadFile(ctx, target.cfg, part, bucket, s3Key, target.storageClass, s.sse, s.objectLock, throttle)
+	}, fmt.Sprintf("Upload %s", filepath.Base(part)))
+	
+	if err != nil {
+		s.summary.FailedUploads++
+		slog.Error(fmt.Sprintf("❌ Upload failed: %s%s", filepath.Base(part), s.destinationLabel(target)),
+			"event", utils.EventUpload, "bucket", bucket, "key", s3Key, "size", size, "error", err)
+		s.recordObject(bucket, s3Key, part, size, ObjectFailed, objectStart, err)
+		return fmt.Errorf("❌ failed to upload %s: %w", part, err)
+	}
+	slog.Info(fmt.Sprintf("✅ Upload successful: %s%s", filepath.Base(part), s.destinationLabel(target)),
+		"event", utils.EventUpload, "bucket", bucket, "key", s3Key, "size", size, "status", "success")
+	s.summary.SuccessfulUploads++
+	s.recordObject(bucket, s3Key, part, size, ObjectUploaded, objectStart, nil)
 	return nil
 }
 
-func (s *BackupService) uploadAdditionalFiles(ctx context.Context, tasks []config.Task, inputFile string, dryRun bool) error {
+// recordObject adds an object result to the current task report (or the run report for additional files)
+func (s *BackupService) recordObject(bucket, key, file string, size int64, status string, start time.Time, err error) {
+	object := &ObjectReport{
+		Bucket:          bucket,
+		Key:             key,
+		File:            file,
+		Size:            size,
+		Status:          status,
+		Error:           errorString(err),
+		DurationSeconds: durationSeconds(start),
+	}
+	if s.objectLock != nil && (status == ObjectUploaded || status == ObjectDryRun) {
+		object.ObjectLock = s.objectLock.String()
+	}
+	recordObjectMetrics(s.report.Mode, status, size)
+	s.report.destination(bucket).record(status, size, err)
+
+	if s.currentTask == nil {
+		s.report.Objects = append(s.report.Objects, object)
+		return
+	}
+	s.currentTask.Objects = append(s.currentTask.Objects, object)
+	if status == ObjectUploaded || status == ObjectDryRun {
+		s.currentTask.Bytes += size
+	}
+}
+
+// configCopyEncryptor returns the encryptor of the copy of the input file, nil if no task obfuscates its object keys.
+// The copy lists all content paths, so it is encrypted if any task hides them, all such tasks must use the same secret.
+func (s *BackupService) configCopyEncryptor(ctx context.Context, tasks []config.Task) (*utils.Encryptor, error) {
+	var encryptor *utils.Encryptor
+	var secret string
+	var first int
+	for i, task := range tasks {
+		if !task.ObfuscateObjectKeys.Or(false) {
+			continue
+		}
+		taskEncryptor, taskSecret, err := s.taskEncryptor(ctx, task)
+		if err != nil {
+			return nil, err
+		}
+		if encryptor == nil {
+			encryptor, secret, first = taskEncryptor, taskSecret, i
+		} else if taskSecret != secret {
+			return nil, fmt.Errorf("❌ tasks %d and %d obfuscate object keys with different secrets, the copy of the input file can only be encrypted with one of them", first+1, i+1)
+		}
+	}
+	return encryptor, nil
+}
+
+func (s *BackupService) uploadAdditionalFiles(ctx context.Context, tasks []config.Task, inputFile string, configEncryptor *utils.Encryptor, dryRun bool) error {
 	if len(tasks) == 0 {
 		return nil
 	}
 
-	bucket := tasks[0].S3Bucket
+	// The copy goes to every destination of the first task
+	s.currentTask = nil
+	s.destinations = s.taskDestinations(tasks[0])
 	var prefix string
 	if tasks[0].S3Prefix == "" {
 		prefix = ""
@@ -272,6 +858,30 @@ func (s *BackupService) uploadAdditionalFiles(ctx context.Context, tasks []confi
 	}
 	defer os.Remove(sanitizedFile)
 
+	// The copy is stored with the server-side encryption and Object Lock of the first task
+	if s.sse, err = taskServerSideEncryption(ctx, tasks[0]); err != nil {
+		return err
+	}
+	if s.objectLock, err = taskObjectLock(tasks[0]); err != nil {
+		return err
+	}
+
+	// The copy lists all content paths, so it is encrypted if any task hides its object keys
+	configName := filepath.Base(inputFile)
+	if configEncryptor != nil {
+		if sanitizedFile, err = configEncryptor.EncryptFile(sanitizedFile); err != nil {
+			return fmt.Errorf("failed to encrypt input file copy: %w", err)
+		}
+		defer os.Remove(sanitizedFile)
+		configName += "." + config.EncryptionExt
+	}
+
+	// The copy is uploaded within the limits of the first task
+	throttle, err := s.taskThrottle(tasks[0])
+	if err != nil {
+		return err
+	}
+
 	files := []string{sanitizedFile}
 
 	for _, file := range files {
@@ -285,51 +895,74 @@ func (s *BackupService) uploadAdditionalFiles(ctx context.Context, tasks []confi
 			return fmt.Errorf("failed to get file size for %s: %w", file, err)
 		}
 
-		s3Key := prefix + filepath.Base(inputFile) // Use original filename for S3 key
-		s.summary.TotalFiles++
-		
-		// Check if additional file already exists in S3 - REQUIRED for safety
-		if !dryRun {
-			var exists bool
-			err := utils.RetryWithBackoff(ctx, func() error {
-				var checkErr error
-				exists, checkErr = utils.CheckObjectExists(ctx, s.cfg, bucket, s3Key)
-				return checkErr
-			}, fmt.Sprintf("Check existence of %s", s3Key))
-			
-			if err != nil {
-				s.summary.FailedUploads++
-				return fmt.Errorf("❌ Cannot verify object existence for %s: %w. Upload aborted to prevent overwriting existing data", s3Key, err)
-			}
-			if exists {
-				log.Printf("⏭️ Skipping additional file: %s (already exists in S3)", filepath.Base(inputFile))
-				s.summary.SkippedFiles++
-				continue
+		s3Key := prefix + configName // Use original filename for S3 key
+		for _, target := range s.destinations {
+			if err := s.uploadAdditionalFile(ctx, target, file, inputFile, s3Key, size, throttle, dryRun); err != nil {
+				if err := s.failDestination(target, err); err != nil {
+					return err
+				}
 			}
 		}
+	}
+	s.reportObjectLocks(ctx, s.report.Objects)
+
+	log.Println("Additional files uploaded successfully")
+	return nil
+}
+
+// uploadAdditionalFile uploads the copy of the input file to a destination unless it already exists there
+func (s *BackupService) uploadAdditionalFile(ctx context.Context, target *destination, file, inputFile, s3Key string, size int64, throttle *utils.Throttle, dryRun bool) error {
+	bucket := target.bucket
+	s.summary.TotalFiles++
+	objectStart := time.Now()
+	
+	// Check if additional file already exists in S3 - REQUIRED for safety
+	if !dryRun {
+		var exists bool
+		err := utils.RetryWithBackoff(ctx, func() error {
+			var checkErr error
+			exists, checkErr = utils.CheckObjectExists(ctx, target.cfg, bucket, s3Key, s.sse)
+			return checkErr
+		}, fmt.Sprintf("Check existence of %s", s3Key))
 		
-		s.summary.TotalBytes += size
-		if dryRun {
-			log.Printf("⬆️  [DRY-RUN] Would upload additional file: %s to s3://%s/%s", filepath.Base(inputFile), bucket, s3Key)
-			s.summary.SuccessfulUploads++
-		} else {
-			log.Printf("⬆️ Uploading additional file: %s", filepath.Base(inputFile))
-			
-			// Retry upload with exponential backoff for network errors
-			err := utils.RetryWithBackoff(ctx, func() error {
-				return utils.UploadFile(ctx, s.cfg, file, bucket, s3Key, types.StorageClassStandard)
-			}, fmt.Sprintf("Upload additional file %s", filepath.Base(inputFile)))
-			
-			if err != nil {
-				s.summary.FailedUploads++
-				return fmt.Errorf("❌ failed to upload additional file %s: %w", filepath.Base(inputFile), err)
-			}
-			log.Printf("✅ Additional file uploaded: %s", filepath.Base(inputFile))
-			s.summary.SuccessfulUploads++
+		if err != nil {
+			s.summary.FailedUploads++
+			s.recordObject(bucket, s3Key, inputFile, size, ObjectFailed, objectStart, err)
+			return fmt.Errorf("❌ Cannot verify object existence for %s: %w. Upload aborted to prevent overwriting existing data", s3Key, err)
 		}
+		if exists {
+			slog.Info(fmt.Sprintf("⏭️ Skipping additional file: %s%s (already exists in S3)", filepath.Base(inputFile), s.destinationLabel(target)),
+				"event", utils.EventSkip, "bucket", bucket, "key", s3Key, "size", size)
+			s.summary.SkippedFiles++
+			s.recordObject(bucket, s3Key, inputFile, size, ObjectSkipped, objectStart, nil)
+			return nil
+		}
+	}
+	
+	s.summary.TotalBytes += size
+	if dryRun {
+		log.Printf("⬆️  [DRY-RUN] Would upload additional file: %s to s3://%s/%s", filepath.Base(inputFile), bucket, s3Key)
+		s.summary.SuccessfulUploads++
+		s.recordObject(bucket, s3Key, inputFile, size, ObjectDryRun, objectStart, nil)
+		return nil
 	}
 
-	log.Println("Additional files uploaded successfully")
+	slog.Info(fmt.Sprintf("⬆️ Uploading additional file: %s%s", filepath.Base(inputFile), s.destinationLabel(target)),
+		"event", utils.EventUpload, "bucket", bucket, "key", s3Key, "size", size)
+	
+	// Retry upload with exponential backoff for network errors
+	err := utils.RetryWithBackoff(ctx, func() error {
+		return utils.UploadFile(ctx, target.cfg, file, bucket, s3Key, types.StorageClassStandard, s.sse, s.objectLock, throttle)
+	}, fmt.Sprintf("Upload additional file %s", filepath.Base(inputFile)))
+	
+	if err != nil {
+		s.summary.FailedUploads++
+		s.recordObject(bucket, s3Key, inputFile, size, ObjectFailed, objectStart, err)
+		return fmt.Errorf("❌ failed to upload additional file %s: %w", filepath.Base(inputFile), err)
+	}
+	log.Printf("✅ Additional file uploaded: %s%s", filepath.Base(inputFile), s.destinationLabel(target))
+	s.summary.SuccessfulUploads++
+	s.recordObject(bucket, s3Key, inputFile, size, ObjectUploaded, objectStart, nil)
 	return nil
 }
 
@@ -339,49 +972,146 @@ func (s *BackupService) cleanupFiles(files []string) {
 	}
 }
 
+// validateBuckets checks every bucket with the credentials of its task and caches the config it is accessed with
 func (s *BackupService) validateBuckets(ctx context.Context, tasks []config.Task) error {
-	buckets := make(map[string]bool)
+	// Buckets and credentials settings mapped to whether a task uploads with Object Lock
+	type bucketAccess struct {
+		bucket string
+		access config.Access
+	}
+	var order []bucketAccess
+	buckets := make(map[bucketAccess]bool)
 	for _, task := range tasks {
-		buckets[task.S3Bucket] = true
+		for _, target := range task.UploadDestinations() {
+			key := bucketAccess{target.S3Bucket, target.Access()}
+			if _, found := buckets[key]; !found {
+				order = append(order, key)
+			}
+			buckets[key] = buckets[key] || task.UsesObjectLock()
+			if utils.CustomS3Endpoint() && config.ParseStorageClass(target.StorageClass) != types.StorageClassStandard {
+				slog.Warn(fmt.Sprintf("⚠️ Storage class %s of s3://%s/%s may not be supported by the S3 endpoint, use STANDARD", target.StorageClass, target.S3Bucket, task.S3Prefix),
+					"event", utils.EventUpload, "bucket", target.S3Bucket, "prefix", task.S3Prefix, "storageClass", target.StorageClass)
+			}
+		}
 	}
 
-	for bucket := range buckets {
-		region, updatedCfg, err := utils.ValidateBucketExistsWithRegion(ctx, s.cfg, bucket)
+	for _, key := range order {
+		cfg, err := s.configs.Session(ctx, key.access)
+		if err != nil {
+			return fmt.Errorf("❌ credentials for S3 bucket '%s': %w", key.bucket, err)
+		}
+		objectLock := buckets[key]
+		region, bucketCfg, err := utils.ValidateBucketExistsWithRegion(ctx, cfg, key.bucket, objectLock)
 		if err != nil {
-			return fmt.Errorf("❌ S3 bucket '%s' does not exist or is not accessible: %w", bucket, err)
+			return fmt.Errorf("❌ S3 bucket '%s' does not exist or is not accessible: %w", key.bucket, err)
 		}
-		// Update service config with correct region
-		s.cfg = updatedCfg
-		regionInfo := utils.GetRegionInfo(region)
-		gdprStatus := "⚠️  Non-GDPR"
-		if regionInfo.GDPRCompliant {
-			gdprStatus = "🔒 GDPR"
+		if err := s.validateObjectLock(ctx, bucketCfg, key.bucket, objectLock); err != nil {
+			return err
 		}
-		log.Printf("✅ S3 bucket validated: %s (region: %s %s %s %s)", bucket, region, regionInfo.Flag, regionInfo.Country, gdprStatus)
+		s.configs.SetBucket(key.bucket, key.access, bucketCfg)
+		log.Printf("✅ S3 bucket validated: %s (%s)%s", key.bucket, utils.RegionDescription(region), describeAccess(key.access))
+	}
+	return nil
+}
+
+// describeAccess names the profile and role a bucket is accessed with, empty for the credentials of the run
+func describeAccess(access config.Access) string {
+	var parts []string
+	if access.Profile != "" {
+		parts = append(parts, "profile: "+access.Profile)
+	}
+	if access.RoleArn != "" {
+		parts = append(parts, "role: "+access.RoleArn)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(parts, ", ") + "]"
+}
+
+// validateObjectLock checks that Object Lock is enabled for a bucket that tasks upload to with retention or legal hold
+func (s *BackupService) validateObjectLock(ctx context.Context, cfg aws.Config, bucket string, objectLock bool) error {
+	if !objectLock {
+		return nil
+	}
+	enabled, err := utils.BucketObjectLockEnabled(ctx, cfg, bucket)
+	if err != nil {
+		return fmt.Errorf("❌ S3 bucket '%s': %w", bucket, err)
+	}
+	if !enabled {
+		return fmt.Errorf("❌ S3 bucket '%s' has no Object Lock, which ObjectLockMode and ObjectLockLegalHold require (it can only be enabled when a bucket is created)", bucket)
 	}
 	return nil
 }
 
 func (s *BackupService) createSanitizedInputFile(inputFile string, tasks []config.Task) (string, error) {
-	// Create sanitized tasks with empty encryption secrets
+	// Create sanitized tasks with empty secrets, plain: values are removed like literal ones
 	sanitizedTasks := make([]config.Task, len(tasks))
 	for i, task := range tasks {
 		sanitizedTasks[i] = task
-		sanitizedTasks[i].EncryptionSecret = "" // Remove encryption secret
+		if !utils.IsIndirectSecret(task.EncryptionSecret) {
+			sanitizedTasks[i].EncryptionSecret = "" // Remove encryption secret, indirect references are kept
+		}
+		if !utils.IsIndirectSecret(task.SSECustomerKey) {
+			sanitizedTasks[i].SSECustomerKey = ""
+		}
+		if !utils.IsIndirectSecret(task.MFAToken) {
+			sanitizedTasks[i].MFAToken = ""
+		}
+		// Copy the destinations, they are shared with the task
+		sanitizedTasks[i].Destinations = slices.Clone(task.Destinations)
+		for j, destination := range task.Destinations {
+			if !utils.IsIndirectSecret(destination.MFAToken) {
+				sanitizedTasks[i].Destinations[j].MFAToken = ""
+			}
+		}
 	}
 
-	// Create temporary file
-	tempFile := inputFile + ".sanitized.tmp"
-	data, err := json.MarshalIndent(map[string][]config.Task{"tasks": sanitizedTasks}, "", "  ")
+	notifications, err := config.LoadNotifications(inputFile)
 	if err != nil {
 		return "", err
 	}
 
-	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+	// Keep the format of the input file so the uploaded copy can be used as input again
+	data, err := config.EncodeTasks(config.InputFormat(inputFile), &config.Tasks{Tasks: sanitizedTasks, Notifications: sanitizeNotifications(notifications)})
+	if err != nil {
 		return "", err
 	}
 
-	return tempFile, nil
+	// Create temporary file, unique per run as scheduled tasks may run concurrently
+	tempFile, err := os.CreateTemp(filepath.Dir(inputFile), filepath.Base(inputFile)+".*.sanitized.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer tempFile.Close()
+
+	if _, err := tempFile.Write(data); err != nil {
+		os.Remove(tempFile.Name())
+		return "", err
+	}
+
+	return tempFile.Name(), nil
+}
+
+// sanitizeNotifications removes passwords, header values and credentials of webhook URLs, indirect references are kept
+func sanitizeNotifications(notifications []config.Notification) []config.Notification {
+	sanitized := slices.Clone(notifications)
+	for i, notification := range notifications {
+		if !utils.IsIndirectSecret(notification.SMTPPassword) {
+			sanitized[i].SMTPPassword = ""
+		}
+		if !utils.IsIndirectSecret(notification.URL) {
+			sanitized[i].URL = utils.StripURLCredentials(notification.URL)
+		}
+		// Copy the headers, they are shared with the notification
+		sanitized[i].Headers = maps.Clone(notification.Headers)
+		for name, value := range notification.Headers {
+			if !utils.IsIndirectSecret(value) {
+				sanitized[i].Headers[name] = ""
+			}
+		}
+	}
+	return sanitized
 }
 
 func (s *BackupService) printSummary(dryRun bool) {
@@ -422,6 +1152,7 @@ func (s *BackupService) printSummary(dryRun bool) {
 		fmt.Printf("⏱️  Upload time: %v\n", s.summary.UploadTime.Round(time.Millisecond))
 	}
 	fmt.Printf("⏱️  Total time: %v\n", s.summary.TotalTime.Round(time.Millisecond))
+	s.printDestinations()
 
 	if s.summary.FailedUploads == 0 {
 		if dryRun {
@@ -434,3 +1165,22 @@ func (s *BackupService) printSummary(dryRun bool) {
 	}
 	fmt.Printf("%s", strings.Repeat("=", 50)+"\n")
 }
+
+// printDestinations prints the results per bucket if the run uploaded to several
+func (s *BackupService) printDestinations() {
+	if len(s.report.Destinations) < 2 {
+		return
+	}
+	fmt.Printf("🎯 Destinations:\n")
+	for _, target := range s.report.Destinations {
+		icon := "✅"
+		if target.Status != StatusSuccess {
+			icon = "❌"
+		}
+		fmt.Printf("   %s s3://%s: %d uploaded, %d skipped, %d failed (%s)\n",
+			icon, target.Bucket, target.Succeeded, target.Skipped, target.Failed, utils.FormatBytes(target.Bytes))
+		if target.Error != "" {
+			fmt.Printf("      %s\n", target.Error)
+		}
+	}
+}
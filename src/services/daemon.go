@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/robfig/cron/v3"
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+// StateFileSuffix is appended to the input file name for the default daemon state file
+const StateFileSuffix = ".state.json"
+
+// RunFinishedFunc is called after each scheduled run with the notifiers of the current configuration
+type RunFinishedFunc func(ctx context.Context, notifications []config.Notification, report *RunReport, err error)
+
+// Daemon runs the tasks of the input file on their cron schedules
+type Daemon struct {
+	cfg              aws.Config
+	inputFile        string
+	stateFile        string
+	dryRun           bool
+	throttle         *utils.Throttle
+	noLock           bool
+	lockStaleMinutes int64
+	onRunFinished    RunFinishedFunc
+
+	mu            sync.Mutex
+	finishMu      sync.Mutex
+	wg            sync.WaitGroup
+	state         *DaemonState
+	running       map[string]bool
+	schedules     []*scheduledTask
+	notifications []config.Notification
+}
+
+// DaemonState is the persisted last-run state of all scheduled tasks
+type DaemonState struct {
+	Tasks map[string]*TaskState `json:"tasks"`
+}
+
+// TaskState holds the last run of a scheduled task
+type TaskState struct {
+	LastStart  time.Time `json:"lastStart"`
+	LastFinish time.Time `json:"lastFinish,omitempty"`
+	LastStatus string    `json:"lastStatus,omitempty"`
+	LastError  string    `json:"lastError,omitempty"`
+	NextRun    time.Time `json:"nextRun,omitempty"`
+}
+
+// scheduledTask is a task with its parsed schedule and next run time
+type scheduledTask struct {
+	id       string
+	task     config.Task
+	schedule cron.Schedule
+	jitter   time.Duration
+	next     time.Time
+}
+
+// NewDaemon creates a daemon for the input file, the state file defaults to the input file with StateFileSuffix
+func NewDaemon(cfg aws.Config, inputFile, stateFile string, dryRun bool, onRunFinished RunFinishedFunc) *Daemon {
+	if stateFile == "" {
+		stateFile = strings.TrimSuffix(inputFile, filepath.Ext(inputFile)) + StateFileSuffix
+	}
+	return &Daemon{
+		cfg:           cfg,
+		inputFile:     inputFile,
+		stateFile:     stateFile,
+		dryRun:        dryRun,
+		onRunFinished: onRunFinished,
+		running:       make(map[string]bool),
+	}
+}
+
+// SetTransferLimits sets the global upload bandwidth limit and transfer windows, shared by all runs
+func (d *Daemon) SetTransferLimits(uploadLimitKBps int64, windows []utils.TransferWindow) {
+	d.throttle = utils.NewThrottle(uploadLimitKBps, windows)
+}
+
+// SetLocking configures the repository lock for all runs
+func (d *Daemon) SetLocking(noLock bool, staleMinutes int64) {
+	d.noLock = noLock
+	d.lockStaleMinutes = staleMinutes
+}
+
+// Run schedules the tasks until ctx is cancelled, a value on reload reloads the input file
+func (d *Daemon) Run(ctx context.Context, reload <-chan os.Signal) error {
+	state, err := LoadDaemonState(d.stateFile)
+	if err != nil {
+		return err
+	}
+	d.state = state
+
+	if err := d.load(time.Now()); err != nil {
+		return err
+	}
+	log.Printf("🕒 Daemon started with %d scheduled tasks (state: %s)", len(d.schedules), d.stateFile)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		timer.Reset(time.Until(d.nextDue()))
+
+		select {
+		case <-ctx.Done():
+			log.Printf("🛑 Daemon stopping, waiting for running tasks...")
+			d.wg.Wait()
+			return nil
+		case <-reload:
+			log.Printf("🔄 Reloading %s", d.inputFile)
+			if err := d.load(time.Now()); err != nil {
+				slog.Error(fmt.Sprintf("❌ Reload failed, keeping previous schedules: %v", err),
+					"event", utils.EventSchedule, "file", d.inputFile, "error", err)
+			} else {
+				log.Printf("✅ Reloaded %d scheduled tasks", len(d.schedules))
+			}
+		case now := <-timer.C:
+			d.startDueTasks(ctx, now)
+		}
+	}
+}
+
+// load reads the input file and schedules its tasks, missed runs since the last persisted run are due immediately
+func (d *Daemon) load(now time.Time) error {
+	tasks, err := config.LoadTasks(d.inputFile)
+	if err != nil {
+		return err
+	}
+	notifications, err := config.LoadNotifications(d.inputFile)
+	if err != nil {
+		return err
+	}
+
+	var schedules []*scheduledTask
+	ids := make(map[string]bool)
+	for _, task := range tasks {
+		id := TaskID(task)
+		if ids[id] {
+			return fmt.Errorf("❌ task %s is defined twice, tasks need different content or streams to be scheduled", id)
+		}
+		ids[id] = true
+		if task.Schedule == "" {
+			slog.Warn(fmt.Sprintf("⚠️ Task %s has no Schedule, it is not run in daemon mode", id),
+				"event", utils.EventSchedule, "task", id)
+			continue
+		}
+
+		schedule, err := cron.ParseStandard(task.Schedule)
+		if err != nil {
+			return fmt.Errorf("❌ invalid Schedule '%s' for task %s: %w", task.Schedule, id, err)
+		}
+		scheduled := &scheduledTask{
+			id:       id,
+			task:     task,
+			schedule: schedule,
+			jitter:   time.Duration(task.ScheduleJitterMinutes.Or(0)) * time.Minute,
+		}
+
+		d.mu.Lock()
+		if previous, exists := d.state.Tasks[id]; exists && !previous.LastStart.IsZero() {
+			scheduled.next = schedule.Next(previous.LastStart)
+		} else {
+			scheduled.next = schedule.Next(now)
+		}
+		d.mu.Unlock()
+		if scheduled.next.Before(now) {
+			log.Printf("⏰ Task %s missed a run at %s, running it now", id, scheduled.next.Format(time.RFC3339))
+			scheduled.next = now
+		}
+		scheduled.next = scheduled.next.Add(randomJitter(scheduled.jitter))
+		log.Printf("🕒 Task %s scheduled '%s', next run: %s", id, task.Schedule, scheduled.next.Format(time.RFC3339))
+
+		schedules = append(schedules, scheduled)
+	}
+
+	if len(schedules) == 0 {
+		return fmt.Errorf("❌ no tasks with Schedule found in input file")
+	}
+
+	d.mu.Lock()
+	d.schedules = schedules
+	d.notifications = notifications
+	d.mu.Unlock()
+	return nil
+}
+
+// nextDue returns the earliest next run time of all scheduled tasks
+func (d *Daemon) nextDue() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var next time.Time
+	for _, scheduled := range d.schedules {
+		if next.IsZero() || scheduled.next.Before(next) {
+			next = scheduled.next
+		}
+	}
+	return next
+}
+
+// startDueTasks starts all due tasks in the background, a task that is still running is skipped
+func (d *Daemon) startDueTasks(ctx context.Context, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, scheduled := range d.schedules {
+		if scheduled.next.After(now) {
+			continue
+		}
+		scheduled.next = scheduled.schedule.Next(now).Add(randomJitter(scheduled.jitter))
+		d.taskState(scheduled.id).NextRun = scheduled.next
+
+		if d.running[scheduled.id] {
+			slog.Warn(fmt.Sprintf("⚠️ Task %s is still running, skipping this run (next run: %s)",
+				scheduled.id, scheduled.next.Format(time.RFC3339)), "event", utils.EventSkip, "task", scheduled.id)
+			continue
+		}
+
+		d.running[scheduled.id] = true
+		d.wg.Add(1)
+		go d.runTask(ctx, scheduled.id, scheduled.task, d.notifications)
+	}
+	d.saveState()
+}
+
+// runTask runs a single task with its own backup service and records the result
+func (d *Daemon) runTask(ctx context.Context, id string, task config.Task, notifications []config.Notification) {
+	defer d.wg.Done()
+
+	log.Printf("▶️ Starting scheduled task %s", id)
+	d.mu.Lock()
+	d.taskState(id).LastStart = time.Now()
+	d.saveState()
+	d.mu.Unlock()
+
+	backupService := NewBackupService(d.cfg)
+	backupService.SetThrottle(d.throttle)
+	backupService.SetLocking(d.noLock, d.lockStaleMinutes)
+	err := backupService.ProcessTasks(ctx, []config.Task{task}, d.inputFile, d.dryRun)
+	report := backupService.Report()
+
+	d.mu.Lock()
+	state := d.taskState(id)
+	state.LastFinish = time.Now()
+	state.LastStatus = report.Status
+	state.LastError = errorString(err)
+	d.running[id] = false
+	d.saveState()
+	d.mu.Unlock()
+
+	if err != nil {
+		slog.Error(fmt.Sprintf("❌ Scheduled task %s finished with status %s: %v", id, report.Status, err),
+			"event", utils.EventSchedule, "task", id, "status", report.Status, "error", err)
+	} else {
+		log.Printf("✅ Scheduled task %s finished successfully", id)
+	}
+
+	if d.onRunFinished != nil {
+		d.finishMu.Lock()
+		d.onRunFinished(ctx, notifications, report, err)
+		d.finishMu.Unlock()
+	}
+}
+
+// taskState returns the state of a task, creating it if needed (caller holds the lock)
+func (d *Daemon) taskState(id string) *TaskState {
+	state, exists := d.state.Tasks[id]
+	if !exists {
+		state = &TaskState{}
+		d.state.Tasks[id] = state
+	}
+	return state
+}
+
+// saveState persists the state, failures are logged only (caller holds the lock)
+func (d *Daemon) saveState() {
+	if err := d.state.WriteFile(d.stateFile); err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ %v", err), "event", utils.EventSchedule, "file", d.stateFile, "error", err)
+	}
+}
+
+// TaskID identifies a task across restarts and reloads by bucket, prefix, content and stream object names
+func TaskID(task config.Task) string {
+	id := fmt.Sprintf("s3://%s/%s [%s]", task.S3Bucket, task.S3Prefix, strings.Join(task.Content, ","))
+	if len(task.Streams) > 0 {
+		names := make([]string, len(task.Streams))
+		for i, stream := range task.Streams {
+			names[i] = stream.ObjectName
+		}
+		id += fmt.Sprintf(" streams [%s]", strings.Join(names, ","))
+	}
+	return id
+}
+
+// LoadDaemonState reads the persisted state, a missing file results in an empty state
+func LoadDaemonState(path string) (*DaemonState, error) {
+	state := &DaemonState{Tasks: make(map[string]*TaskState)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("❌ failed to parse state file: %w", err)
+	}
+	if state.Tasks == nil {
+		state.Tasks = make(map[string]*TaskState)
+	}
+	return state, nil
+}
+
+// WriteFile atomically writes the state as indented JSON
+func (s *DaemonState) WriteFile(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("❌ failed to encode state: %w", err)
+	}
+
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, data, utils.DefaultFilePerm); err != nil {
+		return fmt.Errorf("❌ failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmpFile, path); err != nil {
+		return fmt.Errorf("❌ failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// randomJitter returns a random delay between zero and max
+func randomJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max)
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+// Hooks holds the commands run around a backup task or a restore run
+type Hooks struct {
+	PreCommand     string
+	PostCommand    string
+	OnErrorCommand string
+	Timeout        time.Duration
+}
+
+// isEmpty reports whether no hook command is configured
+func (h Hooks) isEmpty() bool {
+	return h.PreCommand == "" && h.PostCommand == "" && h.OnErrorCommand == ""
+}
+
+// runWithHooks runs fn between the pre and post hooks. A failing pre hook stops the run, the post hook runs
+// after fn whether it succeeded or not and fails a successful run, the on-error hook runs if any step failed.
+func runWithHooks(ctx context.Context, hooks Hooks, env map[string]string, dryRun bool, fn func() error) error {
+	if hooks.isEmpty() {
+		return fn()
+	}
+	if dryRun {
+		log.Printf("🪝 [DRY-RUN] Hooks are not executed")
+		return fn()
+	}
+
+	if err := utils.RunHook(ctx, utils.HookPre, hooks.PreCommand, hooks.Timeout, env); err != nil {
+		return runOnErrorHook(ctx, hooks, env, err)
+	}
+
+	err := fn()
+
+	// Post and on-error hooks also run if the run was interrupted, e.g. to restart stopped services
+	postEnv := hookResultEnv(env, err)
+	if postErr := utils.RunHook(context.WithoutCancel(ctx), utils.HookPost, hooks.PostCommand, hooks.Timeout, postEnv); postErr != nil {
+		if err == nil {
+			err = postErr
+		} else {
+			slog.Error(postErr.Error(), "event", utils.EventHook, "hook", utils.HookPost, "error", postErr)
+		}
+	}
+
+	if err != nil {
+		return runOnErrorHook(ctx, hooks, env, err)
+	}
+	return nil
+}
+
+// runOnErrorHook runs the on-error hook for err and returns err, a failing hook is logged only
+func runOnErrorHook(ctx context.Context, hooks Hooks, env map[string]string, err error) error {
+	if hookErr := utils.RunHook(context.WithoutCancel(ctx), utils.HookOnError, hooks.OnErrorCommand, hooks.Timeout, hookResultEnv(env, err)); hookErr != nil {
+		slog.Warn(fmt.Sprintf("⚠️ %v", hookErr), "event", utils.EventHook, "hook", utils.HookOnError, "error", hookErr)
+	}
+	return err
+}
+
+// hookResultEnv adds the result of the run to the hook environment
+func hookResultEnv(env map[string]string, err error) map[string]string {
+	result := maps.Clone(env)
+	if result == nil {
+		result = make(map[string]string)
+	}
+	result["STATUS"] = classifyRun(err, 0, 0)
+	if err != nil {
+		result["ERROR"] = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+// RestoreLayout changes where restored objects are written, the zero value keeps the full key below -destination
+type RestoreLayout struct {
+	StripPrefix string
+	Flat        bool
+	Mappings    []config.PathMapping
+}
+
+// IsEmpty reports whether objects are restored with their full key below the download location
+func (l RestoreLayout) IsEmpty() bool {
+	return l.StripPrefix == "" && !l.Flat && len(l.Mappings) == 0
+}
+
+// restoreGroup holds the objects restored below one directory, the local key of each object is relative to it
+type restoreGroup struct {
+	dir     string
+	objects []S3Object
+	pending []S3Object
+	failed  map[string]bool // Keys of the objects whose download failed
+}
+
+// locate returns the directory an object is restored to and its local key below it: the Target of the longest
+// matching mapping (relative targets are below the download location) or the download location without StripPrefix
+func (l RestoreLayout) locate(downloadLocation, key string) (string, string) {
+	dir, localKey := downloadLocation, key
+	var matched string
+	for _, mapping := range l.Mappings {
+		prefix := strings.Trim(mapping.Prefix, "/")
+		if rest, found := trimKeyPrefix(key, prefix); found && len(prefix) > len(matched) {
+			matched = prefix
+			dir, localKey = mapping.Target, rest
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(downloadLocation, dir)
+			}
+		}
+	}
+	if matched == "" {
+		localKey, _ = trimKeyPrefix(key, strings.Trim(l.StripPrefix, "/"))
+	}
+	if l.Flat {
+		localKey = path.Base(localKey)
+	}
+	return dir, localKey
+}
+
+// trimKeyPrefix removes a prefix of whole path elements from an object key
+func trimKeyPrefix(key, prefix string) (string, bool) {
+	if prefix == "" {
+		return key, true
+	}
+	if rest, found := strings.CutPrefix(key, prefix+"/"); found && strings.Trim(rest, "/") != "" {
+		return strings.TrimLeft(rest, "/"), true
+	}
+	return key, false
+}
+
+// groupObjects applies the layout to the objects and groups them by the directory they are restored to.
+// Objects that would be restored to the same file or outside of their directory are an error.
+func (l RestoreLayout) groupObjects(downloadLocation string, objects []S3Object) ([]*restoreGroup, error) {
+	if l.IsEmpty() {
+		return []*restoreGroup{{dir: downloadLocation, objects: objects}}, nil
+	}
+
+	var groups []*restoreGroup
+	byDir := make(map[string]*restoreGroup)
+	owners := make(map[string]string)
+	for _, obj := range objects {
+		dir, localKey := l.locate(downloadLocation, obj.LocalKey())
+		if !filepath.IsLocal(filepath.FromSlash(localKey)) {
+			return nil, fmt.Errorf("❌ object %s cannot be restored below %s", obj.Key, dir)
+		}
+		localPath := filepath.Join(dir, filepath.FromSlash(localKey))
+		if other, found := owners[localPath]; found {
+			return nil, fmt.Errorf("❌ objects %s and %s would both be restored to %s, adjust -stripPrefix, -flat or the mapping file", other, obj.Key, localPath)
+		}
+		owners[localPath] = obj.Key
+
+		group, found := byDir[dir]
+		if !found {
+			group = &restoreGroup{dir: dir}
+			byDir[dir] = group
+			groups = append(groups, group)
+		}
+		obj.Name = localKey
+		group.objects = append(group.objects, obj)
+	}
+
+	for _, group := range groups {
+		log.Printf("📂 Restoring %d objects to: %s", len(group.objects), group.dir)
+	}
+	return groups, nil
+}
+
+// finishObjects combines the parts and decompresses the archives of the objects of a group in place. Unlike
+// the scan of the download location only files of the objects are touched, as a Target may hold other data.
+// Archives are extracted into their own directory, they contain the directory or file they were built from.
+// Archives with a failed download are left alone, parts are only removed once their number is verified.
+func (s *RestoreService) finishObjects(group *restoreGroup, skipDecompression bool) error {
+	partPattern := regexp.MustCompile(`^(.+)-part\d{5}$`)
+	var archives, files []string
+	parts := make(map[string][]string)
+	instructions := make(map[string]string)
+	incomplete := make(map[string]bool)
+	for _, obj := range group.objects {
+		localPath := filepath.Join(group.dir, filepath.FromSlash(strings.TrimSuffix(obj.LocalKey(), "."+config.EncryptionExt)))
+		archive := strings.TrimSuffix(localPath, utils.HowToBuildSuffix)
+		if matches := partPattern.FindStringSubmatch(filepath.Base(localPath)); matches != nil {
+			archive = filepath.Join(filepath.Dir(localPath), matches[1])
+		}
+		if group.failed[obj.Key] {
+			incomplete[archive] = true
+			continue
+		}
+		if _, err := os.Stat(localPath); err != nil {
+			continue // Not downloaded or already processed
+		}
+		switch {
+		case archive != localPath && strings.HasSuffix(localPath, utils.HowToBuildSuffix):
+			instructions[archive] = localPath
+		case archive != localPath:
+			if parts[archive] == nil {
+				archives = append(archives, archive)
+			}
+			parts[archive] = append(parts[archive], localPath)
+		default:
+			files = append(files, localPath)
+		}
+	}
+
+	for _, archive := range archives {
+		if incomplete[archive] {
+			continue
+		}
+		expected := 0
+		if howToFile, found := instructions[archive]; found {
+			var err error
+			if expected, err = utils.ReadHowToBuildParts(howToFile); err != nil {
+				s.warnNotCombined(archive, err)
+				continue
+			}
+		}
+		if expected > 0 && expected != len(parts[archive]) {
+			s.warnNotCombined(archive, fmt.Errorf("%d of %d parts found", len(parts[archive]), expected))
+			continue
+		}
+		if _, err := utils.CombineParts(parts[archive]); err != nil {
+			s.warnNotCombined(archive, err)
+			continue
+		}
+		if expected == 0 {
+			log.Printf("ℹ️ Keeping the parts of %s, their number is not recorded in its instructions", filepath.Base(archive))
+		} else {
+			for _, file := range append(parts[archive], instructions[archive]) {
+				os.Remove(file)
+			}
+		}
+		files = append(files, archive)
+	}
+
+	for _, archive := range slices.Sorted(maps.Keys(incomplete)) {
+		slog.Warn(fmt.Sprintf("⚠️ Warning: Not combining or extracting %s, a download failed", archive),
+			"event", utils.EventCombine, "file", archive)
+		s.summary.Warnings++
+	}
+
+	if skipDecompression {
+		log.Printf("⏭️ Skipping archive decompression (--skipDecompression flag set)")
+		return nil
+	}
+	for _, file := range files {
+		name := filepath.Base(file)
+		switch {
+		case incomplete[file]:
+			continue
+		case strings.HasSuffix(name, "."+config.ArchiveExtension):
+			// Extracted in place, the archive is decompressed if the directory or file it was built from exists
+			root, err := utils.ArchiveRoot(file)
+			if err != nil {
+				slog.Error(fmt.Sprintf("❌ Failed to decompress %s: %v", name, err), "event", utils.EventExtract, "file", file, "error", err)
+				continue
+			}
+			s.decompressArchive(file, filepath.Dir(file), filepath.Join(filepath.Dir(file), filepath.FromSlash(root)))
+		case strings.HasSuffix(name, "."+config.StreamExtension):
+			s.decompressStream(file, name)
+		}
+	}
+	return nil
+}
+
+// warnNotCombined reports an archive whose parts are kept because they could not be combined safely
+func (s *RestoreService) warnNotCombined(archive string, err error) {
+	slog.Warn(fmt.Sprintf("⚠️ Warning: Not combining %s, its parts are kept: %v", archive, err),
+		"event", utils.EventCombine, "file", archive, "error", err)
+	s.summary.Warnings++
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+// RecordReportMetrics exports the results of a finished run to the metrics registry
+func RecordReportMetrics(metrics *utils.Metrics, report *RunReport) {
+	success := 0.0
+	if report.Status == StatusSuccess {
+		success = 1
+	}
+
+	metrics.SetGauge(utils.MetricRunSuccess, "Whether the last run succeeded (1) or not (0)", success, "mode", report.Mode)
+	metrics.SetGauge(utils.MetricRunTimestamp, "Unix time the last run finished", float64(report.EndTime.Unix()), "mode", report.Mode)
+	metrics.SetGauge(utils.MetricRunDuration, "Duration of the last run in seconds", report.DurationSeconds, "mode", report.Mode)
+
+	for _, task := range report.Tasks {
+		labels := []string{"bucket", task.Bucket, "prefix", task.Prefix}
+
+		failures := 0.0
+		if task.Status == StatusSuccess {
+			metrics.SetGauge(utils.MetricTaskLastSuccess, "Unix time of the last successful run of a task", float64(report.EndTime.Unix()), labels...)
+		} else {
+			failures = 1
+		}
+
+		metrics.SetGauge(utils.MetricTaskFailures, "Whether the last run of a task failed (1) or not (0)", failures, labels...)
+		metrics.SetGauge(utils.MetricTaskDuration, "Duration of the last run of a task in seconds", task.DurationSeconds, labels...)
+		metrics.SetGauge(utils.MetricTaskBytes, "Bytes uploaded by the last run of a task", float64(task.Bytes), labels...)
+		metrics.SetGauge(utils.MetricTaskParts, "Objects (archives and parts) handled by the last run of a task", float64(len(task.Objects)), labels...)
+	}
+}
+
+// recordObjectMetrics counts a transferred object while the run is in progress
+func recordObjectMetrics(mode, status string, size int64) {
+	utils.DefaultMetrics.AddCounter(utils.MetricObjectsTotal, "Objects handled by status", 1, "mode", mode, "status", status)
+	if status == ObjectUploaded || status == ObjectDownloaded {
+		utils.DefaultMetrics.AddCounter(utils.MetricBytesTotal, "Bytes transferred", float64(size), "mode", mode)
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+// NotifyRun sends the configured notifications for the result of a finished run
+func NotifyRun(ctx context.Context, notifications []config.Notification, report *RunReport) {
+	if len(notifications) == 0 {
+		return
+	}
+
+	event := NotificationEvent(report)
+	utils.SendNotifications(ctx, notifications, utils.NotificationMessage{
+		Event:    event,
+		Mode:     report.Mode,
+		Status:   report.Status,
+		ExitCode: report.ExitCode,
+		Error:    report.Error,
+		Subject:  notificationSubject(report, event),
+		Summary:  SummaryText(report),
+		Time:     report.EndTime,
+	})
+}
+
+// NotificationEvent maps the result of a run to a notification event
+func NotificationEvent(report *RunReport) string {
+	switch {
+	case report.Status == StatusSuccess && report.Totals.Warnings == 0:
+		return config.NotifyOnSuccess
+	case report.Status == StatusSuccess || report.Status == StatusPartial:
+		return config.NotifyOnWarning
+	default:
+		return config.NotifyOnFailure
+	}
+}
+
+// SummaryText formats the summary of a run for notifications
+func SummaryText(report *RunReport) string {
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Mode: %s\n", report.Mode)
+	fmt.Fprintf(&summary, "Status: %s (exit code %d)\n", report.Status, report.ExitCode)
+	if report.DryRun {
+		summary.WriteString("Dry-run: yes\n")
+	}
+	fmt.Fprintf(&summary, "Succeeded: %d\n", report.Totals.Succeeded)
+	fmt.Fprintf(&summary, "Failed: %d\n", report.Totals.Failed)
+	fmt.Fprintf(&summary, "Skipped: %d\n", report.Totals.Skipped)
+	fmt.Fprintf(&summary, "Warnings: %d\n", report.Totals.Warnings)
+	fmt.Fprintf(&summary, "Total files: %d\n", report.Totals.Files)
+	fmt.Fprintf(&summary, "Total data: %s\n", utils.FormatBytes(report.Totals.Bytes))
+	fmt.Fprintf(&summary, "Duration: %v\n", time.Duration(report.DurationSeconds*float64(time.Second)).Round(time.Second))
+
+	for _, task := range report.Tasks {
+		fmt.Fprintf(&summary, "Task s3://%s/%s: %s", task.Bucket, task.Prefix, task.Status)
+		if task.Error != "" {
+			fmt.Fprintf(&summary, " (%s)", task.Error)
+		}
+		summary.WriteString("\n")
+	}
+
+	if report.Error != "" {
+		fmt.Fprintf(&summary, "Error: %s\n", report.Error)
+	}
+	return strings.TrimSuffix(summary.String(), "\n")
+}
+
+// notificationSubject creates the short headline of a run notification
+func notificationSubject(report *RunReport, event string) string {
+	icon := "✅"
+	switch event {
+	case config.NotifyOnWarning:
+		icon = "⚠️"
+	case config.NotifyOnFailure:
+		icon = "❌"
+	}
+	return fmt.Sprintf("%s %s %s %s", icon, config.AppName, report.Mode, report.Status)
+}
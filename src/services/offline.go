@@ -0,0 +1,123 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+// Offline recovery commands, they work on local copies of backup files without AWS access
+const (
+	CommandDecrypt = "decrypt"
+	CommandCombine = "combine"
+	CommandExtract = "extract"
+)
+
+// IsOfflineCommand reports whether name is an offline recovery command
+func IsOfflineCommand(name string) bool {
+	return name == CommandDecrypt || name == CommandCombine || name == CommandExtract
+}
+
+// DecryptFiles decrypts *.enc files next to them, existing decrypted files are not overwritten
+func DecryptFiles(paths []string, keys utils.DecryptionKeys) error {
+	failed := 0
+	keys = keys.WithMasterKeyCache()
+	for _, path := range paths {
+		if err := decryptLocalFile(path, keys); err != nil {
+			failed++
+			slog.Error(fmt.Sprintf("❌ Failed to decrypt %s: %v", path, err), "event", utils.EventDecrypt, "file", path, "error", err)
+		}
+	}
+	return offlineResult(CommandDecrypt, len(paths)-failed, failed)
+}
+
+// decryptLocalFile decrypts a single file after checking its name and output path
+func decryptLocalFile(path string, keys utils.DecryptionKeys) error {
+	if !strings.HasSuffix(path, "."+config.EncryptionExt) {
+		return fmt.Errorf("not an encrypted file (*.%s)", config.EncryptionExt)
+	}
+	outputPath := strings.TrimSuffix(path, "."+config.EncryptionExt)
+	if _, err := os.Stat(outputPath); err == nil {
+		return fmt.Errorf("%s already exists", outputPath)
+	}
+
+	decryptedPath, err := utils.DecryptFileWithKeys(path, keys)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("🔓 Decrypted: %s", decryptedPath), "event", utils.EventDecrypt, "file", path)
+	return nil
+}
+
+// CombineFiles combines the parts of split files, all parts of a file have to be given
+func CombineFiles(paths []string) error {
+	combined, err := utils.CombineParts(paths)
+	if err != nil {
+		return err
+	}
+	log.Printf("✅ Combined %d files from %d parts", len(combined), len(paths))
+	return nil
+}
+
+// ExtractFiles extracts tar.gz archives into a directory named like the archive and decompresses
+// streams (*.gz) next to them. destination replaces the directory of the archives if set.
+func ExtractFiles(paths []string, destination string) error {
+	failed := 0
+	for _, path := range paths {
+		if err := extractLocalFile(path, destination); err != nil {
+			failed++
+			slog.Error(fmt.Sprintf("❌ Failed to extract %s: %v", path, err), "event", utils.EventExtract, "file", path, "error", err)
+		}
+	}
+	return offlineResult(CommandExtract, len(paths)-failed, failed)
+}
+
+// extractLocalFile extracts a single archive or stream
+func extractLocalFile(path, destination string) error {
+	name := filepath.Base(path)
+	dir := filepath.Dir(path)
+	if destination != "" {
+		dir = destination
+	}
+
+	var outputPath string
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"):
+		outputPath = filepath.Join(dir, strings.TrimSuffix(name, ".tar.gz"))
+	case strings.HasSuffix(name, "."+config.StreamExtension):
+		outputPath = filepath.Join(dir, strings.TrimSuffix(name, "."+config.StreamExtension))
+	default:
+		return fmt.Errorf("not an archive (*.tar.gz) or stream (*.%s)", config.StreamExtension)
+	}
+	if _, err := os.Stat(outputPath); err == nil {
+		return fmt.Errorf("%s already exists", outputPath)
+	}
+	if err := os.MkdirAll(dir, utils.DefaultDirPerm); err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(name, ".tar.gz") {
+		if err := utils.ExtractArchive(path, outputPath); err != nil {
+			return err
+		}
+	} else if err := utils.DecompressFile(path, outputPath); err != nil {
+		return err
+	}
+	log.Printf("✅ Extracted: %s -> %s", name, outputPath)
+	return nil
+}
+
+// offlineResult returns nil if all files succeeded, otherwise a *RunError with the partial or failed status
+func offlineResult(command string, succeeded, failed int) error {
+	if failed == 0 {
+		return nil
+	}
+	err := fmt.Errorf("❌ %s failed for %d of %d files: %w", command, failed, succeeded+failed, ErrPartialFailure)
+	return &RunError{Status: classifyRun(err, succeeded, failed), Err: err}
+}
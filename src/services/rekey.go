@@ -0,0 +1,351 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+// ObjectRekeyed is the report status of an object re-encrypted under the new secret
+const ObjectRekeyed = "rekeyed"
+
+// RekeyService re-encrypts the encrypted objects of a prefix under a new secret
+type RekeyService struct {
+	cfg                     aws.Config
+	report                  *RunReport
+	oldKeys                 utils.DecryptionKeys
+	newKeys                 utils.DecryptionKeys
+	encryptor               *utils.Encryptor
+	uploadThrottle          *utils.Throttle
+	downloadThrottle        *utils.Throttle
+	noLock                  bool
+	lockStaleAfter          time.Duration
+	retrievalMode           string
+	restoreExpiresAfterDays int32
+	autoRetryMinutes        int
+	sse                     *utils.ServerSideEncryption
+	summary                 RekeySummary
+}
+
+// RekeySummary counts the objects of a rekey run
+type RekeySummary struct {
+	Rekeyed        int
+	AlreadyRekeyed int
+	Failed         int
+	Pending        int
+	// LegacyManifests counts manifests without a stored name key, their object names depend on the old secret
+	LegacyManifests int
+}
+
+// NewRekeyService creates a rekey service, objects are decrypted with oldKeys and encrypted for newPassword
+func NewRekeyService(cfg aws.Config, oldKeys utils.DecryptionKeys, newPassword string, encryptor *utils.Encryptor) *RekeyService {
+	return &RekeyService{
+		cfg:                     cfg,
+		report:                  newRunReport("rekey", false),
+		oldKeys:                 oldKeys.WithMasterKeyCache(),
+		newKeys:                 utils.DecryptionKeys{Passwords: []string{newPassword}}.WithMasterKeyCache(),
+		encryptor:               encryptor,
+		lockStaleAfter:          time.Duration(config.DefaultLockStaleMinutes) * time.Minute,
+		retrievalMode:           config.DefaultRetrievalMode,
+		restoreExpiresAfterDays: config.DefaultRestoreExpiresAfterDays,
+	}
+}
+
+// Report returns the machine-readable report of the last run
+func (s *RekeyService) Report() *RunReport {
+	return s.report
+}
+
+// SetTransferLimits sets the bandwidth limits and transfer windows
+func (s *RekeyService) SetTransferLimits(uploadLimitKBps, downloadLimitKBps int64, windows []utils.TransferWindow) {
+	s.uploadThrottle = utils.NewThrottle(uploadLimitKBps, windows)
+	s.downloadThrottle = utils.NewThrottle(downloadLimitKBps, windows)
+}
+
+// SetLocking disables the prefix lock or sets the minutes after which a lock is stale (0 keeps the default)
+func (s *RekeyService) SetLocking(noLock bool, staleMinutes int64) {
+	s.noLock = noLock
+	if staleMinutes > 0 {
+		s.lockStaleAfter = time.Duration(staleMinutes) * time.Minute
+	}
+}
+
+// SetGlacierRestore sets how objects in Glacier storage classes are restored before they are rekeyed.
+// Without auto-retry the restore is only initiated and the run has to be repeated when it completed.
+func (s *RekeyService) SetGlacierRestore(retrievalMode string, restoreExpiresAfterDays int32, autoRetryMinutes int) {
+	s.retrievalMode = retrievalMode
+	s.restoreExpiresAfterDays = restoreExpiresAfterDays
+	s.autoRetryMinutes = autoRetryMinutes
+}
+
+// SetServerSideEncryption sets the SSE-C key to read objects stored with a customer-provided key,
+// rekeyed objects keep the server-side encryption they are stored with
+func (s *RekeyService) SetServerSideEncryption(sse *utils.ServerSideEncryption) {
+	s.sse = sse
+}
+
+// ProcessRekey re-encrypts all encrypted objects below the prefix, the returned error is a *RunError if the run did not succeed
+func (s *RekeyService) ProcessRekey(ctx context.Context, bucket, prefix string, dryRun bool) error {
+	s.report = newRunReport("rekey", dryRun)
+	s.summary = RekeySummary{}
+	err := s.processRekey(ctx, bucket, prefix, dryRun)
+	s.printSummary(dryRun)
+	return s.report.finish(err)
+}
+
+func (s *RekeyService) processRekey(ctx context.Context, bucket, prefix string, dryRun bool) error {
+	if !dryRun && !s.noLock {
+		lock, lockCtx, err := utils.AcquireLock(ctx, s.cfg, bucket, prefix, "rekey", s.lockStaleAfter)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := lock.Release(ctx); err != nil {
+				slog.Warn(fmt.Sprintf("⚠️ %v", err), "event", utils.EventLock, "bucket", lock.Bucket(), "key", lock.Key(), "error", err)
+				s.report.Totals.Warnings++
+			}
+		}()
+
+		// Objects are only re-encrypted while the lock is held
+		err = s.rekeyObjects(lockCtx, bucket, prefix, dryRun)
+		if cause := context.Cause(lockCtx); errors.Is(cause, utils.ErrLockLost) {
+			return cause
+		}
+		return err
+	}
+	return s.rekeyObjects(ctx, bucket, prefix, dryRun)
+}
+
+// rekeyObjects re-encrypts the encrypted objects below the prefix under the new key
+func (s *RekeyService) rekeyObjects(ctx context.Context, bucket, prefix string, dryRun bool) error {
+
+	objects, err := utils.ListObjects(ctx, s.cfg, bucket, prefix)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	var encrypted []types.Object
+	for _, obj := range objects {
+		if strings.HasSuffix(aws.ToString(obj.Key), "."+config.EncryptionExt) {
+			encrypted = append(encrypted, obj)
+		}
+	}
+	log.Printf("🔑 Found %d encrypted objects in s3://%s/%s", len(encrypted), bucket, prefix)
+
+	pending, err := s.restoreGlacierObjects(ctx, bucket, encrypted, dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range encrypted {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		key := aws.ToString(obj.Key)
+		if pending[key] {
+			s.summary.Pending++
+			s.recordObject(bucket, key, aws.ToInt64(obj.Size), ObjectSkipped, time.Now(), fmt.Errorf("restore from Glacier not completed"))
+			continue
+		}
+		s.rekeyObject(ctx, bucket, obj, dryRun)
+	}
+
+	if s.summary.Failed > 0 {
+		return fmt.Errorf("❌ %d of %d objects failed to rekey: %w", s.summary.Failed, len(encrypted), ErrPartialFailure)
+	}
+	if s.summary.Pending > 0 {
+		return fmt.Errorf("❌ %d objects are still being restored from Glacier, run rekey again when they are available: %w", s.summary.Pending, ErrPartialFailure)
+	}
+	return nil
+}
+
+// rekeyObject downloads, re-encrypts, verifies and uploads a single object
+func (s *RekeyService) rekeyObject(ctx context.Context, bucket string, obj types.Object, dryRun bool) {
+	start := time.Now()
+	key := aws.ToString(obj.Key)
+	size := aws.ToInt64(obj.Size)
+
+	status, err := s.reencrypt(ctx, bucket, key, obj.StorageClass, dryRun)
+	switch {
+	case err != nil:
+		s.summary.Failed++
+		slog.Error(fmt.Sprintf("❌ Failed to rekey %s: %v", key, err),
+			"event", utils.EventEncrypt, "bucket", bucket, "key", key, "size", size, "error", err)
+	case status == ObjectSkipped:
+		s.summary.AlreadyRekeyed++
+		slog.Info(fmt.Sprintf("⏭️ Skipping %s (already encrypted with the new secret)", key),
+			"event", utils.EventSkip, "bucket", bucket, "key", key, "size", size)
+	default:
+		s.summary.Rekeyed++
+		slog.Info(fmt.Sprintf("✅ Rekeyed: %s", key),
+			"event", utils.EventEncrypt, "bucket", bucket, "key", key, "size", size, "status", status)
+	}
+	s.recordObject(bucket, key, size, status, start, err)
+}
+
+// reencrypt re-encrypts an object and returns its report status
+func (s *RekeyService) reencrypt(ctx context.Context, bucket, key string, storageClass types.ObjectStorageClass, dryRun bool) (string, error) {
+	var data []byte
+	err := utils.RetryWithBackoff(ctx, func() error {
+		var getErr error
+		data, getErr = utils.GetObjectData(ctx, s.cfg, bucket, key, s.sse, s.downloadThrottle)
+		return getErr
+	}, fmt.Sprintf("Download %s", key))
+	if err != nil {
+		return ObjectFailed, err
+	}
+
+	// Objects of an interrupted earlier run are already done
+	if utils.IsEncryptedWith(data, s.newKeys) {
+		return ObjectSkipped, nil
+	}
+
+	plaintext, err := utils.DecryptData(data, s.oldKeys)
+	if err != nil {
+		return ObjectFailed, fmt.Errorf("decryption with the old secret failed: %w", err)
+	}
+	if utils.IsManifestKey(key) {
+		if manifest, err := utils.DecryptManifest(data, s.oldKeys); err == nil && len(manifest.NameKey) == 0 {
+			s.summary.LegacyManifests++
+		}
+	}
+	reencrypted, err := s.encryptor.EncryptData(plaintext)
+	if err != nil {
+		return ObjectFailed, err
+	}
+	check, err := utils.DecryptData(reencrypted, s.newKeys)
+	if err != nil || !bytes.Equal(check, plaintext) {
+		return ObjectFailed, fmt.Errorf("re-encrypted data does not decrypt with the new secret")
+	}
+
+	if dryRun {
+		log.Printf("🔑 [DRY-RUN] Would rekey %s (%s)", key, utils.FormatBytes(int64(len(reencrypted))))
+		return ObjectDryRun, nil
+	}
+
+	// The new version keeps the server-side encryption and the active retention and legal hold
+	sse, err := utils.GetObjectEncryption(ctx, s.cfg, bucket, key, s.sse)
+	if err != nil {
+		return ObjectFailed, err
+	}
+	lock, err := utils.GetObjectLock(ctx, s.cfg, bucket, key, s.sse)
+	if err != nil {
+		return ObjectFailed, err
+	}
+	if lock != nil {
+		log.Printf("🔏 %s is locked (%s), the old version is kept until the lock ends", key, lock)
+	}
+	err = utils.RetryWithBackoff(ctx, func() error {
+		return utils.PutObjectData(ctx, s.cfg, bucket, key, reencrypted, types.StorageClass(storageClass), sse, lock, s.uploadThrottle)
+	}, fmt.Sprintf("Upload %s", key))
+	if err != nil {
+		return ObjectFailed, err
+	}
+
+	// Verify what S3 stored, objects in Glacier storage classes cannot be read back
+	storedSize, checksum, err := utils.GetObjectChecksum(ctx, s.cfg, bucket, key, sse)
+	if err != nil {
+		return ObjectFailed, fmt.Errorf("verification failed: %w", err)
+	}
+	if storedSize != int64(len(reencrypted)) || checksum != utils.SHA256Base64(reencrypted) {
+		return ObjectFailed, fmt.Errorf("verification failed: stored object does not match the uploaded data")
+	}
+	return ObjectRekeyed, nil
+}
+
+// restoreGlacierObjects initiates the restore of archived objects and returns the keys that are not available yet
+func (s *RekeyService) restoreGlacierObjects(ctx context.Context, bucket string, objects []types.Object, dryRun bool) (map[string]bool, error) {
+	pending := map[string]bool{}
+	for _, obj := range objects {
+		if obj.StorageClass != types.ObjectStorageClassGlacier && obj.StorageClass != types.ObjectStorageClassDeepArchive {
+			continue
+		}
+		key := aws.ToString(obj.Key)
+		restored, err := utils.CheckObjectRestoreStatus(ctx, s.cfg, bucket, key, s.sse)
+		if err == nil && restored {
+			continue
+		}
+		pending[key] = true
+
+		if dryRun {
+			log.Printf("🧊 [DRY-RUN] Would restore %s from %s", key, obj.StorageClass)
+			continue
+		}
+		slog.Info(fmt.Sprintf("🔄 Restoring: %s", key),
+			"event", utils.EventRestore, "bucket", bucket, "key", key, "storageClass", obj.StorageClass, "tier", s.retrievalMode)
+		if err := utils.RestoreObject(ctx, s.cfg, bucket, key, s.retrievalMode, s.restoreExpiresAfterDays); err != nil && !strings.Contains(err.Error(), "RestoreAlreadyInProgress") {
+			slog.Error(fmt.Sprintf("❌ Failed to initiate restore for %s: %v", key, err),
+				"event", utils.EventRestore, "bucket", bucket, "key", key, "error", err)
+		}
+	}
+
+	if len(pending) == 0 || s.autoRetryMinutes == 0 || dryRun {
+		return pending, nil
+	}
+	return pending, s.waitForGlacierRestore(ctx, bucket, pending)
+}
+
+// waitForGlacierRestore checks the pending objects every autoRetryMinutes until all are restored
+func (s *RekeyService) waitForGlacierRestore(ctx context.Context, bucket string, pending map[string]bool) error {
+	log.Printf("🔄 Auto-retry enabled: checking restore status of %d objects every %d minutes", len(pending), s.autoRetryMinutes)
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(s.autoRetryMinutes) * time.Minute):
+		}
+		for key := range pending {
+			if restored, err := utils.CheckObjectRestoreStatus(ctx, s.cfg, bucket, key, s.sse); err == nil && restored {
+				log.Printf("✅ Object restored and available: %s", key)
+				delete(pending, key)
+			}
+		}
+		log.Printf("📊 Restore progress: %d objects still waiting", len(pending))
+	}
+	return nil
+}
+
+// recordObject adds an object result to the report
+func (s *RekeyService) recordObject(bucket, key string, size int64, status string, start time.Time, err error) {
+	s.report.Objects = append(s.report.Objects, &ObjectReport{
+		Bucket:          bucket,
+		Key:             key,
+		Size:            size,
+		Status:          status,
+		Error:           errorString(err),
+		DurationSeconds: durationSeconds(start),
+	})
+	s.report.Totals.Files++
+	switch status {
+	case ObjectRekeyed, ObjectDryRun:
+		s.report.Totals.Succeeded++
+		s.report.Totals.Bytes += size
+	case ObjectSkipped:
+		s.report.Totals.Skipped++
+	default:
+		s.report.Totals.Failed++
+	}
+}
+
+// printSummary prints the object counters of the run
+func (s *RekeyService) printSummary(dryRun bool) {
+	prefix := ""
+	if dryRun {
+		prefix = "[DRY-RUN] "
+	}
+	log.Printf("📊 %sRekey summary: %d rekeyed, %d already encrypted with the new secret, %d failed, %d waiting for Glacier restore",
+		prefix, s.summary.Rekeyed, s.summary.AlreadyRekeyed, s.summary.Failed, s.summary.Pending)
+	if s.summary.LegacyManifests > 0 {
+		slog.Warn(fmt.Sprintf("⚠️ %d manifests were written before the object name key was stored in them, the next backup of their prefix uploads all objects again under new names",
+			s.summary.LegacyManifests), "event", utils.EventEncrypt)
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+// Run and object status values used in reports
+const (
+	StatusSuccess   = "success"
+	StatusPartial   = "partial"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+
+	ObjectUploaded   = "uploaded"
+	ObjectDownloaded = "downloaded"
+	ObjectSkipped    = "skipped"
+	ObjectFailed     = "failed"
+	ObjectDryRun     = "dry-run"
+)
+
+// Sentinel errors used to classify a run
+var (
+	ErrCancelled      = errors.New("cancelled by user")
+	ErrPartialFailure = errors.New("partial failure")
+)
+
+// RunReport is the machine-readable result of a backup or restore run
+type RunReport struct {
+	Mode            string               `json:"mode"`
+	Status          string               `json:"status"`
+	ExitCode        int                  `json:"exitCode"`
+	Error           string               `json:"error,omitempty"`
+	DryRun          bool                 `json:"dryRun"`
+	StartTime       time.Time            `json:"startTime"`
+	EndTime         time.Time            `json:"endTime"`
+	DurationSeconds float64              `json:"durationSeconds"`
+	Totals          ReportTotals         `json:"totals"`
+	Tasks           []*TaskReport        `json:"tasks,omitempty"`
+	Objects         []*ObjectReport      `json:"objects,omitempty"`
+	Destinations    []*DestinationReport `json:"destinations,omitempty"`
+}
+
+// DestinationReport holds the upload results of a run for one bucket
+type DestinationReport struct {
+	Bucket    string `json:"bucket"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+	Skipped   int    `json:"skipped"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// ReportTotals holds the summary counters of a run
+type ReportTotals struct {
+	Succeeded int   `json:"succeeded"`
+	Failed    int   `json:"failed"`
+	Skipped   int   `json:"skipped"`
+	Warnings  int   `json:"warnings"`
+	Files     int   `json:"files"`
+	Bytes     int64 `json:"bytes"`
+}
+
+// TaskReport holds the result of a single backup task
+type TaskReport struct {
+	Bucket          string          `json:"bucket"`
+	Prefix          string          `json:"prefix"`
+	StorageClass    string          `json:"storageClass"`
+	Content         []string        `json:"content"`
+	Status          string          `json:"status"`
+	Error           string          `json:"error,omitempty"`
+	DurationSeconds float64         `json:"durationSeconds"`
+	Bytes           int64           `json:"bytes"`
+	Objects         []*ObjectReport `json:"objects"`
+}
+
+// ObjectReport holds the result of a single uploaded or downloaded object
+type ObjectReport struct {
+	Bucket          string  `json:"bucket"`
+	Key             string  `json:"key"`
+	File            string  `json:"file,omitempty"`
+	Size            int64   `json:"size"`
+	Status          string  `json:"status"`
+	Error           string  `json:"error,omitempty"`
+	ObjectLock      string  `json:"objectLock,omitempty"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// RunError carries the final status of a run that did not succeed
+type RunError struct {
+	Status string
+	Err    error
+}
+
+// Error returns the message of the underlying error
+func (e *RunError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// newRunReport creates a report for a run that starts now
+func newRunReport(mode string, dryRun bool) *RunReport {
+	return &RunReport{
+		Mode:      mode,
+		DryRun:    dryRun,
+		StartTime: time.Now(),
+	}
+}
+
+// finish sets status, exit code and duration, returns a RunError if the run did not succeed
+func (r *RunReport) finish(err error) error {
+	r.EndTime = time.Now()
+	r.DurationSeconds = r.EndTime.Sub(r.StartTime).Seconds()
+	r.Status = classifyRun(err, r.Totals.Succeeded, r.Totals.Failed)
+	r.ExitCode = ExitCodeForStatus(r.Status)
+
+	if err == nil && r.Status == StatusSuccess {
+		return nil
+	}
+	if err == nil {
+		err = ErrPartialFailure
+	}
+	r.Error = err.Error()
+	return &RunError{Status: r.Status, Err: err}
+}
+
+// destination returns the results of a bucket, created on first use
+func (r *RunReport) destination(bucket string) *DestinationReport {
+	for _, destination := range r.Destinations {
+		if destination.Bucket == bucket {
+			return destination
+		}
+	}
+	destination := &DestinationReport{Bucket: bucket, Status: StatusSuccess}
+	r.Destinations = append(r.Destinations, destination)
+	return destination
+}
+
+// record adds an object result to the destination counters
+func (d *DestinationReport) record(status string, size int64, err error) {
+	switch status {
+	case ObjectUploaded, ObjectDryRun:
+		d.Succeeded++
+		d.Bytes += size
+	case ObjectSkipped:
+		d.Skipped++
+	case ObjectFailed:
+		d.Failed++
+		d.Error = errorString(err)
+	}
+	if d.Failed > 0 {
+		d.Status = classifyRun(err, d.Succeeded+d.Skipped, d.Failed)
+	}
+}
+
+// WriteFile writes the report as indented JSON
+func (r *RunReport) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("❌ failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(path, data, utils.DefaultFilePerm); err != nil {
+		return fmt.Errorf("❌ failed to write report: %w", err)
+	}
+	return nil
+}
+
+// classifyRun derives the run status from the returned error and the counters
+func classifyRun(err error, succeeded, failed int) string {
+	switch {
+	case err == nil && failed == 0:
+		return StatusSuccess
+	case errors.Is(err, context.Canceled) || errors.Is(err, ErrCancelled):
+		return StatusCancelled
+	case succeeded > 0:
+		return StatusPartial
+	default:
+		return StatusFailed
+	}
+}
+
+// ExitCodeForStatus maps a run status to the process exit code
+func ExitCodeForStatus(status string) int {
+	switch status {
+	case StatusSuccess:
+		return config.ExitSuccess
+	case StatusPartial:
+		return config.ExitPartialFailure
+	case StatusCancelled:
+		return config.ExitCancelled
+	default:
+		return config.ExitFailure
+	}
+}
+
+// ExitCode maps an error returned by a run to the process exit code
+func ExitCode(err error) int {
+	if err == nil {
+		return config.ExitSuccess
+	}
+
+	var runErr *RunError
+	if errors.As(err, &runErr) {
+		return ExitCodeForStatus(runErr.Status)
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrCancelled) {
+		return config.ExitCancelled
+	}
+	return config.ExitFailure
+}
+
+// durationSeconds returns the elapsed seconds since start
+func durationSeconds(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// errorString returns the message of err or an empty string
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,7 +23,15 @@ import (
 type RestoreService struct {
 	cfg              aws.Config
 	summary          *RestoreSummary
+	report           *RunReport
 	downloadLocation string
+	throttle         *utils.Throttle
+	notifications    []config.Notification
+	hooks            Hooks
+	decryptionSecret string
+	keys             utils.DecryptionKeys
+	sse              *utils.ServerSideEncryption
+	layout           RestoreLayout
 }
 
 type RestoreSummary struct {
@@ -38,10 +49,19 @@ type RestoreSummary struct {
 
 type S3Object struct {
 	Key          string `json:"Key"`
+	Name         string `json:"Name,omitempty"`
 	Size         int64  `json:"Size"`
 	StorageClass string `json:"StorageClass"`
 }
 
+// LocalKey returns the original key of an object with an obfuscated key, otherwise its key
+func (o S3Object) LocalKey() string {
+	if o.Name != "" {
+		return o.Name
+	}
+	return o.Key
+}
+
 type S3Contents struct {
 	Contents []S3Object `json:"Contents"`
 }
@@ -50,10 +70,77 @@ func NewRestoreService(cfg aws.Config) *RestoreService {
 	return &RestoreService{
 		cfg:     cfg,
 		summary: &RestoreSummary{},
+		report:  newRunReport("restore", false),
+		keys:    utils.DecryptionKeys{}.WithMasterKeyCache(),
 	}
 }
 
+// Report returns the machine-readable report of the last run
+func (s *RestoreService) Report() *RunReport {
+	return s.report
+}
+
+// SetTransferLimits sets the download bandwidth limit and transfer windows
+func (s *RestoreService) SetTransferLimits(downloadLimitKBps int64, windows []utils.TransferWindow) {
+	s.throttle = utils.NewThrottle(downloadLimitKBps, windows)
+}
+
+// SetNotifications sets the notifiers informed when Glacier objects are restored
+func (s *RestoreService) SetNotifications(notifications []config.Notification) {
+	s.notifications = notifications
+}
+
+// SetHooks sets the commands run before and after the restore
+func (s *RestoreService) SetHooks(hooks Hooks) {
+	s.hooks = hooks
+}
+
+// SetDecryptionSecret sets the secret or secret reference used to decrypt files instead of prompting for it
+func (s *RestoreService) SetDecryptionSecret(secret string) {
+	s.decryptionSecret = secret
+}
+
+// SetDecryptionKeys sets the identities and keyring passwords used to decrypt files without prompting
+func (s *RestoreService) SetDecryptionKeys(keys utils.DecryptionKeys) {
+	s.keys = keys.WithMasterKeyCache()
+}
+
+// SetServerSideEncryption sets the SSE-C key of objects stored with a customer-provided key
+func (s *RestoreService) SetServerSideEncryption(sse *utils.ServerSideEncryption) {
+	s.sse = sse
+}
+
+// SetLayout sets where restored objects are written instead of their full key below the download location
+func (s *RestoreService) SetLayout(layout RestoreLayout) {
+	s.layout = layout
+}
+
+// ProcessRestore runs the restore, the returned error is a *RunError if the run did not succeed
 func (s *RestoreService) ProcessRestore(ctx context.Context, bucket, prefix, inputFile, downloadLocation string, dryRun, skipDecompression bool, retrievalMode string, restoreExpiresAfterDays int32, autoRetryDownloadMinutes int, restoreWithoutConfirmation bool) error {
+	s.report = newRunReport("restore", dryRun)
+	env := map[string]string{
+		"MODE":        "restore",
+		"BUCKET":      bucket,
+		"PREFIX":      prefix,
+		"DESTINATION": downloadLocation,
+		"DRY_RUN":     strconv.FormatBool(dryRun),
+	}
+	err := runWithHooks(ctx, s.hooks, env, dryRun, func() error {
+		return s.processRestore(ctx, bucket, prefix, inputFile, downloadLocation, dryRun, skipDecompression, retrievalMode, restoreExpiresAfterDays, autoRetryDownloadMinutes, restoreWithoutConfirmation)
+	})
+
+	s.report.Totals = ReportTotals{
+		Succeeded: s.summary.SuccessfulDownloads,
+		Failed:    s.summary.FailedDownloads,
+		Skipped:   s.summary.SkippedFiles,
+		Warnings:  s.summary.Warnings,
+		Files:     s.summary.TotalFiles,
+		Bytes:     s.summary.TotalBytes,
+	}
+	return s.report.finish(err)
+}
+
+func (s *RestoreService) processRestore(ctx context.Context, bucket, prefix, inputFile, downloadLocation string, dryRun, skipDecompression bool, retrievalMode string, restoreExpiresAfterDays int32, autoRetryDownloadMinutes int, restoreWithoutConfirmation bool) error {
 	startTime := time.Now()
 
 	s.downloadLocation = downloadLocation // Store for later use
@@ -91,18 +178,27 @@ func (s *RestoreService) ProcessRestore(ctx context.Context, bucket, prefix, inp
 		return nil // User cancelled restore
 	}
 
+	groups, err := s.layout.groupObjects(downloadLocation, objects)
+	if err != nil {
+		return err
+	}
+
 	// Filter out objects that already have decompressed files
-	filteredObjects := s.filterObjectsWithDecompressedFiles(objects, downloadLocation)
+	var filteredObjects []S3Object
+	for _, group := range groups {
+		group.pending = s.filterObjectsWithDecompressedFiles(group.objects, group.dir)
+		filteredObjects = append(filteredObjects, group.pending...)
+	}
 	if len(filteredObjects) < len(objects) {
 		skippedCount := len(objects) - len(filteredObjects)
 		log.Printf("⏭️ Skipping %d objects (decompressed files already exist)", skippedCount)
 		s.summary.SkippedFiles += skippedCount
 	}
 
-	// Check if any files to be downloaded are encrypted and get password upfront
+	// Check if any files to be downloaded are encrypted and get password upfront (not needed with identities or a keyring)
 	var password string
-	if s.hasEncryptedFiles(filteredObjects) {
-		password, err = s.getDecryptionPassword()
+	if s.hasEncryptedFiles(filteredObjects) && (s.keys.IsEmpty() || s.decryptionSecret != "") {
+		password, err = s.getDecryptionPassword(ctx)
 		if err != nil {
 			return err
 		}
@@ -122,67 +218,120 @@ func (s *RestoreService) ProcessRestore(ctx context.Context, bucket, prefix, inp
 		}
 	}
 
-	for _, obj := range filteredObjects {
-		s.summary.TotalFiles++
-		if dryRun {
-			log.Printf("⬇️ [DRY-RUN] Would download (copy instead): %s (%s)", obj.Key, utils.FormatBytes(obj.Size))
-			// Copy from bucket path to destination
-			if err := utils.CopyFile(filepath.Join(bucket+"/"+obj.Key), filepath.Join(downloadLocation+"/"+obj.Key)); err != nil {
-				return fmt.Errorf("❌ Failed to copy file: %w", err)
-			}
-			s.summary.SuccessfulDownloads++
-			s.summary.TotalBytes += obj.Size
-		} else {
-			if err := s.downloadObject(ctx, bucket, obj.Key, downloadLocation, obj.Size); err != nil {
-				log.Printf("❌ Failed to download %s: %v", obj.Key, err)
-				s.summary.FailedDownloads++
+	for _, group := range groups {
+		for _, obj := range group.pending {
+			s.summary.TotalFiles++
+			objectStart := time.Now()
+			if dryRun {
+				log.Printf("⬇️ [DRY-RUN] Would download (copy instead): %s (%s)", obj.Key, utils.FormatBytes(obj.Size))
+				// Copy from bucket path to destination
+				if err := utils.CopyFile(filepath.Join(bucket+"/"+obj.Key), filepath.Join(group.dir+"/"+obj.LocalKey())); err != nil {
+					s.recordObject(bucket, obj, ObjectFailed, objectStart, err)
+					return fmt.Errorf("❌ Failed to copy file: %w", err)
+				}
+				s.summary.SuccessfulDownloads++
+				s.summary.TotalBytes += obj.Size
+				s.recordObject(bucket, obj, ObjectDryRun, objectStart, nil)
+			} else {
+				status, err := s.downloadObject(ctx, bucket, obj.Key, obj.LocalKey(), group.dir, obj.Size)
+				if err != nil {
+					slog.Error(fmt.Sprintf("❌ Failed to download %s: %v", obj.Key, err),
+						"event", utils.EventDownload, "bucket", bucket, "key", obj.Key, "size", obj.Size, "error", err)
+					s.summary.FailedDownloads++
+					status = ObjectFailed
+					if group.failed == nil {
+						group.failed = make(map[string]bool)
+					}
+					group.failed[obj.Key] = true
+					if ctx.Err() != nil {
+						s.recordObject(bucket, obj, status, objectStart, err)
+						return ctx.Err()
+					}
+				}
+				// Note: SuccessfulDownloads and SkippedFiles are incremented in downloadObject
+				s.recordObject(bucket, obj, status, objectStart, err)
 			}
-			// Note: SuccessfulDownloads and SkippedFiles are incremented in downloadObject
 		}
 	}
 
 	// Track processing time (decryption + combination)
 	processingStart := time.Now()
 
+	for _, group := range groups {
+		if err := s.processDownloads(ctx, group, &password, skipDecompression); err != nil {
+			return err
+		}
+	}
+
+	s.summary.ProcessingTime = time.Since(processingStart)
+	s.summary.TotalTime = time.Since(startTime)
+	s.printSummary(dryRun)
+
+	if s.summary.FailedDownloads > 0 {
+		return fmt.Errorf("❌ %d of %d downloads failed: %w", s.summary.FailedDownloads, s.summary.TotalFiles, ErrPartialFailure)
+	}
+	return nil
+}
+
+// processDownloads decrypts, combines and decompresses the downloaded objects of a group
+func (s *RestoreService) processDownloads(ctx context.Context, group *restoreGroup, password *string, skipDecompression bool) error {
 	// Decrypt encrypted files first (before combining)
-	if password != "" || s.hasEncryptedFilesInDir(downloadLocation) {
-		// If we don't have password yet (files existed locally), get it now
-		if password == "" {
-			password, err = s.getDecryptionPassword()
-			if err != nil {
+	if *password != "" || s.hasEncryptedFiles(group.objects) || (s.layout.IsEmpty() && s.hasEncryptedFilesInDir(group.dir)) {
+		// If we don't have password or keys yet (files existed locally), get it now
+		if *password == "" && s.keys.IsEmpty() {
+			var err error
+			if *password, err = s.getDecryptionPassword(ctx); err != nil {
 				return err
 			}
 		}
-		if err := s.decryptFiles(downloadLocation, password, objects); err != nil {
-			log.Printf("⚠️ Warning: Some files could not be decrypted: %v", err)
+		if err := s.decryptFiles(group.dir, *password, group.objects); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ Warning: Some files could not be decrypted: %v", err), "event", utils.EventDecrypt, "dir", group.dir, "error", err)
 			s.summary.Warnings++
 		}
 	}
 
+	// Only the objects are processed in a layout, targets are not scanned
+	if !s.layout.IsEmpty() {
+		if err := s.finishObjects(group, skipDecompression); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ Warning: Failed to combine or decompress files in %s: %v", group.dir, err), "event", utils.EventCombine, "dir", group.dir, "error", err)
+			s.summary.Warnings++
+		}
+		return nil
+	}
+
 	// Combine split files (including decrypted ones)
-	if err := utils.CombineFiles(downloadLocation); err != nil {
-		log.Printf("⚠️ Warning: Failed to combine files: %v", err)
+	if err := utils.CombineFiles(group.dir); err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ Warning: Failed to combine files: %v", err), "event", utils.EventCombine, "dir", group.dir, "error", err)
 		s.summary.Warnings++
 	}
 
 	// Decompress tar.gz archives (unless skipped)
 	if !skipDecompression {
-		if err := s.decompressArchives(downloadLocation); err != nil {
-			log.Printf("⚠️ Warning: Failed to decompress archives: %v", err)
+		if err := s.decompressArchives(group.dir); err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ Warning: Failed to decompress archives: %v", err), "event", utils.EventExtract, "dir", group.dir, "error", err)
 			s.summary.Warnings++
 		}
 	} else {
 		log.Printf("⏭️ Skipping archive decompression (--skipDecompression flag set)")
 	}
-
-	s.summary.ProcessingTime = time.Since(processingStart)
-	s.summary.TotalTime = time.Since(startTime)
-	s.printSummary(dryRun)
 	return nil
 }
 
+// recordObject adds an object result to the run report
+func (s *RestoreService) recordObject(bucket string, obj S3Object, status string, start time.Time, err error) {
+	s.report.Objects = append(s.report.Objects, &ObjectReport{
+		Bucket:          bucket,
+		Key:             obj.Key,
+		Size:            obj.Size,
+		Status:          status,
+		Error:           errorString(err),
+		DurationSeconds: durationSeconds(start),
+	})
+	recordObjectMetrics(s.report.Mode, status, obj.Size)
+}
+
 func (s *RestoreService) listBuckets(ctx context.Context) error {
-	client := s3.NewFromConfig(s.cfg)
+	client := utils.NewS3Client(s.cfg)
 	result, err := client.ListBuckets(ctx, &s3.ListBucketsInput{})
 	if err != nil {
 		return err
@@ -198,12 +347,7 @@ func (s *RestoreService) listBuckets(ctx context.Context) error {
 			continue
 		}
 
-		regionInfo := utils.GetRegionInfo(region)
-		gdprStatus := "⚠️  Non-GDPR"
-		if regionInfo.GDPRCompliant {
-			gdprStatus = "🔒 GDPR"
-		}
-		fmt.Printf("  %s (region: %s %s %s %s)\n", *bucket.Name, region, regionInfo.Flag, regionInfo.Country, gdprStatus)
+		fmt.Printf("  %s (%s)\n", *bucket.Name, utils.RegionDescription(region))
 	}
 
 	fmt.Printf("\n💡 To restore from a bucket, specify it with the -bucket parameter:\n")
@@ -213,7 +357,11 @@ func (s *RestoreService) listBuckets(ctx context.Context) error {
 
 func (s *RestoreService) getObjectList(ctx context.Context, bucket, prefix, inputFile string) ([]S3Object, error) {
 	if inputFile != "" {
-		return s.loadObjectsFromFile(inputFile)
+		objects, err := s.loadObjectsFromFile(inputFile)
+		if err != nil {
+			return nil, err
+		}
+		return s.resolveObjectNames(ctx, bucket, objects)
 	}
 	return s.listObjects(ctx, bucket, prefix)
 }
@@ -242,23 +390,16 @@ func (s *RestoreService) listObjects(ctx context.Context, bucket, prefix string)
 	// Update service config for subsequent operations
 	s.cfg = regionCfg
 
-	client := s3.NewFromConfig(regionCfg)
 	fmt.Printf("📁 Listing objects in bucket: %s (region: %s)\n", bucket, region)
 
-	input := &s3.ListObjectsV2Input{
-		Bucket: &bucket,
-	}
-	if prefix != "" {
-		input.Prefix = &prefix
-	}
-
-	result, err := client.ListObjectsV2(ctx, input)
+	// Manifests and parts can be beyond the first 1000 keys, all pages are listed
+	listed, err := utils.ListObjects(ctx, regionCfg, bucket, prefix)
 	if err != nil {
 		return nil, err
 	}
 
 	var objects []S3Object
-	for _, obj := range result.Contents {
+	for _, obj := range listed {
 		objects = append(objects, S3Object{
 			Key:          *obj.Key,
 			Size:         *obj.Size,
@@ -266,6 +407,11 @@ func (s *RestoreService) listObjects(ctx context.Context, bucket, prefix string)
 		})
 	}
 
+	objects, err = s.resolveObjectNames(ctx, bucket, objects)
+	if err != nil {
+		return nil, err
+	}
+
 	// Save to file for future use
 	generatedRestoreInputFile := "generated-restore-input.json"
 	if err := s.saveObjectsToFile(objects, generatedRestoreInputFile); err != nil {
@@ -275,7 +421,7 @@ func (s *RestoreService) listObjects(ctx context.Context, bucket, prefix string)
 	// List the objects
 	if len(objects) > 0 {
 		for _, obj := range objects {
-			fmt.Printf("☁️  Found: %s (%s) StorageClass: %s\n", obj.Key, utils.FormatBytes(obj.Size), string(obj.StorageClass))
+			fmt.Printf("☁️  Found: %s (%s) StorageClass: %s\n", obj.LocalKey(), utils.FormatBytes(obj.Size), string(obj.StorageClass))
 		}
 	} else {
 		fmt.Printf("⚠️  No objects found in %s\n", bucket)
@@ -308,6 +454,49 @@ func (s *RestoreService) listObjects(ctx context.Context, bucket, prefix string)
 	return objects, nil
 }
 
+// resolveObjectNames sets the original names of obfuscated objects from the encrypted manifests in the listing,
+// the manifests themselves are not restored
+func (s *RestoreService) resolveObjectNames(ctx context.Context, bucket string, objects []S3Object) ([]S3Object, error) {
+	var manifests, resolved []S3Object
+	for _, obj := range objects {
+		if utils.IsManifestKey(obj.Key) {
+			manifests = append(manifests, obj)
+		} else {
+			resolved = append(resolved, obj)
+		}
+	}
+	if len(manifests) == 0 {
+		return objects, nil
+	}
+
+	if s.keys.IsEmpty() || s.decryptionSecret != "" {
+		password, err := s.getDecryptionPassword(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.keys.Passwords = append(s.keys.Passwords, password)
+	}
+
+	names := make(map[string]string)
+	for _, obj := range manifests {
+		data, err := utils.GetObjectData(ctx, s.cfg, bucket, obj.Key, s.sse, s.throttle)
+		if err != nil {
+			return nil, fmt.Errorf("❌ failed to download manifest %s: %w", obj.Key, err)
+		}
+		manifest, err := utils.DecryptManifest(data, s.keys)
+		if err != nil {
+			return nil, fmt.Errorf("❌ failed to decrypt manifest %s: %w", obj.Key, err)
+		}
+		maps.Copy(names, manifest.Objects)
+	}
+
+	for i := range resolved {
+		resolved[i].Name = names[resolved[i].Key]
+	}
+	log.Printf("🗺️ Resolved object names from %d manifests", len(manifests))
+	return resolved, nil
+}
+
 func (s *RestoreService) saveObjectsToFile(objects []S3Object, filename string) error {
 	contents := S3Contents{Contents: objects}
 	data, err := json.MarshalIndent(contents, "", "  ")
@@ -400,7 +589,12 @@ func (s *RestoreService) hasEncryptedFilesInDir(downloadDir string) bool {
 	return false
 }
 
-func (s *RestoreService) getDecryptionPassword() (string, error) {
+// getDecryptionPassword resolves the configured decryption secret or prompts for the password
+func (s *RestoreService) getDecryptionPassword(ctx context.Context) (string, error) {
+	if s.decryptionSecret != "" {
+		return utils.ResolveSecret(ctx, s.decryptionSecret)
+	}
+
 	fmt.Printf("🔐 Encrypted files detected. Enter decryption password: ")
 	var password string
 	fmt.Scanln(&password)
@@ -414,9 +608,9 @@ func (s *RestoreService) decryptFiles(downloadDir, password string, objects []S3
 	// Filter encrypted files from objects list, excluding those already decrypted
 	var encryptedFiles []S3Object
 	for _, obj := range objects {
-		if strings.HasSuffix(obj.Key, "."+config.EncryptionExt) {
+		if strings.HasSuffix(obj.LocalKey(), "."+config.EncryptionExt) {
 			// Check if decrypted version already exists
-			decryptedKey := strings.TrimSuffix(obj.Key, "."+config.EncryptionExt)
+			decryptedKey := strings.TrimSuffix(obj.LocalKey(), "."+config.EncryptionExt)
 			decryptedPath := filepath.Join(downloadDir, decryptedKey)
 			if _, err := os.Stat(decryptedPath); err == nil {
 				log.Printf("⏭️ Skipping decryption of %s (decrypted version already exists: %s)", obj.Key, decryptedKey)
@@ -433,12 +627,17 @@ func (s *RestoreService) decryptFiles(downloadDir, password string, objects []S3
 
 	log.Printf("🔓 Found %d encrypted files to decrypt from objects list", len(encryptedFiles))
 
+	keys := s.keys
+	if password != "" {
+		keys.Passwords = []string{password}
+	}
+
 	for _, obj := range encryptedFiles {
 		// Build local file path
-		localPath := filepath.Join(downloadDir, obj.Key)
+		localPath := filepath.Join(downloadDir, obj.LocalKey())
 
 		// Calculate decrypted filename by removing .enc extension
-		decryptedName := strings.TrimSuffix(obj.Key, "."+config.EncryptionExt)
+		decryptedName := strings.TrimSuffix(obj.LocalKey(), "."+config.EncryptionExt)
 		decryptedPath := filepath.Join(downloadDir, decryptedName)
 
 		// Skip if decrypted version already exists
@@ -449,25 +648,29 @@ func (s *RestoreService) decryptFiles(downloadDir, password string, objects []S3
 
 		// Check if encrypted file exists locally
 		if _, err := os.Stat(localPath); os.IsNotExist(err) {
-			log.Printf("⚠️ Encrypted file not found locally: %s", obj.Key)
+			slog.Warn(fmt.Sprintf("⚠️ Encrypted file not found locally: %s", obj.Key), "event", utils.EventDecrypt, "key", obj.Key, "file", localPath)
 			continue
 		}
 
-		log.Printf("🔓 Decrypting: %s -> %s", obj.Key, decryptedName)
+		slog.Info(fmt.Sprintf("🔓 Decrypting: %s -> %s", obj.Key, decryptedName),
+			"event", utils.EventDecrypt, "key", obj.Key, "size", obj.Size)
 
 		for {
-			_, err := utils.DecryptFile(localPath, password)
+			_, err := utils.DecryptFileWithKeys(localPath, keys)
 			if err == nil {
 				// Decryption successful, remove encrypted file
 				if err := os.Remove(localPath); err != nil {
-					log.Printf("⚠️ Warning: Could not remove encrypted file %s: %v", obj.Key, err)
+					slog.Warn(fmt.Sprintf("⚠️ Warning: Could not remove encrypted file %s: %v", obj.Key, err),
+						"event", utils.EventDecrypt, "key", obj.Key, "file", localPath, "error", err)
 				}
-				log.Printf("✅ Successfully decrypted: %s", decryptedName)
+				slog.Info(fmt.Sprintf("✅ Successfully decrypted: %s", decryptedName),
+					"event", utils.EventDecrypt, "key", obj.Key, "status", "success")
 				break
 			}
 
 			// Decryption failed, ask for password or skip
-			log.Printf("❌ Failed to decrypt %s: %v", obj.Key, err)
+			slog.Error(fmt.Sprintf("❌ Failed to decrypt %s: %v", obj.Key, err),
+				"event", utils.EventDecrypt, "key", obj.Key, "error", err)
 			fmt.Printf("Enter password for %s (or 'skip' to skip this file): ", obj.Key)
 			var input string
 			fmt.Scanln(&input)
@@ -483,84 +686,86 @@ func (s *RestoreService) decryptFiles(downloadDir, password string, objects []S3
 			}
 
 			// Update password for all subsequent files
-			password = input
+			keys.Passwords = []string{input}
 		}
 	}
 	return nil
 }
 
-func (s *RestoreService) downloadObject(ctx context.Context, bucket, key, downloadDir string, size int64) error {
+func (s *RestoreService) downloadObject(ctx context.Context, bucket, key, localKey, downloadDir string, size int64) (string, error) {
 	// Skip if already exists
-	localPath := fmt.Sprintf("%s/%s", strings.TrimRight(downloadDir, "/"), key)
+	localPath := fmt.Sprintf("%s/%s", strings.TrimRight(downloadDir, "/"), localKey)
 	if _, err := os.Stat(localPath); err == nil {
-		log.Printf("⏭️ Skipping %s (already exists)", key)
+		slog.Info(fmt.Sprintf("⏭️ Skipping %s (already exists)", key),
+			"event", utils.EventSkip, "bucket", bucket, "key", key, "size", size)
 		s.summary.SkippedFiles++
-		return nil
+		return ObjectSkipped, nil
 	}
 
 	// Skip encrypted files if decrypted version already exists
-	if strings.HasSuffix(key, "."+config.EncryptionExt) {
-		decryptedKey := strings.TrimSuffix(key, "."+config.EncryptionExt)
+	if strings.HasSuffix(localKey, "."+config.EncryptionExt) {
+		decryptedKey := strings.TrimSuffix(localKey, "."+config.EncryptionExt)
 		decryptedPath := fmt.Sprintf("%s/%s", strings.TrimRight(downloadDir, "/"), decryptedKey)
 		if _, err := os.Stat(decryptedPath); err == nil {
 			log.Printf("⏭️ Skipping %s (decrypted version already exists: %s)", key, decryptedKey)
 			s.summary.SkippedFiles++
-			return nil
+			return ObjectSkipped, nil
 		}
 	}
 
 	// Skip split files if combined file already exists
 	partPattern := regexp.MustCompile(`^(.+)-part\d{5}(\.` + config.EncryptionExt + `)?$`)
-	if matches := partPattern.FindStringSubmatch(filepath.Base(key)); len(matches) >= 2 {
+	if matches := partPattern.FindStringSubmatch(filepath.Base(localKey)); len(matches) >= 2 {
 		baseName := matches[1]
 		// Build combined file path maintaining directory structure
-		combinedKey := filepath.Join(filepath.Dir(key), baseName)
+		combinedKey := filepath.Join(filepath.Dir(localKey), baseName)
 		combinedPath := fmt.Sprintf("%s/%s", strings.TrimRight(downloadDir, "/"), combinedKey)
 		if _, err := os.Stat(combinedPath); err == nil {
 			log.Printf("⏭️ Skipping %s (combined file already exists: %s)", key, baseName)
 			s.summary.SkippedFiles++
-			return nil
+			return ObjectSkipped, nil
 		}
 	}
 
 	// Skip HowToBuild.txt files if combined file already exists
 	howToBuildPattern := regexp.MustCompile(`^(.+)-HowToBuild\.txt(\.` + config.EncryptionExt + `)?$`)
-	if matches := howToBuildPattern.FindStringSubmatch(filepath.Base(key)); len(matches) >= 2 {
+	if matches := howToBuildPattern.FindStringSubmatch(filepath.Base(localKey)); len(matches) >= 2 {
 		baseName := matches[1]
 		// Build combined file path maintaining directory structure
-		combinedKey := filepath.Join(filepath.Dir(key), baseName)
+		combinedKey := filepath.Join(filepath.Dir(localKey), baseName)
 		combinedPath := fmt.Sprintf("%s/%s", strings.TrimRight(downloadDir, "/"), combinedKey)
 		if _, err := os.Stat(combinedPath); err == nil {
 			log.Printf("⏭️ Skipping %s (combined file already exists: %s)", key, baseName)
 			s.summary.SkippedFiles++
-			return nil
+			return ObjectSkipped, nil
 		}
 	}
 
 	// Create directory if needed
 	dirPath := filepath.Dir(localPath)
 	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		return err
+		return ObjectFailed, err
 	}
 
 	// Check if file already exists locally (skip re-download)
 	if _, err := os.Stat(localPath); err == nil {
 		log.Printf("⏭️ Skipping download: %s (already exists locally)", key)
-		return nil
+		return ObjectSkipped, nil
 	}
 
-	log.Printf("⬇️ Downloading: %s (%s)", key, utils.FormatBytes(size))
+	slog.Info(fmt.Sprintf("⬇️ Downloading: %s (%s)", localKey, utils.FormatBytes(size)),
+		"event", utils.EventDownload, "bucket", bucket, "key", key, "size", size)
 
 	// Track actual download time
 	downloadStart := time.Now()
 
 	// Retry download with exponential backoff for network errors
 	err := utils.RetryWithBackoff(ctx, func() error {
-		return utils.DownloadFile(ctx, s.cfg, bucket, key, localPath)
+		return utils.DownloadFile(ctx, s.cfg, bucket, key, localPath, s.sse, s.throttle)
 	}, fmt.Sprintf("Download %s", key))
 
 	if err != nil {
-		return err
+		return ObjectFailed, err
 	}
 	s.trackActualDownloadTime(time.Since(downloadStart))
 
@@ -570,7 +775,7 @@ func (s *RestoreService) downloadObject(ctx context.Context, bucket, key, downlo
 	}
 
 	s.summary.SuccessfulDownloads++
-	return nil
+	return ObjectDownloaded, nil
 }
 
 // scanLocalDirectory scans a local directory for files (dry-run mode)
@@ -634,30 +839,16 @@ func (s *RestoreService) decompressArchives(downloadDir string) error {
 			return nil
 		}
 
-		// Check if file is a tar.gz archive
-		if strings.HasSuffix(info.Name(), ".tar.gz") {
-			// Check if decompressed version already exists
-			baseName := strings.TrimSuffix(info.Name(), ".tar.gz")
-			decompressedPath := filepath.Join(filepath.Dir(path), baseName)
-			if _, err := os.Stat(decompressedPath); err == nil {
-				log.Printf("⏭️ Skipping decompression of %s (already exists: %s)", info.Name(), baseName)
-				return nil
-			}
-
-			log.Printf("📎 Decompressing: %s", info.Name())
-
-			// Extract to same directory
-			if err := utils.ExtractArchive(path, decompressedPath); err != nil {
-				log.Printf("❌ Failed to decompress %s: %v", info.Name(), err)
-				return nil // Continue with other files
-			}
+		// Compressed streams are plain gzip files
+		if strings.HasSuffix(info.Name(), "."+config.StreamExtension) && !strings.HasSuffix(info.Name(), ".tar.gz") {
+			s.decompressStream(path, info.Name())
+			return nil
+		}
 
-			// Remove the archive after successful extraction
-			if err := os.Remove(path); err != nil {
-				log.Printf("⚠️ Warning: Could not remove archive %s: %v", info.Name(), err)
-			} else {
-				log.Printf("✅ Successfully decompressed and removed: %s", info.Name())
-			}
+		// Check if file is a tar.gz archive, it is extracted into a directory of its name
+		if strings.HasSuffix(info.Name(), ".tar.gz") {
+			extractDir := filepath.Join(filepath.Dir(path), strings.TrimSuffix(info.Name(), ".tar.gz"))
+			s.decompressArchive(path, extractDir, extractDir)
 		}
 
 		return nil
@@ -671,11 +862,54 @@ func (s *RestoreService) decompressArchives(downloadDir string) error {
 	return nil
 }
 
+// decompressArchive extracts a tar.gz archive into extractDir and removes it, unless its decompressed version exists
+func (s *RestoreService) decompressArchive(path, extractDir, decompressedPath string) {
+	name := filepath.Base(path)
+	if _, err := os.Stat(decompressedPath); err == nil {
+		log.Printf("⏭️ Skipping decompression of %s (already exists: %s)", name, decompressedPath)
+		return
+	}
+
+	log.Printf("📎 Decompressing: %s", name)
+	if err := utils.ExtractArchive(path, extractDir); err != nil {
+		slog.Error(fmt.Sprintf("❌ Failed to decompress %s: %v", name, err), "event", utils.EventExtract, "file", path, "error", err)
+		return // Continue with other files
+	}
+
+	// Remove the archive after successful extraction
+	if err := os.Remove(path); err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ Warning: Could not remove archive %s: %v", name, err), "event", utils.EventExtract, "file", path, "error", err)
+	} else {
+		log.Printf("✅ Successfully decompressed and removed: %s", name)
+	}
+}
+
+// decompressStream decompresses a backed up command output or stdin stream next to the downloaded file
+func (s *RestoreService) decompressStream(path, name string) {
+	decompressedPath := strings.TrimSuffix(path, "."+config.StreamExtension)
+	if _, err := os.Stat(decompressedPath); err == nil {
+		log.Printf("⏭️ Skipping decompression of %s (already exists: %s)", name, filepath.Base(decompressedPath))
+		return
+	}
+
+	log.Printf("📎 Decompressing: %s", name)
+	if err := utils.DecompressFile(path, decompressedPath); err != nil {
+		slog.Error(fmt.Sprintf("❌ Failed to decompress %s: %v", name, err), "event", utils.EventExtract, "file", path, "error", err)
+		return
+	}
+
+	if err := os.Remove(path); err != nil {
+		slog.Warn(fmt.Sprintf("⚠️ Warning: Could not remove archive %s: %v", name, err), "event", utils.EventExtract, "file", path, "error", err)
+	} else {
+		log.Printf("✅ Successfully decompressed and removed: %s", name)
+	}
+}
+
 // filterObjectsWithDecompressedFiles removes objects that already have final processed files
 func (s *RestoreService) filterObjectsWithDecompressedFiles(objects []S3Object, downloadDir string) []S3Object {
 	var filtered []S3Object
 	for _, obj := range objects {
-		if !s.finalFileExists(obj.Key, downloadDir) {
+		if !s.finalFileExists(obj.LocalKey(), downloadDir) {
 			filtered = append(filtered, obj)
 		}
 	}
@@ -750,6 +984,15 @@ func (s *RestoreService) finalFileExists(key, downloadDir string) bool {
 		}
 	}
 
+	// For compressed streams, check if the decompressed file exists
+	streamName := strings.TrimSuffix(filepath.Base(key), "."+config.EncryptionExt)
+	if strings.HasSuffix(streamName, "."+config.StreamExtension) && !strings.HasSuffix(streamName, ".tar.gz") {
+		decompressedPath := filepath.Join(downloadDir, filepath.Dir(key), strings.TrimSuffix(streamName, "."+config.StreamExtension))
+		if _, err := os.Stat(decompressedPath); err == nil {
+			return true
+		}
+	}
+
 	// For regular files, check if the file itself exists
 	localPath := filepath.Join(downloadDir, key)
 	if _, err := os.Stat(localPath); err == nil {
@@ -781,9 +1024,10 @@ func (s *RestoreService) handleGlacierRestore(ctx context.Context, bucket string
 	var available []S3Object
 
 	for _, obj := range glacierObjects {
-		restored, err := utils.CheckObjectRestoreStatus(ctx, s.cfg, bucket, obj.Key)
+		restored, err := utils.CheckObjectRestoreStatus(ctx, s.cfg, bucket, obj.Key, s.sse)
 		if err != nil {
-			log.Printf("⚠️ Could not check restore status for %s: %v", obj.Key, err)
+			slog.Warn(fmt.Sprintf("⚠️ Could not check restore status for %s: %v", obj.Key, err),
+				"event", utils.EventRestore, "bucket", bucket, "key", obj.Key, "error", err)
 			needsRestore = append(needsRestore, obj)
 			continue
 		}
@@ -817,7 +1061,7 @@ func (s *RestoreService) handleGlacierRestore(ctx context.Context, bucket string
 		fmt.Scanln(&response)
 
 		if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
-			return fmt.Errorf("restore cancelled by user")
+			return fmt.Errorf("restore %w", ErrCancelled)
 		}
 	}
 
@@ -825,13 +1069,15 @@ func (s *RestoreService) handleGlacierRestore(ctx context.Context, bucket string
 	log.Printf("🔄 Initiating restore for %d objects (mode: %s, expires after: %d days)", len(needsRestore), retrievalMode, restoreExpiresAfterDays)
 
 	for _, obj := range needsRestore {
-		log.Printf("🔄 Restoring: %s", obj.Key)
+		slog.Info(fmt.Sprintf("🔄 Restoring: %s", obj.Key),
+			"event", utils.EventRestore, "bucket", bucket, "key", obj.Key, "storageClass", obj.StorageClass, "tier", retrievalMode)
 		if err := utils.RestoreObject(ctx, s.cfg, bucket, obj.Key, retrievalMode, restoreExpiresAfterDays); err != nil {
 			// Check if restore is already in progress
 			if strings.Contains(err.Error(), "RestoreAlreadyInProgress") {
 				log.Printf("ℹ️ Restore already in progress for: %s", obj.Key)
 			} else {
-				log.Printf("❌ Failed to initiate restore for %s: %v", obj.Key, err)
+				slog.Error(fmt.Sprintf("❌ Failed to initiate restore for %s: %v", obj.Key, err),
+					"event", utils.EventRestore, "bucket", bucket, "key", obj.Key, "error", err)
 			}
 		} else {
 			log.Printf("✅ Restore initiated for: %s", obj.Key)
@@ -880,15 +1126,17 @@ func (s *RestoreService) waitForGlacierRestore(ctx context.Context, bucket strin
 
 	retryInterval := time.Duration(retryMinutes) * time.Minute
 	startTime := time.Now()
+	totalGlacierObjects := len(glacierObjects)
 
 	for {
 		var stillWaiting []S3Object
 
 		// Check status of all Glacier objects
 		for _, obj := range glacierObjects {
-			restored, err := utils.CheckObjectRestoreStatus(ctx, s.cfg, bucket, obj.Key)
+			restored, err := utils.CheckObjectRestoreStatus(ctx, s.cfg, bucket, obj.Key, s.sse)
 			if err != nil {
-				log.Printf("⚠️ Could not check restore status for %s: %v", obj.Key, err)
+				slog.Warn(fmt.Sprintf("⚠️ Could not check restore status for %s: %v", obj.Key, err),
+					"event", utils.EventRestore, "bucket", bucket, "key", obj.Key, "error", err)
 				stillWaiting = append(stillWaiting, obj)
 				continue
 			}
@@ -904,6 +1152,7 @@ func (s *RestoreService) waitForGlacierRestore(ctx context.Context, bucket strin
 		if len(stillWaiting) == 0 {
 			log.Printf("🎉 All Glacier objects are now restored and available for download")
 			s.summary.RestoreWaitTime = time.Since(startTime)
+			s.notifyRestoreReady(ctx, bucket, totalGlacierObjects)
 			return nil
 		}
 
@@ -913,9 +1162,29 @@ func (s *RestoreService) waitForGlacierRestore(ctx context.Context, bucket strin
 
 		// Wait before next check
 		log.Printf("⏰ Waiting %d minutes before next check...", retryMinutes)
-		time.Sleep(retryInterval)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryInterval):
+		}
 
 		// Update the list for next iteration
 		glacierObjects = stillWaiting
 	}
 }
+
+// notifyRestoreReady informs the configured notifiers that the Glacier restore has completed
+func (s *RestoreService) notifyRestoreReady(ctx context.Context, bucket string, objectCount int) {
+	if len(s.notifications) == 0 {
+		return
+	}
+
+	utils.SendNotifications(ctx, s.notifications, utils.NotificationMessage{
+		Event:   config.NotifyOnRestoreReady,
+		Mode:    "restore",
+		Status:  "restored",
+		Subject: fmt.Sprintf("🎉 %s Glacier restore completed", config.AppName),
+		Summary: fmt.Sprintf("Bucket: %s\nObjects: %d\nWait time: %v\nDownloads are starting now.",
+			bucket, objectCount, s.summary.RestoreWaitTime.Round(time.Second)),
+	})
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/robfig/cron/v3"
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+// ValidateInput checks an input file without contacting AWS and returns all problems found
+func ValidateInput(inputFile string) []error {
+	tasks, err := config.LoadTasks(inputFile)
+	if err != nil {
+		return []error{err}
+	}
+
+	var problems []error
+	if _, err := config.LoadNotifications(inputFile); err != nil {
+		problems = append(problems, err)
+	}
+	for i, task := range tasks {
+		for _, err := range validateTask(task) {
+			problems = append(problems, fmt.Errorf("❌ task %d (s3://%s/%s): %w", i+1, task.S3Bucket, task.S3Prefix, err))
+		}
+	}
+	return problems
+}
+
+// validateTask checks the bucket names, storage classes, keys, MFA tokens, Object Lock, local paths, schedule and transfer window of a task
+func validateTask(task config.Task) []error {
+	var problems []error
+
+	for _, destination := range task.UploadDestinations() {
+		if err := config.ValidateBucketName(destination.S3Bucket); err != nil {
+			problems = append(problems, err)
+		}
+		if !config.IsKnownStorageClass(destination.StorageClass) {
+			problems = append(problems, fmt.Errorf("unknown StorageClass '%s'", destination.StorageClass))
+		}
+		if err := utils.ValidateSecretReference(destination.MFAToken); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if _, err := utils.ParseRecipients(task.Recipients); err != nil {
+		problems = append(problems, err)
+	}
+	if utils.IsSecretReference(task.EncryptionSecret) {
+		if err := utils.ValidateSecretReference(task.EncryptionSecret); err != nil {
+			problems = append(problems, err)
+		}
+	} else if err := utils.ValidateEncryptionPassword(task.EncryptionSecret); err != nil {
+		problems = append(problems, err)
+	}
+	if utils.IsSecretReference(task.SSECustomerKey) {
+		if err := utils.ValidateSecretReference(task.SSECustomerKey); err != nil {
+			problems = append(problems, err)
+		}
+	} else if task.SSECustomerKey != "" {
+		if _, err := utils.NewCustomerKeyEncryption(context.Background(), task.SSECustomerKey); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if _, err := utils.NewObjectLock(task.ObjectLockMode, task.ObjectLockRetentionDays.Or(0), task.ObjectLockRetainUntil, task.ObjectLockLegalHold.Or(false)); err != nil {
+		problems = append(problems, err)
+	}
+
+	if len(task.Content) == 0 && len(task.Streams) == 0 {
+		problems = append(problems, fmt.Errorf("no Content or Streams to back up"))
+	}
+	for _, contentPath := range task.Content {
+		if _, err := os.Stat(contentPath); err != nil {
+			problems = append(problems, fmt.Errorf("content path '%s' is not accessible: %w", contentPath, err))
+		}
+	}
+
+	if task.TmpStorageToBuildArchives == "" {
+		problems = append(problems, fmt.Errorf("TmpStorageToBuildArchives is required"))
+	} else if info, err := os.Stat(task.TmpStorageToBuildArchives); err == nil && !info.IsDir() {
+		problems = append(problems, fmt.Errorf("TmpStorageToBuildArchives '%s' is not a directory", task.TmpStorageToBuildArchives))
+	}
+
+	if task.Schedule != "" {
+		if _, err := cron.ParseStandard(task.Schedule); err != nil {
+			problems = append(problems, fmt.Errorf("invalid Schedule '%s': %w", task.Schedule, err))
+		}
+	}
+	if task.TransferWindow != "" {
+		if _, err := utils.ParseTransferWindows(task.TransferWindow); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return problems
+}
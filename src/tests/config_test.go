@@ -43,6 +43,25 @@ func TestConfigValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid endpoint",
+			config: config.Config{
+				Mode:                    "restore",
+				RestoreExpiresAfterDays: 3,
+				Endpoint:                "https://minio.example.com:9000",
+				PathStyle:               true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "endpoint without scheme",
+			config: config.Config{
+				Mode:                    "restore",
+				RestoreExpiresAfterDays: 3,
+				Endpoint:                "minio.example.com:9000",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
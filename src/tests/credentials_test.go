@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+func TestAccessValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		access  config.Access
+		wantErr bool
+	}{
+		{"empty", config.Access{}, false},
+		{"profile and region", config.Access{Profile: "prod", Region: "eu-central-1"}, false},
+		{"role with MFA", config.Access{RoleArn: "arn:aws:iam::123456789012:role/backup", ExternalId: "backup", MFASerial: "arn:aws:iam::111111111111:mfa/admin", MFAToken: "env:MFA_CODE"}, false},
+		{"invalid role", config.Access{RoleArn: "backup"}, true},
+		{"external id without role", config.Access{ExternalId: "backup"}, true},
+		{"MFA without role", config.Access{MFASerial: "arn:aws:iam::111111111111:mfa/admin"}, true},
+		{"MFA token without serial", config.Access{RoleArn: "arn:aws:iam::123456789012:role/backup", MFAToken: "123456"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.access.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Destinations inherit the credentials settings of their task as one unit
+	task := config.Task{S3Bucket: "backup-bucket", StorageClass: "STANDARD", Profile: "prod", RoleArn: "arn:aws:iam::123456789012:role/backup",
+		ExternalId: "backup", Destinations: []config.Destination{{S3Bucket: "offsite-bucket", Profile: "offsite", Region: "eu-west-1"}, {S3Bucket: "copy-bucket"}}}
+	if err := task.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	offsite := task.UploadDestinations()[1].Access()
+	if offsite.Profile != "offsite" || offsite.Region != "eu-west-1" || offsite.RoleArn != "" || offsite.ExternalId != "" {
+		t.Errorf("Destination with its own profile inherited credentials of the task: %+v", offsite)
+	}
+	if copied := task.UploadDestinations()[2].Access(); copied != task.UploadDestinations()[0].Access() {
+		t.Errorf("Destination without credentials settings did not inherit those of the task: %+v", copied)
+	}
+
+	// Role settings of a destination are not combined with the role of the task
+	task.Destinations[0].MFASerial = "arn:aws:iam::111111111111:mfa/admin"
+	if err := task.Validate(); err == nil {
+		t.Errorf("Expected error for a destination with MFASerial but no RoleArn")
+	}
+}
+
+func TestMFAPromptWithoutTerminal(t *testing.T) {
+	cfg, _ := newFakeS3Config(t)
+	cache := utils.NewConfigCache(cfg)
+
+	// Tests do not run with a terminal on stdin, so the prompt fails instead of waiting for input
+	_, err := cache.Session(context.Background(), config.Access{RoleArn: "arn:aws:iam::123456789012:role/backup", MFASerial: "arn:aws:iam::111111111111:mfa/admin"})
+	if err == nil || !strings.Contains(err.Error(), "not a terminal") {
+		t.Errorf("Expected error for an MFA prompt without terminal, got %v", err)
+	}
+}
+
+func TestTaskCredentials(t *testing.T) {
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+	fake.regions["eu-bucket"] = "eu-west-1"
+	t.Setenv("TEST_MFA_CODE", "123456")
+
+	roleTask, inputFile := sseBackupTask(t, t.TempDir())
+	roleTask.RoleArn = "arn:aws:iam::123456789012:role/backup"
+	roleTask.ExternalId = "backup-host"
+	roleTask.MFASerial = "arn:aws:iam::111111111111:mfa/admin"
+	roleTask.MFAToken = "env:TEST_MFA_CODE"
+	roleTask.Destinations = []config.Destination{{S3Bucket: "offsite-bucket"}}
+	regionTask, _ := sseBackupTask(t, t.TempDir())
+	regionTask.S3Bucket = "eu-bucket"
+
+	backup := services.NewBackupService(cfg)
+	backup.SetLocking(true, 0)
+	if err := backup.ProcessTasks(ctx, []config.Task{roleTask, regionTask}, inputFile, false); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	// The role is assumed once for both buckets of the task
+	if len(fake.assumedRoles) != 1 {
+		t.Fatalf("Expected one AssumeRole call, got %d", len(fake.assumedRoles))
+	}
+	params := fake.assumedRoles[0]
+	if params.Get("RoleArn") != roleTask.RoleArn || params.Get("ExternalId") != "backup-host" ||
+		params.Get("SerialNumber") != roleTask.MFASerial || params.Get("TokenCode") != "123456" {
+		t.Errorf("Unexpected AssumeRole parameters %v", params)
+	}
+
+	// Every bucket is accessed in its region with the credentials of its task
+	want := map[string]string{
+		"backup-bucket":  "ASIAROLE/us-east-1",
+		"offsite-bucket": "ASIAROLE/us-east-1",
+		"eu-bucket":      "AKID/eu-west-1",
+	}
+	for bucket, scope := range want {
+		credential := fake.credentials[bucket]
+		key, rest, _ := strings.Cut(credential, "/")
+		_, region, _ := strings.Cut(rest, "/")
+		if key+"/"+region != scope {
+			t.Errorf("Bucket %s accessed with %q, want %s", bucket, credential, scope)
+		}
+	}
+}
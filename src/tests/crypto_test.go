@@ -0,0 +1,260 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/rtitz/aws-s3-backup/utils"
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptTestFile writes content to a file in a temp dir and encrypts it
+func encryptTestFile(t *testing.T, encryptor *utils.Encryptor, content string) string {
+	t.Helper()
+	plainFile := filepath.Join(t.TempDir(), "data.tar.gz")
+	if err := os.WriteFile(plainFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	encryptedFile, err := encryptor.EncryptFile(plainFile)
+	if err != nil {
+		t.Fatalf("EncryptFile failed: %v", err)
+	}
+	os.Remove(plainFile)
+	return encryptedFile
+}
+
+// scryptKDF returns the default scrypt parameters
+func scryptKDF(t *testing.T) utils.KDFParams {
+	t.Helper()
+	kdf, err := utils.DefaultKDF("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kdf
+}
+
+// legacyEncryptedFile writes a file in the v1 format: nonce | ciphertext | tag | salt
+func legacyEncryptedFile(t *testing.T, password, content string, n, p int) string {
+	t.Helper()
+	salt := make([]byte, utils.SaltSize)
+	nonce := make([]byte, 12)
+	rand.Read(salt)
+	rand.Read(nonce)
+	key, err := scrypt.Key([]byte(password), salt, n, utils.ScryptR, p, utils.KeySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+	data := append(gcm.Seal(nonce, nonce, []byte(content), nil), salt...)
+
+	encryptedFile := filepath.Join(t.TempDir(), "legacy.tar.gz.enc")
+	if err := os.WriteFile(encryptedFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return encryptedFile
+}
+
+// assertDecrypts decrypts a file with keys and checks the content
+func assertDecrypts(t *testing.T, encryptedFile string, keys utils.DecryptionKeys, want string) {
+	t.Helper()
+	decryptedFile, err := utils.DecryptFileWithKeys(encryptedFile, keys)
+	if err != nil {
+		t.Fatalf("DecryptFileWithKeys failed: %v", err)
+	}
+	defer os.Remove(decryptedFile)
+	if data, _ := os.ReadFile(decryptedFile); string(data) != want {
+		t.Errorf("Decrypted content = %q, want %q", data, want)
+	}
+}
+
+func TestRecipientEncryption(t *testing.T) {
+	offline, _ := utils.GenerateIdentity()
+	second, _ := utils.GenerateIdentity()
+	other, _ := utils.GenerateIdentity()
+
+	recipients, err := utils.ParseRecipients([]string{offline.Recipient().String(), second.Recipient().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedFile := encryptTestFile(t, utils.NewRecipientEncryptor(recipients), "archive data")
+
+	assertDecrypts(t, encryptedFile, utils.DecryptionKeys{Identities: []*utils.Identity{offline}}, "archive data")
+	assertDecrypts(t, encryptedFile, utils.DecryptionKeys{Identities: []*utils.Identity{other, second}}, "archive data")
+
+	if _, err := utils.DecryptFileWithKeys(encryptedFile, utils.DecryptionKeys{Identities: []*utils.Identity{other}}); err == nil {
+		t.Error("Expected decryption with a foreign identity to fail")
+	}
+	if _, err := utils.DecryptFile(encryptedFile, "Some-Password-123!"); err == nil {
+		t.Error("Expected decryption with a password to fail")
+	}
+
+	// The header is authenticated, changing it must fail the decryption
+	data, _ := os.ReadFile(encryptedFile)
+	data[20] ^= 1
+	os.WriteFile(encryptedFile, data, 0644)
+	if _, err := utils.DecryptFileWithKeys(encryptedFile, utils.DecryptionKeys{Identities: []*utils.Identity{offline}}); err == nil {
+		t.Error("Expected decryption of a modified file to fail")
+	}
+}
+
+func TestPasswordEncryption(t *testing.T) {
+	encryptedFile := encryptTestFile(t, utils.NewPasswordEncryptor("Backup-Secret-2024!", "", scryptKDF(t)), "archive data")
+
+	assertDecrypts(t, encryptedFile, utils.DecryptionKeys{Passwords: []string{"Wrong-Secret-2023!", "Backup-Secret-2024!"}}, "archive data")
+	if _, err := utils.DecryptFile(encryptedFile, "Wrong-Secret-2023!"); err == nil {
+		t.Error("Expected decryption with a wrong password to fail")
+	}
+}
+
+func TestKDFHeader(t *testing.T) {
+	argon2id, err := utils.DefaultKDF("argon2id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := utils.DefaultKDF("bcrypt"); err == nil {
+		t.Error("Expected unknown KDF to fail")
+	}
+
+	encryptedFile := encryptTestFile(t, utils.NewPasswordEncryptor("Backup-Secret-2024!", "", argon2id), "argon2id data")
+	data, _ := os.ReadFile(encryptedFile)
+	if !bytes.Contains(data, []byte(`"algorithm":"argon2id"`)) {
+		t.Errorf("Expected KDF parameters in header, got %q", data[:min(len(data), 200)])
+	}
+	assertDecrypts(t, encryptedFile, utils.DecryptionKeys{Passwords: []string{"Backup-Secret-2024!"}}, "argon2id data")
+
+	// Parameters are read from the header and checked before the key is derived
+	encryptedFile = encryptTestFile(t, utils.NewPasswordEncryptor("Backup-Secret-2024!", "", scryptKDF(t)), "scrypt data")
+	data, _ = os.ReadFile(encryptedFile)
+	os.WriteFile(encryptedFile, bytes.Replace(data, []byte(`"n":131072`), []byte(`"n":131071`), 1), 0644)
+	_, err = utils.DecryptFile(encryptedFile, "Backup-Secret-2024!")
+	if err == nil || !strings.Contains(err.Error(), "unsupported scrypt parameters") {
+		t.Errorf("Expected unsupported parameters error, got %v", err)
+	}
+}
+
+func TestPasswordMasterKeyPerRun(t *testing.T) {
+	encryptor := utils.NewPasswordEncryptor("Backup-Secret-2024!", "", scryptKDF(t))
+	saltPattern := regexp.MustCompile(`"salt":"([^"]+)"`)
+
+	// All parts of a run share the KDF salt, each part has its own subkey salt
+	var parts []string
+	for i := range 3 {
+		encryptedFile := encryptTestFile(t, encryptor, fmt.Sprintf("part %d", i))
+		data, _ := os.ReadFile(encryptedFile)
+		if !bytes.Contains(data, []byte(`"keyCheck"`)) || !bytes.Contains(data, []byte(`"subkeySalt"`)) {
+			t.Fatalf("Expected key check and subkey salt in header, got %q", data[:min(len(data), 300)])
+		}
+		if i > 0 && saltPattern.FindString(string(data)) != saltPattern.FindString(string(parts[0])) {
+			t.Error("Expected parts of one run to share the KDF salt")
+		}
+		parts = append(parts, string(data))
+		assertDecrypts(t, encryptedFile, utils.DecryptionKeys{Passwords: []string{"Backup-Secret-2024!"}}, fmt.Sprintf("part %d", i))
+	}
+
+	// A matching key check with a modified wrapped key reports a damaged file
+	encryptedFile := encryptTestFile(t, encryptor, "damaged")
+	data, _ := os.ReadFile(encryptedFile)
+	position := bytes.Index(data, []byte(`"wrappedKey":"`)) + len(`"wrappedKey":"`)
+	data[position] = map[bool]byte{true: 'B', false: 'A'}[data[position] == 'A']
+	os.WriteFile(encryptedFile, data, 0644)
+	if _, err := utils.DecryptFile(encryptedFile, "Backup-Secret-2024!"); err == nil || !strings.Contains(err.Error(), "damaged") {
+		t.Errorf("Expected damaged file error, got %v", err)
+	}
+}
+
+func TestLegacyFormatParameters(t *testing.T) {
+	// v1 files written on hosts with another CPU count decrypt as well
+	for range 2 {
+		encryptedFile := legacyEncryptedFile(t, "Backup-Secret-2024!", "v1 data", utils.NewScryptN, 2)
+		assertDecrypts(t, encryptedFile, utils.DecryptionKeys{Passwords: []string{"Backup-Secret-2024!"}}, "v1 data")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	identity, _ := utils.GenerateIdentity()
+	keyringFile := filepath.Join(t.TempDir(), "keyring.txt")
+	t.Setenv("BACKUP_SECRET_2025", "Backup-Secret-2025!")
+	content := "# rotated keys\n2024 Backup-Secret-2024!\n2025 env:BACKUP_SECRET_2025\n" + identity.String() + "\n"
+	if err := os.WriteFile(keyringFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := utils.LoadKeyring(context.Background(), keyringFile)
+	if err != nil {
+		t.Fatalf("LoadKeyring failed: %v", err)
+	}
+
+	// Files of every key generation decrypt with the same keyring
+	legacyFile := legacyEncryptedFile(t, "Backup-Secret-2024!", "2024 legacy", utils.NewScryptN, 1)
+	oldFile := encryptTestFile(t, utils.NewPasswordEncryptor("Backup-Secret-2024!", "2024", scryptKDF(t)), "2024 data")
+	newFile := encryptTestFile(t, utils.NewPasswordEncryptor("Backup-Secret-2025!", "2025", scryptKDF(t)), "2025 data")
+	recipientFile := encryptTestFile(t, utils.NewRecipientEncryptor([]*utils.Recipient{identity.Recipient()}), "offline data")
+
+	assertDecrypts(t, legacyFile, keys, "2024 legacy")
+	assertDecrypts(t, oldFile, keys, "2024 data")
+	assertDecrypts(t, newFile, keys, "2025 data")
+	assertDecrypts(t, recipientFile, keys, "offline data")
+
+	// A file with an unknown key ID still decrypts with a plain password
+	assertDecrypts(t, newFile, utils.DecryptionKeys{Passwords: []string{"Backup-Secret-2025!"}}, "2025 data")
+
+	_, err = utils.DecryptFileWithKeys(newFile, utils.DecryptionKeys{KeyedPasswords: map[string]string{"2024": "Backup-Secret-2024!"}})
+	if err == nil || !strings.Contains(err.Error(), "2025") {
+		t.Errorf("Expected error naming key ID 2025, got %v", err)
+	}
+}
+
+func TestLoadKeyringErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing password": "2024\n",
+		"duplicate ID":     "2024 first-secret\n2024 second-secret\n",
+		"invalid identity": utils.SecretKeyPrefix + "INVALID\n",
+		"no keys":          "# empty\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			keyringFile := filepath.Join(t.TempDir(), "keyring.txt")
+			if err := os.WriteFile(keyringFile, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := utils.LoadKeyring(context.Background(), keyringFile); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestIdentityFile(t *testing.T) {
+	identity, _ := utils.GenerateIdentity()
+	identityFile := filepath.Join(t.TempDir(), "identity.txt")
+
+	if err := utils.WriteIdentityFile(identityFile, identity); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.WriteIdentityFile(identityFile, identity); err == nil {
+		t.Error("Expected existing identity file not to be overwritten")
+	}
+
+	identities, err := utils.LoadIdentities(identityFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 1 || identities[0].String() != identity.String() {
+		t.Errorf("Loaded identities do not match")
+	}
+
+	if _, err := utils.ParseRecipient("s3bk1invalid"); err == nil {
+		t.Error("Expected invalid recipient to fail")
+	}
+}
@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+)
+
+func TestDaemonRunsMissedSchedule(t *testing.T) {
+	tmpDir := t.TempDir()
+	contentDir := filepath.Join(tmpDir, "data")
+	os.MkdirAll(contentDir, 0755)
+	os.WriteFile(filepath.Join(contentDir, "file.txt"), []byte("daemon test data"), 0644)
+
+	task := config.Task{
+		S3Bucket:                  "my-s3-backup-bucket",
+		S3Prefix:                  "backup",
+		TmpStorageToBuildArchives: filepath.Join(tmpDir, "tmp"),
+		Schedule:                  "0 3 * * *",
+		Content:                   []string{contentDir},
+	}
+	inputFile := filepath.Join(tmpDir, "input.json")
+	data, _ := json.Marshal(config.Tasks{Tasks: []config.Task{task}})
+	os.WriteFile(inputFile, data, 0644)
+
+	// Last run two days ago, so the daily run is missed and must start immediately
+	stateFile := filepath.Join(tmpDir, "state.json")
+	state := &services.DaemonState{Tasks: map[string]*services.TaskState{
+		services.TaskID(task): {LastStart: time.Now().Add(-48 * time.Hour)},
+	}}
+	if err := state.WriteFile(stateFile); err != nil {
+		t.Fatal(err)
+	}
+
+	finished := make(chan *services.RunReport, 1)
+	daemon := services.NewDaemon(aws.Config{}, inputFile, stateFile, true,
+		func(_ context.Context, _ []config.Notification, report *services.RunReport, _ error) {
+			finished <- report
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- daemon.Run(ctx, nil) }()
+
+	select {
+	case report := <-finished:
+		if report.Status != services.StatusSuccess {
+			t.Errorf("Unexpected run status %s: %s", report.Status, report.Error)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("Missed scheduled run was not started")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Daemon returned error: %v", err)
+	}
+
+	saved, err := services.LoadDaemonState(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskState := saved.Tasks[services.TaskID(task)]
+	if taskState == nil || taskState.LastStatus != services.StatusSuccess || !taskState.NextRun.After(time.Now()) {
+		t.Errorf("Unexpected persisted state: %+v", taskState)
+	}
+}
+
+func TestDaemonRejectsInvalidSchedule(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := filepath.Join(tmpDir, "input.json")
+	data, _ := json.Marshal(config.Tasks{Tasks: []config.Task{{S3Bucket: "bucket", Schedule: "every day", Content: []string{tmpDir}}}})
+	os.WriteFile(inputFile, data, 0644)
+
+	daemon := services.NewDaemon(aws.Config{}, inputFile, "", true, nil)
+	if err := daemon.Run(context.Background(), nil); err == nil {
+		t.Error("Expected error for invalid schedule")
+	}
+}
+
+func TestDaemonStreamTasks(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Two stream-only tasks on the same prefix are scheduled, run and persisted separately
+	var tasks []config.Task
+	for _, name := range []string{"postgres/db.dump", "mysql/db.sql"} {
+		tasks = append(tasks, config.Task{
+			S3Bucket:                  "my-s3-backup-bucket",
+			S3Prefix:                  "db",
+			TmpStorageToBuildArchives: filepath.Join(tmpDir, "tmp"),
+			Schedule:                  "0 3 * * *",
+			Streams:                   []config.StreamSource{{Type: config.StreamCommand, Command: "printf dump", ObjectName: name}},
+		})
+	}
+	if services.TaskID(tasks[0]) == services.TaskID(tasks[1]) {
+		t.Fatalf("Stream tasks share the ID %s", services.TaskID(tasks[0]))
+	}
+	inputFile := filepath.Join(tmpDir, "input.json")
+	data, _ := json.Marshal(config.Tasks{Tasks: tasks})
+	os.WriteFile(inputFile, data, 0644)
+
+	stateFile := filepath.Join(tmpDir, "state.json")
+	state := &services.DaemonState{Tasks: map[string]*services.TaskState{}}
+	for _, task := range tasks {
+		state.Tasks[services.TaskID(task)] = &services.TaskState{LastStart: time.Now().Add(-48 * time.Hour)}
+	}
+	if err := state.WriteFile(stateFile); err != nil {
+		t.Fatal(err)
+	}
+
+	finished := make(chan *services.RunReport, 2)
+	daemon := services.NewDaemon(aws.Config{}, inputFile, stateFile, true,
+		func(_ context.Context, _ []config.Notification, report *services.RunReport, _ error) {
+			finished <- report
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- daemon.Run(ctx, nil) }()
+
+	for range tasks {
+		select {
+		case report := <-finished:
+			if report.Status != services.StatusSuccess {
+				t.Errorf("Unexpected run status %s: %s", report.Status, report.Error)
+			}
+		case <-time.After(30 * time.Second):
+			t.Fatal("Missed scheduled runs were not started")
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Daemon returned error: %v", err)
+	}
+
+	saved, err := services.LoadDaemonState(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range tasks {
+		if taskState := saved.Tasks[services.TaskID(task)]; taskState == nil || taskState.LastStatus != services.StatusSuccess {
+			t.Errorf("Unexpected persisted state of %s: %+v", services.TaskID(task), taskState)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+)
+
+func TestDestinationsValidation(t *testing.T) {
+	task := config.Task{S3Bucket: "backup-bucket", StorageClass: "DEEP_ARCHIVE", Destinations: []config.Destination{
+		{S3Bucket: "offsite-bucket", Region: "eu-west-1"},
+		{S3Bucket: "cold-bucket", StorageClass: "GLACIER_IR", Profile: "offsite"},
+	}}
+	if err := task.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	destinations := task.UploadDestinations()
+	if len(destinations) != 3 || destinations[0].S3Bucket != "backup-bucket" || destinations[1].StorageClass != "DEEP_ARCHIVE" || destinations[2].StorageClass != "GLACIER_IR" {
+		t.Errorf("Unexpected destinations %+v", destinations)
+	}
+
+	task.Destinations = append(task.Destinations, config.Destination{S3Bucket: "backup-bucket"})
+	if err := task.Validate(); err == nil {
+		t.Errorf("Expected error for a bucket used twice")
+	}
+}
+
+func TestDestinationsFanOut(t *testing.T) {
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	task, inputFile := sseBackupTask(t, tmpDir)
+	task.Destinations = []config.Destination{{S3Bucket: "offsite-bucket", StorageClass: "STANDARD_IA"}}
+	tasks := []config.Task{task}
+
+	backup := services.NewBackupService(cfg)
+	if err := backup.ProcessTasks(ctx, tasks, inputFile, false); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	keys := backupObjectKeys(fake)
+	if len(keys) != 4 {
+		t.Fatalf("Expected archive and config copy in both buckets, got %v", keys)
+	}
+	for _, key := range keys {
+		// The config copy is always STANDARD
+		if strings.HasPrefix(key, "offsite-bucket/") && !strings.HasSuffix(key, "input.json") && fake.classes[key] != "STANDARD_IA" {
+			t.Errorf("Object %s stored as %q, want STANDARD_IA", key, fake.classes[key])
+		}
+	}
+
+	report := backup.Report()
+	if len(report.Destinations) != 2 {
+		t.Fatalf("Expected 2 destinations in report, got %+v", report.Destinations)
+	}
+	for _, destination := range report.Destinations {
+		if destination.Status != services.StatusSuccess || destination.Succeeded != 2 {
+			t.Errorf("Unexpected destination result %+v", destination)
+		}
+	}
+
+	// A second run skips the existing objects in every destination
+	backup = services.NewBackupService(cfg)
+	if err := backup.ProcessTasks(ctx, tasks, inputFile, false); err != nil {
+		t.Fatalf("Second backup failed: %v", err)
+	}
+	if totals := backup.Report().Totals; totals.Skipped != 4 || totals.Succeeded != 0 {
+		t.Errorf("Expected 4 skipped objects, got %+v", totals)
+	}
+}
+
+func TestDestinationsPartialFailure(t *testing.T) {
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	fake.readOnly["offsite-bucket"] = true
+
+	task, inputFile := sseBackupTask(t, tmpDir)
+	task.Destinations = []config.Destination{{S3Bucket: "offsite-bucket"}}
+
+	backup := services.NewBackupService(cfg)
+	backup.SetLocking(true, 0)
+	err := backup.ProcessTasks(ctx, []config.Task{task}, inputFile, false)
+	var runErr *services.RunError
+	if !errors.As(err, &runErr) || runErr.Status != services.StatusPartial {
+		t.Fatalf("Expected partial failure, got %v", err)
+	}
+
+	if keys := backupObjectKeys(fake); len(keys) != 2 || !strings.HasPrefix(keys[0], "backup-bucket/") {
+		t.Errorf("Expected archive and config copy in backup-bucket only, got %v", keys)
+	}
+	report := backup.Report()
+	if report.Tasks[0].Status != services.StatusPartial || !strings.Contains(report.Tasks[0].Error, "s3://offsite-bucket") {
+		t.Errorf("Unexpected task result %s: %s", report.Tasks[0].Status, report.Tasks[0].Error)
+	}
+	for _, destination := range report.Destinations {
+		want := services.StatusSuccess
+		if destination.Bucket == "offsite-bucket" {
+			want = services.StatusFailed
+		}
+		if destination.Status != want {
+			t.Errorf("Destination %s has status %s, want %s", destination.Bucket, destination.Status, want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+// endpointRoundTrip backs up a directory to bucket and restores it from a listing of the bucket
+func endpointRoundTrip(t *testing.T, cfg aws.Config, bucket string) {
+	t.Helper()
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	task, inputFile := sseBackupTask(t, tmpDir)
+	task.S3Bucket = bucket
+	backup := services.NewBackupService(cfg)
+	if err := backup.ProcessTasks(ctx, []config.Task{task}, inputFile, false); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	objects, err := utils.ListObjects(ctx, cfg, bucket, task.S3Prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contents services.S3Contents
+	for _, object := range objects {
+		contents.Contents = append(contents.Contents, services.S3Object{
+			Key: aws.ToString(object.Key), Size: aws.ToInt64(object.Size), StorageClass: "STANDARD",
+		})
+	}
+	listing, _ := json.Marshal(contents)
+	listingFile := filepath.Join(tmpDir, "restore.json")
+	if err := os.WriteFile(listingFile, listing, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	destination := filepath.Join(tmpDir, "restore")
+	restore := services.NewRestoreService(cfg)
+	if err := restore.ProcessRestore(ctx, bucket, task.S3Prefix, listingFile, destination, false, false, "bulk", 1, 0, true); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	var found bool
+	filepath.Walk(destination, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Name() == "notes.txt" {
+			content, _ := os.ReadFile(path)
+			found = string(content) == "compliance notes"
+		}
+		return nil
+	})
+	if !found {
+		t.Errorf("Restored notes.txt not found")
+	}
+}
+
+// useS3Endpoint sets the S3 endpoint for the test and static credentials from the environment
+func useS3Endpoint(t *testing.T, endpoint utils.S3Endpoint, accessKey, secretKey string) {
+	t.Helper()
+	t.Setenv("AWS_ACCESS_KEY_ID", accessKey)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", secretKey)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	utils.SetS3Endpoint(endpoint)
+	t.Cleanup(func() { utils.SetS3Endpoint(utils.S3Endpoint{}) })
+}
+
+func TestS3CompatibleEndpoint(t *testing.T) {
+	fake := newFakeS3()
+	fake.pathStyle = true
+	server := httptest.NewTLSServer(fake)
+	t.Cleanup(server.Close)
+
+	caBundle := filepath.Join(t.TempDir(), "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caBundle, certificate, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	useS3Endpoint(t, utils.S3Endpoint{URL: server.URL, PathStyle: true, NoRegionDiscovery: true}, "AKID", "SECRET")
+	if _, err := utils.CreateAWSSession(ctx, "", "garage"); err == nil {
+		t.Fatalf("Expected TLS error without CA bundle")
+	}
+
+	useS3Endpoint(t, utils.S3Endpoint{URL: server.URL, PathStyle: true, CABundle: caBundle, NoRegionDiscovery: true}, "AKID", "SECRET")
+	cfg, err := utils.CreateAWSSession(ctx, "", "garage")
+	if err != nil {
+		t.Fatalf("CreateAWSSession failed: %v", err)
+	}
+	if region, err := utils.GetBucketRegion(ctx, cfg, "backup-bucket"); err != nil || region != "garage" {
+		t.Errorf("GetBucketRegion = %q, %v, want the configured region", region, err)
+	}
+
+	endpointRoundTrip(t, cfg, "backup-bucket")
+	if keys := backupObjectKeys(fake); len(keys) != 2 || !strings.HasPrefix(keys[0], "backup-bucket/backup/") {
+		t.Errorf("Expected archive and config copy in backup-bucket, got %v", keys)
+	}
+	if fake.locations != 0 {
+		t.Errorf("Expected no region lookups, got %d", fake.locations)
+	}
+}
+
+// TestMinIOEndpoint runs a backup and restore against a real S3-compatible storage, e.g.
+// docker run -p 9000:9000 minio/minio server /data and
+// AWS_S3_BACKUP_TEST_ENDPOINT=http://localhost:9000 (credentials default to minioadmin)
+func TestMinIOEndpoint(t *testing.T) {
+	endpoint := os.Getenv("AWS_S3_BACKUP_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("AWS_S3_BACKUP_TEST_ENDPOINT not set")
+	}
+	accessKey, secretKey := os.Getenv("AWS_S3_BACKUP_TEST_ACCESS_KEY"), os.Getenv("AWS_S3_BACKUP_TEST_SECRET_KEY")
+	if accessKey == "" {
+		accessKey, secretKey = "minioadmin", "minioadmin"
+	}
+	region := os.Getenv("AWS_S3_BACKUP_TEST_REGION")
+	if region == "" {
+		region = config.DefaultAWSRegion
+	}
+
+	ctx := context.Background()
+	useS3Endpoint(t, utils.S3Endpoint{URL: endpoint, PathStyle: true, CABundle: os.Getenv("AWS_S3_BACKUP_TEST_CA_BUNDLE")}, accessKey, secretKey)
+	cfg, err := utils.CreateAWSSession(ctx, "", region)
+	if err != nil {
+		t.Fatalf("CreateAWSSession failed: %v", err)
+	}
+
+	bucket := "aws-s3-backup-test"
+	if _, err := utils.GetBucketRegion(ctx, cfg, bucket); err != nil {
+		if err := utils.CreateBucket(ctx, cfg, bucket, region, false); err != nil {
+			t.Fatalf("CreateBucket failed: %v", err)
+		}
+	}
+	endpointRoundTrip(t, cfg, bucket)
+}
@@ -0,0 +1,313 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// fakeS3 is a minimal in-memory S3 that supports conditional PUT and DELETE, listing, Glacier restores
+// and the server-side encryption and Object Lock headers of objects
+type fakeS3 struct {
+	mu         sync.Mutex
+	objects    map[string][]byte
+	classes    map[string]string
+	checksums  map[string]string
+	restored   map[string]bool
+	headers    map[string]http.Header
+	objectLock bool
+	pathStyle  bool
+	// readOnly holds buckets that deny uploads
+	readOnly map[string]bool
+	// regions holds the region of buckets outside us-east-1
+	regions map[string]string
+	// credentials holds the credential scope (access key, date, region) of the last request per bucket
+	credentials map[string]string
+	// assumedRoles holds the parameters of STS AssumeRole calls
+	assumedRoles []url.Values
+	// locations counts bucket region lookups
+	locations int
+	// pageSize limits the keys of a list response like the 1000 keys of S3, 0 means 1000
+	pageSize int
+}
+
+// sseCustomerKeyMD5 is the header that identifies the SSE-C key of an object
+const sseCustomerKeyMD5 = "X-Amz-Server-Side-Encryption-Customer-Key-Md5"
+
+// ServeHTTP handles object requests, the bucket is part of the host name or with path-style addressing the first part of the path
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.pathStyle {
+		bucket, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if bucket == "" && r.Method == http.MethodGet {
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><ListAllMyBucketsResult><Buckets></Buckets></ListAllMyBucketsResult>`)
+			return
+		}
+		r.Host, r.URL.Path = bucket, "/"+path
+	}
+	if r.Host == "sts" {
+		r.ParseForm()
+		f.assumedRoles = append(f.assumedRoles, r.PostForm)
+		fmt.Fprint(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult><Credentials>`+
+			`<AccessKeyId>ASIAROLE</AccessKeyId><SecretAccessKey>SECRET</SecretAccessKey><SessionToken>TOKEN</SessionToken>`+
+			`<Expiration>2099-01-01T00:00:00Z</Expiration></Credentials></AssumeRoleResult></AssumeRoleResponse>`)
+		return
+	}
+	if _, scope, found := strings.Cut(r.Header.Get("Authorization"), "Credential="); found {
+		f.credentials[r.Host], _, _ = strings.Cut(scope, "/s3/")
+	}
+	if r.URL.Query().Has("location") {
+		f.locations++
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><LocationConstraint>%s</LocationConstraint>`, f.regions[r.Host])
+		return
+	}
+	if r.URL.Path == "/" && r.Method == http.MethodHead {
+		return
+	}
+	if r.URL.Query().Has("object-lock") {
+		if !f.objectLock {
+			writeS3Error(w, http.StatusNotFound, "ObjectLockConfigurationNotFoundError")
+			return
+		}
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled></ObjectLockConfiguration>`)
+		return
+	}
+	if r.URL.Query().Has("legal-hold") && r.Method == http.MethodPut {
+		f.putLegalHold(w, r)
+		return
+	}
+	if r.Header.Get("X-Amz-Object-Lock-Mode") != "" && !f.objectLock {
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest")
+		return
+	}
+
+	if r.URL.Query().Get("list-type") == "2" {
+		f.list(w, r.Host, r.URL.Query().Get("prefix"), r.URL.Query().Get("continuation-token"))
+		return
+	}
+
+	key := r.Host + r.URL.Path
+	data, exists := f.objects[key]
+	archived := f.classes[key] == "GLACIER" || f.classes[key] == "DEEP_ARCHIVE"
+	etag := ""
+	if exists {
+		sum := md5.Sum(data)
+		etag = `"` + hex.EncodeToString(sum[:]) + `"`
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch == "*" && exists {
+		writeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+		return
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != etag {
+		writeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+		return
+	}
+	if exists && (r.Method == http.MethodGet || r.Method == http.MethodHead) && r.Header.Get(sseCustomerKeyMD5) != f.headers[key].Get(sseCustomerKeyMD5) {
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest")
+		return
+	}
+	for name, values := range f.headers[key] {
+		if name != "X-Amz-Server-Side-Encryption-Customer-Key" {
+			w.Header()[name] = values
+		}
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if f.readOnly[r.Host] {
+			writeS3Error(w, http.StatusForbidden, "AccessDenied")
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") {
+			body = decodeAWSChunked(body)
+		}
+		f.objects[key] = body
+		f.classes[key] = r.Header.Get("X-Amz-Storage-Class")
+		f.checksums[key] = r.Header.Get("X-Amz-Checksum-Sha256")
+		f.headers[key] = http.Header{}
+		for name, values := range r.Header {
+			if strings.HasPrefix(name, "X-Amz-Server-Side-Encryption") || strings.HasPrefix(name, "X-Amz-Object-Lock") {
+				f.headers[key][name] = values
+			}
+		}
+		delete(f.restored, key)
+		sum := md5.Sum(body)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	case http.MethodGet:
+		if !exists {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if archived && !f.restored[key] {
+			writeS3Error(w, http.StatusForbidden, "InvalidObjectState")
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write(data)
+	case http.MethodHead:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		if class := f.classes[key]; class != "" {
+			w.Header().Set("X-Amz-Storage-Class", class)
+		}
+		if checksum := f.checksums[key]; checksum != "" {
+			w.Header().Set("X-Amz-Checksum-Sha256", checksum)
+		}
+		if f.restored[key] {
+			w.Header().Set("X-Amz-Restore", `ongoing-request="false"`)
+		}
+	case http.MethodPost:
+		if !exists {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		f.restored[key] = true
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// putLegalHold sets the legal hold header returned for an object, like S3 only in buckets with Object Lock
+func (f *fakeS3) putLegalHold(w http.ResponseWriter, r *http.Request) {
+	key := r.Host + r.URL.Path
+	if !f.objectLock {
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest")
+		return
+	}
+	if _, exists := f.objects[key]; !exists {
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	if strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") {
+		body = decodeAWSChunked(body)
+	}
+	status := "OFF"
+	if strings.Contains(string(body), "<Status>ON</Status>") {
+		status = "ON"
+	}
+	if f.headers[key] == nil {
+		f.headers[key] = http.Header{}
+	}
+	f.headers[key].Set("X-Amz-Object-Lock-Legal-Hold", status)
+}
+
+// list writes a ListObjectsV2 response with the objects of the bucket below the prefix, a page of them
+// after the continuation token (the last key of the previous page)
+func (f *fakeS3) list(w http.ResponseWriter, bucket, prefix, token string) {
+	var keys []string
+	for key := range f.objects {
+		if name, found := strings.CutPrefix(key, bucket+"/"); found && strings.HasPrefix(name, prefix) && name > token {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+
+	pageSize := f.pageSize
+	if pageSize == 0 {
+		pageSize = 1000
+	}
+	truncated := ""
+	if len(keys) > pageSize {
+		keys = keys[:pageSize]
+		truncated = fmt.Sprintf(`<NextContinuationToken>%s</NextContinuationToken>`, keys[len(keys)-1])
+	}
+
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult><Name>%s</Name><Prefix>%s</Prefix><KeyCount>%d</KeyCount><IsTruncated>%t</IsTruncated>%s`,
+		bucket, prefix, len(keys), truncated != "", truncated)
+	for _, name := range keys {
+		class := f.classes[bucket+"/"+name]
+		if class == "" {
+			class = "STANDARD"
+		}
+		fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size><StorageClass>%s</StorageClass></Contents>`, name, len(f.objects[bucket+"/"+name]), class)
+	}
+	fmt.Fprint(w, `</ListBucketResult>`)
+}
+
+// decodeAWSChunked returns the payload of a body the SDK sent in chunks with a trailing checksum
+func decodeAWSChunked(body []byte) []byte {
+	var payload []byte
+	for {
+		line, rest, _ := bytes.Cut(body, []byte("\r\n"))
+		sizeHex, _, _ := strings.Cut(string(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size == 0 || int64(len(rest)) < size {
+			return payload
+		}
+		payload = append(payload, rest[:size]...)
+		body = bytes.TrimPrefix(rest[size:], []byte("\r\n"))
+	}
+}
+
+// writeS3Error writes an S3 XML error response
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+// redirectTransport sends all requests to the test server, keeping the original host for the bucket name
+type redirectTransport struct {
+	target *url.URL
+}
+
+// RoundTrip rewrites the request URL to the test server
+func (t *redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Host = strings.Split(r.URL.Host, ".")[0]
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// newFakeS3 returns an empty in-memory S3
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		objects:     make(map[string][]byte),
+		classes:     make(map[string]string),
+		checksums:   make(map[string]string),
+		restored:    make(map[string]bool),
+		headers:     make(map[string]http.Header),
+		readOnly:    make(map[string]bool),
+		regions:     make(map[string]string),
+		credentials: make(map[string]string),
+	}
+}
+
+// newFakeS3Config returns an AWS config that talks to an in-memory S3
+func newFakeS3Config(t *testing.T) (aws.Config, *fakeS3) {
+	t.Helper()
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL)
+	return aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		HTTPClient:  &http.Client{Transport: &redirectTransport{target: target}},
+	}, fake
+}
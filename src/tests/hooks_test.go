@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+func TestRunHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use sh")
+	}
+	ctx := context.Background()
+
+	if err := utils.RunHook(ctx, utils.HookPre, "", time.Second, nil); err != nil {
+		t.Errorf("empty hook should be a no-op, got %v", err)
+	}
+
+	output := filepath.Join(t.TempDir(), "env.txt")
+	env := map[string]string{"BUCKET": "my-bucket", "STATUS": "success"}
+	command := `echo "$AWS_S3_BACKUP_HOOK $AWS_S3_BACKUP_BUCKET $AWS_S3_BACKUP_STATUS" > ` + output
+	if err := utils.RunHook(ctx, utils.HookPost, command, time.Minute, env); err != nil {
+		t.Fatalf("hook failed: %v", err)
+	}
+	data, _ := os.ReadFile(output)
+	if got := strings.TrimSpace(string(data)); got != "post my-bucket success" {
+		t.Errorf("hook environment = %q", got)
+	}
+
+	err := utils.RunHook(ctx, utils.HookPre, "exit 3", time.Minute, nil)
+	if err == nil || !strings.Contains(err.Error(), "exit code 3") {
+		t.Errorf("expected exit code error, got %v", err)
+	}
+
+	err = utils.RunHook(ctx, utils.HookPre, "sleep 5", 100*time.Millisecond, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+}
+
+func TestPreHookCancelled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use sh")
+	}
+	cfg, _ := newFakeS3Config(t)
+	task, inputFile := sseBackupTask(t, t.TempDir())
+	task.PreCommand = "sleep 5"
+	if err := task.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// SIGINT/SIGTERM cancel the run context, the killed pre hook cancels the run instead of failing it
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	backup := services.NewBackupService(cfg)
+	err := backup.ProcessTasks(ctx, []config.Task{task}, inputFile, false)
+	if !errors.Is(err, context.Canceled) || services.ExitCode(err) != config.ExitCancelled {
+		t.Fatalf("Expected cancelled run with exit code %d, got %v (exit code %d)", config.ExitCancelled, err, services.ExitCode(err))
+	}
+	if report := backup.Report(); report.Status != services.StatusCancelled {
+		t.Errorf("Run status = %s, want %s", report.Status, services.StatusCancelled)
+	}
+}
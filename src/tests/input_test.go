@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+)
+
+// writeInputFile writes an input file with the given name and content
+func writeInputFile(t *testing.T, name, content string) string {
+	t.Helper()
+	inputFile := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(inputFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return inputFile
+}
+
+func TestLoadTasksFormats(t *testing.T) {
+	inputs := map[string]string{
+		"legacy.json": `{"tasks": [{"S3Bucket": "my-bucket", "ArchiveSplitEachMB": "100", "CleanupTmpStorage": "false", "Content": ["/data"]}]}`,
+		"typed.json":  `{"tasks": [{"S3Bucket": "my-bucket", "ArchiveSplitEachMB": 100, "CleanupTmpStorage": false, "Content": ["/data"]}]}`,
+		"input.yaml": `
+tasks:
+  - S3Bucket: my-bucket
+    ArchiveSplitEachMB: 100
+    CleanupTmpStorage: no
+    Content:
+      - /data
+`,
+		"input.toml": `
+[[tasks]]
+S3Bucket = "my-bucket"
+ArchiveSplitEachMB = 100
+CleanupTmpStorage = false
+Content = ["/data"]
+`,
+	}
+
+	for name, content := range inputs {
+		t.Run(name, func(t *testing.T) {
+			tasks, err := config.LoadTasks(writeInputFile(t, name, content))
+			if err != nil {
+				t.Fatalf("LoadTasks failed: %v", err)
+			}
+			task := tasks[0]
+			if task.S3Bucket != "my-bucket" || task.ArchiveSplitEachMB.Or(0) != 100 || task.CleanupTmpStorage.Or(true) || task.Content[0] != "/data" {
+				t.Errorf("Unexpected task: %+v", task)
+			}
+			if task.UploadLimitKBps.IsSet() || task.HookTimeoutMinutes.Or(config.DefaultHookTimeoutMinutes) != config.DefaultHookTimeoutMinutes {
+				t.Errorf("Missing settings must be unset: %+v", task)
+			}
+		})
+	}
+}
+
+func TestLoadTasksUnknownFields(t *testing.T) {
+	inputs := map[string]string{
+		"input.json": "{\n  \"tasks\": [\n    {\n      \"S3Bucket\": \"my-bucket\",\n      \"StorageClas\": \"STANDARD\"\n    }\n  ]\n}",
+		"input.yaml": "tasks:\n  - S3Bucket: my-bucket\n\n\n    StorageClas: STANDARD\n",
+		"input.toml": "[[tasks]]\nS3Bucket = \"my-bucket\"\n\n\nStorageClas = \"STANDARD\"\n",
+	}
+
+	for name, content := range inputs {
+		t.Run(name, func(t *testing.T) {
+			_, err := config.LoadTasks(writeInputFile(t, name, content))
+			if err == nil {
+				t.Fatal("Expected unknown field error")
+			}
+			if !strings.Contains(err.Error(), "line 5") || !strings.Contains(err.Error(), "StorageClas") {
+				t.Errorf("Error must name the field and its line: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadTasksInvalidValues(t *testing.T) {
+	inputs := map[string]string{
+		"not a number":   `{"tasks": [{"S3Bucket": "my-bucket", "ArchiveSplitEachMB": "abc"}]}`,
+		"zero split":     `{"tasks": [{"S3Bucket": "my-bucket", "ArchiveSplitEachMB": 0}]}`,
+		"invalid bool":   `{"tasks": [{"S3Bucket": "my-bucket", "CleanupTmpStorage": "maybe"}]}`,
+		"negative limit": `{"tasks": [{"S3Bucket": "my-bucket", "UploadLimitKBps": -1}]}`,
+		"unknown KDF":    `{"tasks": [{"S3Bucket": "my-bucket", "EncryptionSecret": "env:SECRET", "KDF": "bcrypt"}]}`,
+		"key ID alone":   `{"tasks": [{"S3Bucket": "my-bucket", "EncryptionKeyID": "2025"}]}`,
+		"obfuscate only": `{"tasks": [{"S3Bucket": "my-bucket", "ObfuscateObjectKeys": true}]}`,
+		"unknown SSE":    `{"tasks": [{"S3Bucket": "my-bucket", "ServerSideEncryption": "aws:dsse"}]}`,
+		"KMS key alone":  `{"tasks": [{"S3Bucket": "my-bucket", "SSEKMSKeyID": "alias/backup"}]}`,
+		"SSE-C no key":   `{"tasks": [{"S3Bucket": "my-bucket", "ServerSideEncryption": "SSE-C"}]}`,
+		"lock no period": `{"tasks": [{"S3Bucket": "my-bucket", "ObjectLockMode": "COMPLIANCE"}]}`,
+		"lock bad date":  `{"tasks": [{"S3Bucket": "my-bucket", "ObjectLockMode": "GOVERNANCE", "ObjectLockRetainUntil": "31.12.2030"}]}`,
+	}
+
+	for name, content := range inputs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := config.LoadTasks(writeInputFile(t, "input.json", content)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestLoadTasksJSON(t *testing.T) {
+	// Escapes and case-insensitive keys of encoding/json are valid input
+	content := `{"tasks": [{"s3bucket": "my-bucket", "S3Prefix": "hosts\/web1", "Content": ["/data/\ud83d\ude00"]}]}`
+	tasks, err := config.LoadTasks(writeInputFile(t, "input.json", content))
+	if err != nil {
+		t.Fatalf("LoadTasks failed: %v", err)
+	}
+	if tasks[0].S3Bucket != "my-bucket" || tasks[0].S3Prefix != "hosts/web1" || tasks[0].Content[0] != "/data/\U0001F600" {
+		t.Errorf("Unexpected task: %+v", tasks[0])
+	}
+
+	if _, err := config.LoadTasks(writeInputFile(t, "input.json", "{\n  \"tasks\": [\n    {\"S3Bucket\": \"my-bucket\",}\n  ]\n}")); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Syntax error must name its line: %v", err)
+	}
+
+	// All invalid tasks are reported
+	content = `{"tasks": [{"S3Bucket": "my-bucket", "EncryptionKeyID": "2025"}, {"S3Bucket": "my-bucket", "ObjectLockMode": "COMPLIANCE"}]}`
+	if _, err := config.LoadTasks(writeInputFile(t, "input.json", content)); err == nil || !strings.Contains(err.Error(), "task 1") || !strings.Contains(err.Error(), "task 2") {
+		t.Errorf("Expected errors of both tasks, got %v", err)
+	}
+}
+
+func TestEncodeTasksRoundTrip(t *testing.T) {
+	tasks := &config.Tasks{Tasks: []config.Task{{
+		S3Bucket:           "my-bucket",
+		ArchiveSplitEachMB: config.NewInt(100),
+		Content:            []string{"/data"},
+	}}}
+
+	for _, format := range []string{config.FormatJSON, config.FormatYAML, config.FormatTOML} {
+		t.Run(format, func(t *testing.T) {
+			data, err := config.EncodeTasks(format, tasks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := config.LoadTasks(writeInputFile(t, "input."+format, string(data)))
+			if err != nil {
+				t.Fatalf("Encoded tasks cannot be loaded: %v\n%s", err, data)
+			}
+			if loaded[0].ArchiveSplitEachMB.Or(0) != 100 || loaded[0].CleanupTmpStorage.IsSet() {
+				t.Errorf("Unexpected task after round trip: %+v", loaded[0])
+			}
+		})
+	}
+}
+
+func TestValidateBucketName(t *testing.T) {
+	valid := []string{"my-bucket", "backup.example.com", "abc"}
+	invalid := []string{"ab", "My-Bucket", "my_bucket", "-bucket", "bucket-", "my..bucket", "192.168.1.1", "xn--bucket", "bucket-s3alias"}
+
+	for _, name := range valid {
+		if err := config.ValidateBucketName(name); err != nil {
+			t.Errorf("ValidateBucketName(%s) = %v", name, err)
+		}
+	}
+	for _, name := range invalid {
+		if err := config.ValidateBucketName(name); err == nil {
+			t.Errorf("ValidateBucketName(%s) expected error", name)
+		}
+	}
+}
+
+func TestValidateInput(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputFile := writeInputFile(t, "input.yaml", `
+tasks:
+  - S3Bucket: my-bucket
+    StorageClass: DEEP_ARCHIVE
+    TmpStorageToBuildArchives: `+tmpDir+`
+    Schedule: "0 2 * * *"
+    Content:
+      - `+tmpDir+`
+`)
+	if problems := services.ValidateInput(inputFile); len(problems) != 0 {
+		t.Errorf("Expected valid input, got %v", problems)
+	}
+
+	inputFile = writeInputFile(t, "input.yaml", `
+tasks:
+  - S3Bucket: My_Bucket
+    StorageClass: COLD
+    TmpStorageToBuildArchives: `+tmpDir+`
+    Schedule: "every night"
+    Content:
+      - `+filepath.Join(tmpDir, "missing")+`
+`)
+	if problems := services.ValidateInput(inputFile); len(problems) != 4 {
+		t.Errorf("Expected 4 problems, got %d: %v", len(problems), problems)
+	}
+}
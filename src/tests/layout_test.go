@@ -0,0 +1,257 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+func TestLoadPathMappings(t *testing.T) {
+	tmpDir := t.TempDir()
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr bool
+	}{
+		{"json", "mapping.json", `{"mappings": [{"Prefix": "backup/home/alice", "Target": "/home/alice"}]}`, false},
+		{"yaml", "mapping.yaml", "mappings:\n  - Prefix: backup/etc/\n    Target: etc\n", false},
+		{"no mappings", "empty.json", `{"mappings": []}`, true},
+		{"missing target", "target.json", `{"mappings": [{"Prefix": "backup"}]}`, true},
+		{"duplicate prefix", "duplicate.json", `{"mappings": [{"Prefix": "backup/", "Target": "a"}, {"Prefix": "backup", "Target": "b"}]}`, true},
+		{"unknown field", "unknown.json", `{"mappings": [{"Prefix": "backup", "Target": "a", "Mode": "flat"}]}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(tmpDir, tt.file)
+			if err := os.WriteFile(file, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := config.LoadPathMappings(file); (err != nil) != tt.wantErr {
+				t.Errorf("LoadPathMappings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRestoreLayout(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	// A local bucket (dry-run) with the archives of two home directories, one split into parts
+	docs := filepath.Join(tmpDir, "alice", "docs")
+	photos := filepath.Join(tmpDir, "bob", "photos")
+	for _, dir := range []string{docs, photos} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(docs, "notes.txt"), []byte("alice notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	image := make([]byte, 1536*1024)
+	rand.Read(image)
+	if err := os.WriteFile(filepath.Join(photos, "image.raw"), image, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	localBucket := filepath.Join(tmpDir, "bucket")
+	archives := map[string]string{"backup/home/alice/docs.tar.gz": docs, "backup/home/bob/photos.tar.gz": photos}
+	for key, source := range archives {
+		archive := filepath.Join(localBucket, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(archive), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := utils.CreateArchive([]string{source}, archive); err != nil {
+			t.Fatal(err)
+		}
+	}
+	photosArchive := filepath.Join(localBucket, "backup", "home", "bob", "photos.tar.gz")
+	if parts, err := utils.SplitFile(photosArchive, 1); err != nil || len(parts) != 2 {
+		t.Fatalf("SplitFile = %v, %v", parts, err)
+	}
+	os.Remove(photosArchive)
+	if _, err := utils.WriteHowToBuild(photosArchive, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice is restored to her own directory, which holds other data that must not be touched
+	aliceHome := filepath.Join(tmpDir, "home", "alice")
+	if err := os.MkdirAll(aliceHome, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(aliceHome, "other.tar.gz"), []byte("not an archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mappings := []config.PathMapping{{Prefix: "backup/home/alice/", Target: aliceHome}}
+
+	destination := filepath.Join(tmpDir, "restore")
+	restore := services.NewRestoreService(aws.Config{})
+	restore.SetLayout(services.RestoreLayout{StripPrefix: "backup/home", Mappings: mappings})
+	if err := restore.ProcessRestore(ctx, localBucket, "", "", destination, true, false, "bulk", 1, 0, true); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	// Archives are extracted in place, not into a directory of their name
+	if notes, err := os.ReadFile(filepath.Join(aliceHome, "docs", "notes.txt")); err != nil || string(notes) != "alice notes" {
+		t.Errorf("docs not restored to mapping target: %q, %v", notes, err)
+	}
+	if _, err := os.Stat(filepath.Join(aliceHome, "other.tar.gz")); err != nil {
+		t.Errorf("Other data in mapping target was touched: %v", err)
+	}
+	if restored, err := os.ReadFile(filepath.Join(destination, "bob", "photos", "image.raw")); err != nil || !bytes.Equal(restored, image) {
+		t.Errorf("photos not restored without stripped prefix: %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(destination, "bob")); len(entries) != 1 {
+		t.Errorf("Expected only photos in %s, got %d entries", filepath.Join(destination, "bob"), len(entries))
+	}
+}
+
+func TestRestoreLayoutFlat(t *testing.T) {
+	ctx := context.Background()
+	localBucket := t.TempDir()
+	for _, key := range []string{"backup/web1/db.sql.gz", "backup/web2/db.sql.gz", "backup/web2/site.tar.gz"} {
+		path := filepath.Join(localBucket, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(key), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restore := services.NewRestoreService(aws.Config{})
+	restore.SetLayout(services.RestoreLayout{Flat: true})
+	err := restore.ProcessRestore(ctx, localBucket, "", "", t.TempDir(), true, true, "bulk", 1, 0, true)
+	if err == nil || !strings.Contains(err.Error(), "would both be restored") {
+		t.Fatalf("Expected error for two objects with the same name, got %v", err)
+	}
+
+	// With a mapping per host the names are unique again
+	destination := t.TempDir()
+	restore = services.NewRestoreService(aws.Config{})
+	restore.SetLayout(services.RestoreLayout{Flat: true, Mappings: []config.PathMapping{{Prefix: "backup/web2", Target: "web2"}}})
+	if err := restore.ProcessRestore(ctx, localBucket, "", "", destination, true, true, "bulk", 1, 0, true); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	for _, path := range []string{"db.sql.gz", "web2/db.sql.gz", "web2/site.tar.gz"} {
+		if _, err := os.Stat(filepath.Join(destination, path)); err != nil {
+			t.Errorf("Expected %s in destination: %v", path, err)
+		}
+	}
+}
+
+func TestRestoreLayoutMissingPart(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	// Two archives split into three parts, the last part of the second one is missing in the bucket
+	localBucket := filepath.Join(tmpDir, "bucket")
+	data := make([]byte, 2560*1024)
+	rand.Read(data)
+	for _, name := range []string{"complete", "truncated"} {
+		source := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(source, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(source, "data.raw"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		archive := filepath.Join(localBucket, "backup", name+".tar.gz")
+		if err := os.MkdirAll(filepath.Dir(archive), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := utils.CreateArchive([]string{source}, archive); err != nil {
+			t.Fatal(err)
+		}
+		parts, err := utils.SplitFile(archive, 1)
+		if err != nil || len(parts) != 3 {
+			t.Fatalf("SplitFile = %v, %v", parts, err)
+		}
+		os.Remove(archive)
+		if _, err := utils.WriteHowToBuild(archive, len(parts)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Remove(filepath.Join(localBucket, "backup", "truncated.tar.gz-part00003"))
+
+	destination := filepath.Join(tmpDir, "restore")
+	restore := services.NewRestoreService(aws.Config{})
+	restore.SetLayout(services.RestoreLayout{StripPrefix: "backup"})
+	if err := restore.ProcessRestore(ctx, localBucket, "", "", destination, true, false, "bulk", 1, 0, true); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	if restored, err := os.ReadFile(filepath.Join(destination, "complete", "data.raw")); err != nil || !bytes.Equal(restored, data) {
+		t.Errorf("Complete archive not restored: %v", err)
+	}
+	for _, name := range []string{"complete.tar.gz-part00001", "complete.tar.gz" + utils.HowToBuildSuffix} {
+		if _, err := os.Stat(filepath.Join(destination, name)); err == nil {
+			t.Errorf("%s not removed after combining", name)
+		}
+	}
+
+	// The truncated archive is neither combined nor extracted and its parts are kept
+	for _, name := range []string{"truncated.tar.gz", "truncated"} {
+		if _, err := os.Stat(filepath.Join(destination, name)); err == nil {
+			t.Errorf("%s restored from an incomplete archive", name)
+		}
+	}
+	for _, name := range []string{"truncated.tar.gz-part00001", "truncated.tar.gz-part00002", "truncated.tar.gz" + utils.HowToBuildSuffix} {
+		if _, err := os.Stat(filepath.Join(destination, name)); err != nil {
+			t.Errorf("%s removed: %v", name, err)
+		}
+	}
+}
+
+func TestRestoreLayoutRerun(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	// The archive is named after its object key, its content is the directory it was built from
+	source := filepath.Join(tmpDir, "www")
+	if err := os.MkdirAll(source, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "index.html"), []byte("backed up page"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	localBucket := filepath.Join(tmpDir, "bucket")
+	archive := filepath.Join(localBucket, "backup", "site.tar.gz")
+	if err := os.MkdirAll(filepath.Dir(archive), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.CreateArchive([]string{source}, archive); err != nil {
+		t.Fatal(err)
+	}
+
+	destination := filepath.Join(tmpDir, "restore")
+	restored := filepath.Join(destination, "www", "index.html")
+	for run := range 2 {
+		restore := services.NewRestoreService(aws.Config{})
+		restore.SetLayout(services.RestoreLayout{StripPrefix: "backup"})
+		if err := restore.ProcessRestore(ctx, localBucket, "", "", destination, true, false, "bulk", 1, 0, true); err != nil {
+			t.Fatalf("Restore %d failed: %v", run+1, err)
+		}
+		if run == 0 {
+			if err := os.WriteFile(restored, []byte("changed after restore"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// A second run into the same target does not extract over the restored files
+	if content, err := os.ReadFile(restored); err != nil || string(content) != "changed after restore" {
+		t.Errorf("Restored file overwritten by a second run: %q, %v", content, err)
+	}
+}
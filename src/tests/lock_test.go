@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+func TestRepositoryLock(t *testing.T) {
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+
+	lock, _, err := utils.AcquireLock(ctx, cfg, "backup-bucket", "hosts/web1", "backup", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+
+	if _, _, err := utils.AcquireLock(ctx, cfg, "backup-bucket", "hosts/web1", "backup", 15*time.Minute); !errors.Is(err, utils.ErrLocked) {
+		t.Errorf("Expected ErrLocked for second writer, got %v", err)
+	}
+
+	other, _, err := utils.AcquireLock(ctx, cfg, "backup-bucket", "hosts/web2", "backup", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("Lock of another prefix failed: %v", err)
+	}
+	other.Release(ctx)
+
+	if err := lock.Release(ctx); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if len(fake.objects) != 0 {
+		t.Errorf("Lock objects left after release: %v", fake.objects)
+	}
+}
+
+func TestRepositoryLockStaleTakeover(t *testing.T) {
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+
+	stale := utils.LockInfo{Host: "crashed-host", PID: 42, Heartbeat: time.Now().Add(-time.Hour)}
+	data, _ := json.Marshal(stale)
+	fake.objects["backup-bucket/"+utils.LockKey("data")] = data
+
+	lock, _, err := utils.AcquireLock(ctx, cfg, "backup-bucket", "data", "backup", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("Stale lock was not taken over: %v", err)
+	}
+	if err := lock.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.objects["backup-bucket/"+utils.LockKey("data")] = data
+	if err := utils.ForceUnlock(ctx, cfg, "backup-bucket", "data"); err != nil {
+		t.Fatalf("ForceUnlock failed: %v", err)
+	}
+	if len(fake.objects) != 0 {
+		t.Errorf("Lock object left after ForceUnlock")
+	}
+}
+
+func TestRepositoryLockLost(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stream tests use sh")
+	}
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+	interval := utils.LockHeartbeatInterval
+	utils.LockHeartbeatInterval = 50 * time.Millisecond
+	t.Cleanup(func() { utils.LockHeartbeatInterval = interval })
+
+	task, inputFile := sseBackupTask(t, t.TempDir())
+	task.Streams = []config.StreamSource{{Type: config.StreamCommand, Command: "sleep 2; echo dump", ObjectName: "databases/db.sql"}}
+
+	// Another writer takes the lock over while the stream command runs, which changes its ETag
+	lockObject := "backup-bucket/" + utils.LockKey("backup")
+	go func() {
+		for {
+			time.Sleep(20 * time.Millisecond)
+			fake.mu.Lock()
+			if _, found := fake.objects[lockObject]; found {
+				data, _ := json.Marshal(utils.LockInfo{Host: "other-host", PID: 7, Heartbeat: time.Now()})
+				fake.objects[lockObject] = data
+				fake.mu.Unlock()
+				return
+			}
+			fake.mu.Unlock()
+		}
+	}()
+
+	backup := services.NewBackupService(cfg)
+	err := backup.ProcessTasks(ctx, []config.Task{task}, inputFile, false)
+	if !errors.Is(err, utils.ErrLockLost) {
+		t.Fatalf("Expected lock lost error, got %v", err)
+	}
+	if report := backup.Report().Tasks[0]; report.Status != services.StatusFailed || !strings.Contains(report.Error, "lock lost") {
+		t.Errorf("Expected failed task with lock lost error, got %s: %s", report.Status, report.Error)
+	}
+}
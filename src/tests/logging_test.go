@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"encoding/json"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"", slog.LevelInfo, false},
+		{"WARN", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", slog.LevelInfo, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := utils.ParseLogLevel(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLogLevel() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseLogLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// restoreLogging restores the default loggers after the test, SetupLogging replaces them
+func restoreLogging(t *testing.T) {
+	t.Helper()
+	logger, output, flags := slog.Default(), log.Writer(), log.Flags()
+	t.Cleanup(func() {
+		slog.SetDefault(logger)
+		log.SetOutput(output)
+		log.SetFlags(flags)
+	})
+}
+
+func TestJSONLogFile(t *testing.T) {
+	restoreLogging(t)
+	logFile := filepath.Join(t.TempDir(), "backup.log")
+
+	closeLog, err := utils.SetupLogging(config.DefaultLogLevel, utils.LogFormatJSON, logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.Debug("hidden debug line")
+	slog.Info("⬆️ Uploading", "event", utils.EventUpload, "bucket", "my-bucket", "key", "backup/file.tar.gz", "size", 42)
+	closeLog()
+
+	if _, err := utils.SetupLogging("verbose", utils.LogFormatPlain, ""); err == nil {
+		t.Error("Expected error for invalid log level")
+	}
+	if _, err := utils.SetupLogging(config.DefaultLogLevel, utils.LogFormatPlain, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 log line, got %d: %q", len(lines), string(data))
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Log line is not valid JSON: %v", err)
+	}
+	if record["event"] != utils.EventUpload || record["bucket"] != "my-bucket" || record["size"] != float64(42) {
+		t.Errorf("Unexpected structured fields: %v", record)
+	}
+}
+
+func TestJSONLogErrorFields(t *testing.T) {
+	restoreLogging(t)
+	tmpDir := t.TempDir()
+	logFile := filepath.Join(tmpDir, "restore.log")
+
+	closeLog, err := utils.SetupLogging(config.DefaultLogLevel, utils.LogFormatJSON, logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(tmpDir, "broken.tar.gz")
+	if err := os.WriteFile(archive, []byte("not an archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := services.ExtractFiles([]string{archive}, ""); err == nil {
+		t.Error("Expected error for a broken archive")
+	}
+	closeLog()
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Log line is not valid JSON: %v", err)
+		}
+		if record["level"] == "ERROR" {
+			found = record["event"] == utils.EventExtract && record["file"] == archive && record["error"] != nil
+		}
+	}
+	if !found {
+		t.Errorf("No error record with event, file and error fields in:\n%s", data)
+	}
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+func TestMetricsTextFormat(t *testing.T) {
+	metrics := utils.NewMetrics()
+	metrics.AddCounter(utils.MetricRetriesTotal, "Retries", 1)
+	metrics.AddCounter(utils.MetricRetriesTotal, "Retries", 2)
+	metrics.SetGauge(utils.MetricTaskBytes, "Bytes", 1024, "bucket", "my-bucket", "prefix", `back"up`)
+
+	var out strings.Builder
+	if err := metrics.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"# TYPE aws_s3_backup_retries_total counter",
+		"aws_s3_backup_retries_total 3",
+		"# TYPE aws_s3_backup_task_bytes gauge",
+		`aws_s3_backup_task_bytes{bucket="my-bucket",prefix="back\"up"} 1024`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Missing line %q in:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestMetricsKeepPreviousSamples(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aws-s3-backup.prom")
+
+	previous := utils.NewMetrics()
+	previous.SetGauge(utils.MetricTaskLastSuccess, "Last success", 1700000000, "bucket", "a", "prefix", "")
+	previous.SetGauge(utils.MetricTaskLastSuccess, "Last success", 1700000000, "bucket", "b", "prefix", "")
+	if err := previous.WriteTextfile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	current := utils.NewMetrics()
+	current.SetGauge(utils.MetricTaskLastSuccess, "Last success", 1800000000, "bucket", "a", "prefix", "")
+	current.KeepPreviousSamples(path, utils.MetricTaskLastSuccess)
+	if err := current.WriteTextfile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`aws_s3_backup_task_last_success_timestamp_seconds{bucket="a",prefix=""} 1800000000`,
+		`aws_s3_backup_task_last_success_timestamp_seconds{bucket="b",prefix=""} 1700000000`,
+	} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("Missing line %q in:\n%s", line, string(data))
+		}
+	}
+}
+
+func TestMetricsKeepPreviousSamplesAllTasksFailed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aws-s3-backup.prom")
+
+	previous := utils.NewMetrics()
+	services.RecordReportMetrics(previous, &services.RunReport{Mode: "backup", Status: services.StatusSuccess, EndTime: time.Unix(1700000000, 0),
+		Tasks: []*services.TaskReport{{Bucket: "a", Status: services.StatusSuccess}}})
+	if err := previous.WriteTextfile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// No task succeeds, so this run does not create the last success family at all
+	current := utils.NewMetrics()
+	services.RecordReportMetrics(current, &services.RunReport{Mode: "backup", Status: services.StatusFailed, EndTime: time.Unix(1800000000, 0),
+		Tasks: []*services.TaskReport{{Bucket: "a", Status: services.StatusFailed}}})
+	current.KeepPreviousSamples(path, utils.MetricTaskLastSuccess)
+	if err := current.WriteTextfile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"# TYPE aws_s3_backup_task_last_success_timestamp_seconds gauge",
+		`aws_s3_backup_task_last_success_timestamp_seconds{bucket="a",prefix=""} 1700000000`,
+		`aws_s3_backup_task_failures{bucket="a",prefix=""} 1`,
+	} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("Missing line %q in:\n%s", line, string(data))
+		}
+	}
+}
+
+func TestMetricsServerBusyAddress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The address is bound before the server runs, so a busy address fails the start
+	if err := utils.StartMetricsServer(listener.Addr().String()); err == nil {
+		t.Error("Expected error for a busy metrics address")
+	}
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+func TestRenderNotificationBody(t *testing.T) {
+	message := utils.NotificationMessage{Event: "failure", Subject: "Backup \"failed\"", Summary: "line 1\nline 2"}
+
+	body, err := utils.RenderNotificationBody("", message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]string
+	if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+		t.Fatalf("default body is not valid JSON: %v (%s)", err, body)
+	}
+	if decoded["text"] != message.Text() {
+		t.Errorf("text = %q, want %q", decoded["text"], message.Text())
+	}
+
+	body, err = utils.RenderNotificationBody(`{"title": {{json .Subject}}, "event": "{{.Event}}"}`, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != `{"title": "Backup \"failed\"", "event": "failure"}` {
+		t.Errorf("custom body = %s", body)
+	}
+
+	if _, err := utils.RenderNotificationBody("{{.Unknown", message); err == nil {
+		t.Error("expected error for invalid template")
+	}
+}
+
+func TestSendWebhookNotification(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r.Header.Get("Authorization")+" "+string(body))
+	}))
+	defer server.Close()
+
+	notifications := []config.Notification{
+		{Type: "webhook", URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}},
+		{Type: "webhook", URL: server.URL, On: []string{"success"}},
+	}
+	utils.SendNotifications(context.Background(), notifications, utils.NotificationMessage{Event: "failure", Subject: "failed"})
+
+	if len(received) != 1 {
+		t.Fatalf("received %d notifications, want 1", len(received))
+	}
+	if !strings.HasPrefix(received[0], "Bearer token ") || !strings.Contains(received[0], `"failed"`) {
+		t.Errorf("unexpected request: %s", received[0])
+	}
+}
+
+func TestWebhookSecretReferences(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	// URL and header values are resolved like other secrets
+	t.Setenv("TEST_WEBHOOK_URL", server.URL)
+	t.Setenv("TEST_WEBHOOK_AUTH", "Bearer token")
+	notifications := []config.Notification{
+		{Type: "webhook", URL: "env:TEST_WEBHOOK_URL", Headers: map[string]string{"Authorization": "env:TEST_WEBHOOK_AUTH"}},
+	}
+	utils.SendNotifications(context.Background(), notifications, utils.NotificationMessage{Event: "success", Subject: "done"})
+
+	if received != "Bearer token" {
+		t.Errorf("unexpected Authorization header %q", received)
+	}
+}
+
+func TestLoadNotifications(t *testing.T) {
+	tmpDir := t.TempDir()
+	valid := filepath.Join(tmpDir, "valid.json")
+	os.WriteFile(valid, []byte(`{"tasks": [], "notifications": [
+		{"Type": "webhook", "URL": "https://ntfy.sh/backups", "On": ["failure", "warning"]},
+		{"Type": "smtp", "SMTPHost": "mail.example.com", "From": "backup@example.com", "To": ["admin@example.com"]}
+	]}`), 0644)
+
+	notifications, err := config.LoadNotifications(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 2 {
+		t.Fatalf("got %d notifications, want 2", len(notifications))
+	}
+	if notifications[0].NotifiesOn("success") || !notifications[0].NotifiesOn("failure") {
+		t.Error("webhook should notify on failure only")
+	}
+	if !notifications[1].NotifiesOn("success") {
+		t.Error("notifier without On should notify on all events")
+	}
+
+	invalid := filepath.Join(tmpDir, "invalid.json")
+	os.WriteFile(invalid, []byte(`{"notifications": [{"Type": "smtp", "SMTPHost": "mail.example.com"}]}`), 0644)
+	if _, err := config.LoadNotifications(invalid); err == nil {
+		t.Error("expected error for smtp notification without From and To")
+	}
+}
+
+func TestNotificationEvent(t *testing.T) {
+	tests := []struct {
+		status   string
+		warnings int
+		want     string
+	}{
+		{services.StatusSuccess, 0, config.NotifyOnSuccess},
+		{services.StatusSuccess, 2, config.NotifyOnWarning},
+		{services.StatusPartial, 0, config.NotifyOnWarning},
+		{services.StatusFailed, 0, config.NotifyOnFailure},
+		{services.StatusCancelled, 0, config.NotifyOnFailure},
+	}
+
+	for _, tt := range tests {
+		report := &services.RunReport{Status: tt.status, Totals: services.ReportTotals{Warnings: tt.warnings}}
+		if got := services.NotificationEvent(report); got != tt.want {
+			t.Errorf("NotificationEvent(%s, %d warnings) = %s, want %s", tt.status, tt.warnings, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+func TestObjectNamer(t *testing.T) {
+	kdf := scryptKDF(t)
+	legacyKey, err := utils.DeriveNameKey("Obfuscate-Secret-123!", kdf, "backup-bucket", "hosts/web1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameKey, _ := utils.DeriveNameKey("Obfuscate-Secret-123!", kdf, "backup-bucket", "hosts/web1")
+	otherKey, err := utils.NewNameKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	namer := utils.NewObjectNamer(legacyKey, "hosts/web1")
+	same := utils.NewObjectNamer(sameKey, "hosts/web1")
+	other := utils.NewObjectNamer(otherKey, "hosts/web1")
+
+	key := namer.ObjectKey("hosts/web1/home/user/documents.tar.gz.enc")
+	if !strings.HasPrefix(key, "hosts/web1/") || !strings.HasSuffix(key, ".enc") || strings.Contains(key, "documents") {
+		t.Errorf("Unexpected obfuscated key %s", key)
+	}
+	if same.ObjectKey("hosts/web1/home/user/documents.tar.gz.enc") != key {
+		t.Errorf("Obfuscated keys differ between runs")
+	}
+	if other.ObjectKey("hosts/web1/home/user/documents.tar.gz.enc") == key {
+		t.Errorf("Obfuscated keys do not depend on the name key")
+	}
+	if utils.IsManifestKey(key) || !utils.IsManifestKey(utils.ManifestKey("hosts/web1", utils.NewManifest().Created)) {
+		t.Errorf("Manifest keys not recognized")
+	}
+}
+
+func TestObfuscatedBackupAndRestore(t *testing.T) {
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	password := "Hidden-K3ys#Vault-2025"
+
+	sourceDir := filepath.Join(tmpDir, "documents")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "notes.txt"), []byte("secret notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	inputFile := filepath.Join(tmpDir, "input.json")
+	if err := os.WriteFile(inputFile, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tasks := []config.Task{{
+		S3Bucket:                  "backup-bucket",
+		S3Prefix:                  "backup",
+		TrimBeginningOfPathInS3:   tmpDir,
+		StorageClass:              "STANDARD",
+		TmpStorageToBuildArchives: filepath.Join(tmpDir, "build"),
+		EncryptionSecret:          password,
+		ObfuscateObjectKeys:       config.NewBool(true),
+		Content:                   []string{sourceDir},
+	}}
+
+	backup := services.NewBackupService(cfg)
+	if err := backup.ProcessTasks(ctx, tasks, inputFile, false); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	keys := utils.DecryptionKeys{Passwords: []string{password}}
+	var manifests []string
+	for key := range fake.objects {
+		if strings.Contains(key, "documents") || strings.Contains(key, "notes") {
+			t.Errorf("Object key reveals the content path: %s", key)
+		}
+		if utils.IsManifestKey(key) {
+			manifests = append(manifests, key)
+		}
+	}
+	if len(manifests) != 1 {
+		t.Fatalf("Expected one manifest, got %v", manifests)
+	}
+	manifest, err := utils.DecryptManifest(fake.objects[manifests[0]], keys)
+	if err != nil {
+		t.Fatalf("Manifest does not decrypt: %v", err)
+	}
+	if len(manifest.Objects) != 1 {
+		t.Errorf("Expected one object in the manifest, got %v", manifest.Objects)
+	}
+	configCopy, found := fake.objects["backup-bucket/backup/input.json.enc"]
+	if !found || !utils.IsEncryptedWith(configCopy, keys) {
+		t.Errorf("Config copy was not uploaded encrypted")
+	}
+
+	// The obfuscated keys are stable, so a second run skips the existing archive
+	backup = services.NewBackupService(cfg)
+	if err := backup.ProcessTasks(ctx, tasks, inputFile, false); err != nil {
+		t.Fatalf("Second backup failed: %v", err)
+	}
+	if backup.Report().Totals.Skipped != 2 {
+		t.Errorf("Expected archive and config copy to be skipped, got %+v", backup.Report().Totals)
+	}
+
+	var contents services.S3Contents
+	for key, data := range fake.objects {
+		if name, found := strings.CutPrefix(key, "backup-bucket/"); found && !strings.Contains(name, ".lock") {
+			contents.Contents = append(contents.Contents, services.S3Object{Key: name, Size: int64(len(data)), StorageClass: "STANDARD"})
+		}
+	}
+	listing, _ := json.Marshal(contents)
+	listingFile := filepath.Join(tmpDir, "restore.json")
+	if err := os.WriteFile(listingFile, listing, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	destination := filepath.Join(tmpDir, "restore")
+	restore := services.NewRestoreService(cfg)
+	restore.SetDecryptionKeys(keys)
+	if err := restore.ProcessRestore(ctx, "backup-bucket", "backup", listingFile, destination, false, false, "bulk", 1, 0, true); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	restored := map[string]string{}
+	filepath.Walk(destination, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			content, _ := os.ReadFile(path)
+			restored[filepath.ToSlash(strings.TrimPrefix(path, destination))] = string(content)
+		}
+		return nil
+	})
+	var notesFound bool
+	for path, content := range restored {
+		notesFound = notesFound || (strings.HasSuffix(path, "documents/notes.txt") && content == "secret notes")
+	}
+	if !notesFound {
+		t.Errorf("Restored files not found under their original names: %v", restored)
+	}
+	if _, found := restored["/backup/input.json"]; !found {
+		t.Errorf("Config copy not restored: %v", restored)
+	}
+
+	// Without a listing file all pages of the bucket listing are read, the manifest is found on any page
+	fake.pageSize = 1
+	t.Chdir(tmpDir)
+	restore = services.NewRestoreService(cfg)
+	restore.SetDecryptionKeys(keys)
+	if err := restore.ProcessRestore(ctx, "backup-bucket", "backup", "", destination, false, false, "bulk", 1, 0, true); err != nil {
+		t.Fatalf("Restore from bucket listing failed: %v", err)
+	}
+	generated, err := os.ReadFile(filepath.Join(tmpDir, "generated-restore-input.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed services.S3Contents
+	if err := json.Unmarshal(generated, &listed); err != nil {
+		t.Fatal(err)
+	}
+	var archiveFound bool
+	for _, object := range listed.Contents {
+		archiveFound = archiveFound || strings.HasSuffix(object.LocalKey(), "documents.tar.gz.enc")
+	}
+	if len(listed.Contents) != 2 || !archiveFound {
+		t.Errorf("Objects of the bucket listing not resolved to their original names: %+v", listed.Contents)
+	}
+}
+
+// objectKeys returns the keys of the backup objects without manifests
+func objectKeys(fake *fakeS3) map[string]bool {
+	keys := map[string]bool{}
+	for _, key := range backupObjectKeys(fake) {
+		if !utils.IsManifestKey(key) {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+func TestObfuscatedNamesSurviveRekey(t *testing.T) {
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+	oldPassword, newPassword := "Hidden-K3ys#Vault-2025", "Rotated-K3ys#Vault-2026"
+
+	task, inputFile := sseBackupTask(t, t.TempDir())
+	task.EncryptionSecret = oldPassword
+	task.ObfuscateObjectKeys = config.NewBool(true)
+	if err := services.NewBackupService(cfg).ProcessTasks(ctx, []config.Task{task}, inputFile, false); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	before := objectKeys(fake)
+
+	rekey := services.NewRekeyService(cfg, utils.DecryptionKeys{Passwords: []string{oldPassword}}, newPassword,
+		utils.NewPasswordEncryptor(newPassword, "", scryptKDF(t)))
+	if err := rekey.ProcessRekey(ctx, "backup-bucket", "backup", false); err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+
+	// The name key is kept in the rekeyed manifest, so the objects keep their names under the new secret
+	task.EncryptionSecret = newPassword
+	if err := services.NewBackupService(cfg).ProcessTasks(ctx, []config.Task{task}, inputFile, false); err != nil {
+		t.Fatalf("Backup after rekey failed: %v", err)
+	}
+	if after := objectKeys(fake); len(after) != len(before) {
+		t.Errorf("Objects renamed after rekey: %v, before %v", after, before)
+	}
+}
+
+func TestObfuscatedConfigCopy(t *testing.T) {
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+	password := "Hidden-K3ys#Vault-2025"
+
+	// The copy is encrypted if any task obfuscates its object keys, not only the first one
+	plainTask, inputFile := sseBackupTask(t, t.TempDir())
+	hiddenTask, _ := sseBackupTask(t, t.TempDir())
+	hiddenTask.S3Prefix = "hidden"
+	hiddenTask.EncryptionSecret = password
+	hiddenTask.ObfuscateObjectKeys = config.NewBool(true)
+	if err := services.NewBackupService(cfg).ProcessTasks(ctx, []config.Task{plainTask, hiddenTask}, inputFile, false); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	configCopy, found := fake.objects["backup-bucket/backup/input.json.enc"]
+	if !found || !utils.IsEncryptedWith(configCopy, utils.DecryptionKeys{Passwords: []string{password}}) {
+		t.Errorf("Config copy was not uploaded encrypted")
+	}
+	if _, found := fake.objects["backup-bucket/backup/input.json"]; found {
+		t.Errorf("Config copy was uploaded in plaintext")
+	}
+
+	// Tasks with different secrets cannot share the encrypted copy, the run fails before any upload
+	otherTask, _ := sseBackupTask(t, t.TempDir())
+	otherTask.S3Prefix = "other"
+	otherTask.EncryptionSecret = "Other-K3ys#Vault-2025"
+	otherTask.ObfuscateObjectKeys = config.NewBool(true)
+	backup := services.NewBackupService(cfg)
+	err := backup.ProcessTasks(ctx, []config.Task{plainTask, hiddenTask, otherTask}, inputFile, false)
+	if err == nil || !strings.Contains(err.Error(), "different secrets") {
+		t.Errorf("Expected error for tasks with different secrets, got %v", err)
+	}
+	if len(backup.Report().Tasks) != 0 {
+		t.Errorf("Tasks ran despite the invalid config copy encryption")
+	}
+}
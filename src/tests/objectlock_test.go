@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+func TestNewObjectLock(t *testing.T) {
+	if lock, err := utils.NewObjectLock("", 0, "", false); lock != nil || err != nil {
+		t.Errorf("Expected no lock, got %v, %v", lock, err)
+	}
+	if _, err := utils.NewObjectLock(config.ObjectLockGovernance, 0, "2020-01-01", false); err == nil {
+		t.Errorf("Expected error for a retain-until date in the past")
+	}
+	lock, err := utils.NewObjectLock(config.ObjectLockCompliance, 0, "2099-12-31", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.String() != "COMPLIANCE until 2099-12-31T00:00:00Z, legal hold" {
+		t.Errorf("Unexpected description %q", lock.String())
+	}
+}
+
+func TestObjectLockBackup(t *testing.T) {
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	task, inputFile := sseBackupTask(t, tmpDir)
+	task.ObjectLockMode = config.ObjectLockCompliance
+	task.ObjectLockRetentionDays = config.NewInt(30)
+	task.ObjectLockLegalHold = config.NewBool(true)
+	if err := task.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tasks := []config.Task{task}
+
+	// Object Lock can only be enabled when a bucket is created
+	backup := services.NewBackupService(cfg)
+	if err := backup.ProcessTasks(ctx, tasks, inputFile, false); err == nil || !strings.Contains(err.Error(), "has no Object Lock") {
+		t.Fatalf("Expected error for a bucket without Object Lock, got %v", err)
+	}
+
+	fake.objectLock = true
+	backup = services.NewBackupService(cfg)
+	if err := backup.ProcessTasks(ctx, tasks, inputFile, false); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	keys := backupObjectKeys(fake)
+	if len(keys) != 2 {
+		t.Fatalf("Expected archive and config copy, got %v", keys)
+	}
+	for _, key := range keys {
+		headers := fake.headers[key]
+		until, err := time.Parse(time.RFC3339, headers.Get("X-Amz-Object-Lock-Retain-Until-Date"))
+		if headers.Get("X-Amz-Object-Lock-Mode") != "COMPLIANCE" || headers.Get("X-Amz-Object-Lock-Legal-Hold") != "ON" ||
+			err != nil || time.Until(until) < 29*24*time.Hour || time.Until(until) > 31*24*time.Hour {
+			t.Errorf("Object %s not stored with Object Lock: %v", key, headers)
+		}
+
+		lock, err := utils.GetObjectLock(ctx, cfg, "backup-bucket", strings.TrimPrefix(key, "backup-bucket/"), nil)
+		if err != nil || lock == nil || lock.Mode != types.ObjectLockModeCompliance || !lock.LegalHold {
+			t.Errorf("GetObjectLock(%s) = %v, %v", key, lock, err)
+		}
+	}
+
+	// The report holds the lock S3 returns, also for objects that already existed
+	for _, run := range []string{"first", "second"} {
+		if run == "second" {
+			backup = services.NewBackupService(cfg)
+			if err := backup.ProcessTasks(ctx, tasks, inputFile, false); err != nil {
+				t.Fatalf("Second backup failed: %v", err)
+			}
+		}
+		report := backup.Report()
+		for _, object := range append(report.Objects, report.Tasks[0].Objects...) {
+			if !strings.HasPrefix(object.ObjectLock, "COMPLIANCE until ") || !strings.HasSuffix(object.ObjectLock, ", legal hold") {
+				t.Errorf("Object lock of %s not reported in %s run: %q", object.Key, run, object.ObjectLock)
+			}
+		}
+	}
+
+	// Legal holds are cleared and set again for all objects below the prefix
+	if err := utils.SetLegalHolds(ctx, cfg, "backup-bucket", "backup", false, false); err != nil {
+		t.Fatalf("Clearing legal holds failed: %v", err)
+	}
+	for _, key := range keys {
+		lock, err := utils.GetObjectLock(ctx, cfg, "backup-bucket", strings.TrimPrefix(key, "backup-bucket/"), nil)
+		if err != nil || lock == nil || lock.LegalHold || lock.Mode != types.ObjectLockModeCompliance {
+			t.Errorf("Legal hold of %s not cleared: %v, %v", key, lock, err)
+		}
+	}
+	if err := utils.SetLegalHolds(ctx, cfg, "backup-bucket", "backup", true, false); err != nil {
+		t.Fatalf("Setting legal holds failed: %v", err)
+	}
+	for _, key := range keys {
+		if lock, err := utils.GetObjectLock(ctx, cfg, "backup-bucket", strings.TrimPrefix(key, "backup-bucket/"), nil); err != nil || lock == nil || !lock.LegalHold {
+			t.Errorf("Legal hold of %s not set: %v, %v", key, lock, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+func TestOfflineRecovery(t *testing.T) {
+	tmpDir := t.TempDir()
+	password := "OfflineRecovery123!"
+
+	// Build what a backup uploads: an archive split into encrypted parts
+	sourceDir := filepath.Join(tmpDir, "documents")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "notes.txt"), []byte("offline content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(tmpDir, "documents.tar.gz")
+	if err := utils.CreateArchive([]string{sourceDir}, archive); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(archive)
+
+	copyDir := filepath.Join(tmpDir, "copy")
+	if err := os.MkdirAll(copyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	encryptor := utils.NewPasswordEncryptor(password, "", scryptKDF(t))
+	var encrypted, parts []string
+	for i, chunk := range [][]byte{data[:len(data)/2], data[len(data)/2:]} {
+		part := filepath.Join(copyDir, "documents.tar.gz-part0000"+string(rune('1'+i)))
+		if err := os.WriteFile(part, chunk, 0644); err != nil {
+			t.Fatal(err)
+		}
+		encryptedPart, err := encryptor.EncryptFile(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(part)
+		encrypted = append(encrypted, encryptedPart)
+		parts = append(parts, part)
+	}
+
+	if err := services.DecryptFiles(encrypted, utils.DecryptionKeys{Passwords: []string{"WrongPassword123!"}}); services.ExitCode(err) != 1 {
+		t.Errorf("Expected failure with a wrong password, got %v", err)
+	}
+	if err := services.DecryptFiles(encrypted, utils.DecryptionKeys{Passwords: []string{password}}); err != nil {
+		t.Fatalf("DecryptFiles failed: %v", err)
+	}
+	if err := services.DecryptFiles(encrypted[:1], utils.DecryptionKeys{Passwords: []string{password}}); err == nil {
+		t.Errorf("Existing decrypted file was overwritten")
+	}
+
+	if err := services.CombineFiles(parts[1:]); err == nil {
+		t.Errorf("Expected error for missing first part")
+	}
+	if err := services.CombineFiles(parts); err != nil {
+		t.Fatalf("CombineFiles failed: %v", err)
+	}
+	for _, part := range parts {
+		if _, err := os.Stat(part); err != nil {
+			t.Errorf("Part %s was removed: %v", part, err)
+		}
+	}
+
+	destination := filepath.Join(tmpDir, "recovered")
+	if err := services.ExtractFiles([]string{filepath.Join(copyDir, "documents.tar.gz")}, destination); err != nil {
+		t.Fatalf("ExtractFiles failed: %v", err)
+	}
+	var found []string
+	filepath.Walk(destination, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Name() == "notes.txt" {
+			found = append(found, path)
+		}
+		return nil
+	})
+	if len(found) != 1 {
+		t.Fatalf("Expected one extracted notes.txt, found %v", found)
+	}
+	if content, _ := os.ReadFile(found[0]); string(content) != "offline content" {
+		t.Errorf("Extracted content mismatch: %q", content)
+	}
+}
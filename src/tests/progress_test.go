@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+func TestEstimateRemaining(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int64
+		total   int64
+		rate    int64
+		want    time.Duration
+	}{
+		{"half done", 50, 100, 10, 5 * time.Second},
+		{"finished", 100, 100, 10, 0},
+		{"no throughput yet", 0, 100, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := utils.EstimateRemaining(tt.current, tt.total, tt.rate)
+			if got != tt.want {
+				t.Errorf("EstimateRemaining() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBytesPerSecond(t *testing.T) {
+	if got := utils.BytesPerSecond(1000, 2*time.Second); got != 500 {
+		t.Errorf("BytesPerSecond() = %d, want 500", got)
+	}
+	if got := utils.BytesPerSecond(1000, 0); got != 0 {
+		t.Errorf("BytesPerSecond() with zero duration = %d, want 0", got)
+	}
+}
+
+func TestProgressReaderKeepsData(t *testing.T) {
+	data := []byte("Hello World! This is progress test data.")
+	progress := utils.StartProgress("test", int64(len(data)))
+	defer progress.Finish()
+
+	got, err := io.ReadAll(progress.Reader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("Progress reader changed the data")
+	}
+}
+
+func TestProgressReadSeekerRetry(t *testing.T) {
+	data := []byte("Upload data that is read again after a failed attempt.")
+	progress := utils.StartProgress("test", int64(len(data)))
+	defer progress.Finish()
+	if progress == nil {
+		t.Skip("progress reporting disabled")
+	}
+
+	// A retry seeks back to the start, the bytes of the failed attempt are not counted twice
+	body := progress.ReadSeeker(bytes.NewReader(data))
+	if _, err := io.ReadAll(body); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if got := progress.Bytes(); got != 0 {
+		t.Errorf("Bytes() after Seek(0) = %d, want 0", got)
+	}
+	if _, err := io.ReadAll(body); err != nil {
+		t.Fatal(err)
+	}
+	if got := progress.Bytes(); got != int64(len(data)) {
+		t.Errorf("Bytes() after retry = %d, want %d", got, len(data))
+	}
+}
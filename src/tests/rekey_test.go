@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+// rekeyStatuses returns the report status of every object of a rekey run
+func rekeyStatuses(report *services.RunReport) map[string]string {
+	statuses := map[string]string{}
+	for _, obj := range report.Objects {
+		statuses[obj.Key] = obj.Status
+	}
+	return statuses
+}
+
+func TestRekey(t *testing.T) {
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+	oldPassword, newPassword := "OldBackupPassword123!", "NewBackupPassword456!"
+
+	legacy, err := os.ReadFile(legacyEncryptedFile(t, oldPassword, "legacy content", 32768, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldEncryptor := utils.NewPasswordEncryptor(oldPassword, "2024", scryptKDF(t))
+	current, err := oldEncryptor.EncryptData([]byte("current content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archived, err := oldEncryptor.EncryptData([]byte("archived content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.objects["backup-bucket/data/legacy.tar.gz.enc"] = legacy
+	fake.objects["backup-bucket/data/current.tar.gz.enc"] = current
+	fake.objects["backup-bucket/data/archived.tar.gz.enc"] = archived
+	fake.classes["backup-bucket/data/archived.tar.gz.enc"] = "DEEP_ARCHIVE"
+	fake.objects["backup-bucket/data/notes.txt"] = []byte("not encrypted")
+
+	oldKeys := utils.DecryptionKeys{Passwords: []string{oldPassword}}
+	newKeys := utils.DecryptionKeys{Passwords: []string{newPassword}}
+	rekey := func() (*services.RunReport, error) {
+		svc := services.NewRekeyService(cfg, oldKeys, newPassword, utils.NewPasswordEncryptor(newPassword, "2025", scryptKDF(t)))
+		err := svc.ProcessRekey(ctx, "backup-bucket", "data", false)
+		return svc.Report(), err
+	}
+
+	// The archived object is restored first and rekeyed by the next run
+	report, err := rekey()
+	if !errors.Is(err, services.ErrPartialFailure) {
+		t.Fatalf("Expected partial failure while the restore is pending, got %v", err)
+	}
+	statuses := rekeyStatuses(report)
+	if statuses["data/legacy.tar.gz.enc"] != services.ObjectRekeyed || statuses["data/current.tar.gz.enc"] != services.ObjectRekeyed {
+		t.Errorf("Expected legacy and current objects to be rekeyed, got %v", statuses)
+	}
+	if statuses["data/archived.tar.gz.enc"] != services.ObjectSkipped || !fake.restored["backup-bucket/data/archived.tar.gz.enc"] {
+		t.Errorf("Expected archived object to be restored and skipped, got %v", statuses)
+	}
+	if _, found := statuses["data/notes.txt"]; found {
+		t.Errorf("Unencrypted object was rekeyed")
+	}
+
+	report, err = rekey()
+	if err != nil {
+		t.Fatalf("Second rekey failed: %v", err)
+	}
+	statuses = rekeyStatuses(report)
+	if statuses["data/archived.tar.gz.enc"] != services.ObjectRekeyed {
+		t.Errorf("Expected archived object to be rekeyed, got %v", statuses)
+	}
+	if statuses["data/legacy.tar.gz.enc"] != services.ObjectSkipped || statuses["data/current.tar.gz.enc"] != services.ObjectSkipped {
+		t.Errorf("Expected objects of the first run to be skipped, got %v", statuses)
+	}
+	if class := fake.classes["backup-bucket/data/archived.tar.gz.enc"]; class != "DEEP_ARCHIVE" {
+		t.Errorf("Storage class not kept: %q", class)
+	}
+	if _, locked := fake.objects["backup-bucket/"+utils.LockKey("data")]; locked {
+		t.Errorf("Lock object left after rekey")
+	}
+
+	for key, want := range map[string]string{
+		"backup-bucket/data/legacy.tar.gz.enc":   "legacy content",
+		"backup-bucket/data/current.tar.gz.enc":  "current content",
+		"backup-bucket/data/archived.tar.gz.enc": "archived content",
+	} {
+		plaintext, err := utils.DecryptData(fake.objects[key], newKeys)
+		if err != nil || string(plaintext) != want {
+			t.Errorf("%s does not decrypt with the new password: %v", key, err)
+		}
+		if utils.IsEncryptedWith(fake.objects[key], oldKeys) {
+			t.Errorf("%s still decrypts with the old password", key)
+		}
+	}
+}
+
+func TestRekeyWrongPassword(t *testing.T) {
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+
+	data, err := utils.NewPasswordEncryptor("SomeOtherPassword123!", "", scryptKDF(t)).EncryptData([]byte("content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.objects["backup-bucket/data/file.tar.gz.enc"] = data
+
+	svc := services.NewRekeyService(cfg, utils.DecryptionKeys{Passwords: []string{"OldBackupPassword123!"}},
+		"NewBackupPassword456!", utils.NewPasswordEncryptor("NewBackupPassword456!", "", scryptKDF(t)))
+	if err := svc.ProcessRekey(ctx, "backup-bucket", "data", false); !errors.Is(err, services.ErrPartialFailure) {
+		t.Fatalf("Expected partial failure, got %v", err)
+	}
+	if svc.Report().Totals.Failed != 1 {
+		t.Errorf("Expected one failed object, got %d", svc.Report().Totals.Failed)
+	}
+	if string(fake.objects["backup-bucket/data/file.tar.gz.enc"]) != string(data) {
+		t.Errorf("Object was changed although it could not be decrypted")
+	}
+}
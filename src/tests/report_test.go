@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, config.ExitSuccess},
+		{"plain error", errors.New("boom"), config.ExitFailure},
+		{"partial", &services.RunError{Status: services.StatusPartial, Err: services.ErrPartialFailure}, config.ExitPartialFailure},
+		{"cancelled run", &services.RunError{Status: services.StatusCancelled, Err: context.Canceled}, config.ExitCancelled},
+		{"interrupted", fmt.Errorf("upload: %w", context.Canceled), config.ExitCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := services.ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDryRunBackupReport(t *testing.T) {
+	tmpDir := t.TempDir()
+	contentDir := filepath.Join(tmpDir, "data")
+	if err := os.MkdirAll(contentDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(contentDir, "file.txt"), []byte("report test data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	inputFile := filepath.Join(tmpDir, "input.json")
+	tasks := config.Tasks{Tasks: []config.Task{{
+		S3Bucket:                  "my-s3-backup-bucket",
+		S3Prefix:                  "backup",
+		TmpStorageToBuildArchives: filepath.Join(tmpDir, "tmp"),
+		CleanupTmpStorage:         config.NewBool(false),
+		Content:                   []string{contentDir},
+	}}}
+	data, _ := json.Marshal(tasks)
+	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backupService := services.NewBackupService(aws.Config{})
+	if err := backupService.ProcessBackup(context.Background(), inputFile, true); err != nil {
+		t.Fatalf("ProcessBackup failed: %v", err)
+	}
+
+	report := backupService.Report()
+	if report.Status != services.StatusSuccess || report.ExitCode != config.ExitSuccess {
+		t.Errorf("Unexpected report status %s (exit code %d)", report.Status, report.ExitCode)
+	}
+	if len(report.Tasks) != 1 || len(report.Tasks[0].Objects) != 1 {
+		t.Fatalf("Expected 1 task with 1 object, got %+v", report.Tasks)
+	}
+	if object := report.Tasks[0].Objects[0]; object.Status != services.ObjectDryRun || object.Bucket != "my-s3-backup-bucket" {
+		t.Errorf("Unexpected object report: %+v", object)
+	}
+
+	reportFile := filepath.Join(tmpDir, "report.json")
+	if err := report.WriteFile(reportFile); err != nil {
+		t.Fatal(err)
+	}
+	var decoded services.RunReport
+	data, _ = os.ReadFile(reportFile)
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Mode != "backup" {
+		t.Errorf("Report file could not be decoded: %v", err)
+	}
+}
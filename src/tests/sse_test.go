@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+// sseBackupTask creates a content directory and input file and returns a task backing it up to backup-bucket/backup
+func sseBackupTask(t *testing.T, tmpDir string) (config.Task, string) {
+	t.Helper()
+	sourceDir := filepath.Join(tmpDir, "documents")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "notes.txt"), []byte("compliance notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	inputFile := filepath.Join(tmpDir, "input.json")
+	if err := os.WriteFile(inputFile, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return config.Task{
+		S3Bucket:                  "backup-bucket",
+		S3Prefix:                  "backup",
+		TrimBeginningOfPathInS3:   tmpDir,
+		StorageClass:              "STANDARD",
+		TmpStorageToBuildArchives: filepath.Join(tmpDir, "build"),
+		Content:                   []string{sourceDir},
+	}, inputFile
+}
+
+// backupObjectKeys returns the keys of the objects a backup uploaded, without the lock object
+func backupObjectKeys(fake *fakeS3) []string {
+	var keys []string
+	for key := range fake.objects {
+		if !strings.HasSuffix(key, utils.LockObjectName) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestServerSideEncryptionKMS(t *testing.T) {
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	keyARN := "arn:aws:kms:eu-central-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	oldPassword, newPassword := "Kms-R3k3y#Vault-2025", "Kms-N3wK3y#Safe-2026"
+
+	task, inputFile := sseBackupTask(t, tmpDir)
+	task.EncryptionSecret = oldPassword
+	task.ServerSideEncryption = config.SSEKMS
+	task.SSEKMSKeyID = keyARN
+	task.SSEBucketKey = config.NewBool(true)
+	if err := task.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	backup := services.NewBackupService(cfg)
+	if err := backup.ProcessTasks(ctx, []config.Task{task}, inputFile, false); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	keys := backupObjectKeys(fake)
+	if len(keys) != 2 {
+		t.Fatalf("Expected archive and config copy, got %v", keys)
+	}
+	for _, key := range keys {
+		headers := fake.headers[key]
+		if headers.Get("X-Amz-Server-Side-Encryption") != "aws:kms" || headers.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id") != keyARN ||
+			headers.Get("X-Amz-Server-Side-Encryption-Bucket-Key-Enabled") != "true" {
+			t.Errorf("Object %s not stored with SSE-KMS: %v", key, headers)
+		}
+	}
+
+	// Rekeyed objects keep their KMS key
+	rekey := services.NewRekeyService(cfg, utils.DecryptionKeys{Passwords: []string{oldPassword}}, newPassword,
+		utils.NewPasswordEncryptor(newPassword, "", scryptKDF(t)))
+	if err := rekey.ProcessRekey(ctx, "backup-bucket", "backup", false); err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+	for _, key := range keys {
+		if strings.HasSuffix(key, "."+config.EncryptionExt) && fake.headers[key].Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id") != keyARN {
+			t.Errorf("Rekeyed object %s lost its KMS key: %v", key, fake.headers[key])
+		}
+	}
+}
+
+func TestServerSideEncryptionCustomerKey(t *testing.T) {
+	cfg, fake := newFakeS3Config(t)
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	rawKey := make([]byte, 32)
+	rand.Read(rawKey)
+	customerKey := base64.StdEncoding.EncodeToString(rawKey)
+	if _, err := utils.NewCustomerKeyEncryption(ctx, "c2hvcnQ="); err == nil {
+		t.Errorf("Expected error for a short SSE-C key")
+	}
+
+	task, inputFile := sseBackupTask(t, tmpDir)
+	task.ServerSideEncryption = config.SSECustomer
+	task.SSECustomerKey = customerKey
+	tasks := []config.Task{task}
+
+	backup := services.NewBackupService(cfg)
+	if err := backup.ProcessTasks(ctx, tasks, inputFile, false); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	keys := backupObjectKeys(fake)
+	for _, key := range keys {
+		if fake.headers[key].Get(sseCustomerKeyMD5) == "" {
+			t.Errorf("Object %s not stored with SSE-C", key)
+		}
+	}
+
+	// Existing objects are only found with the key
+	backup = services.NewBackupService(cfg)
+	if err := backup.ProcessTasks(ctx, tasks, inputFile, false); err != nil {
+		t.Fatalf("Second backup failed: %v", err)
+	}
+	if backup.Report().Totals.Skipped != len(keys) {
+		t.Errorf("Expected %d skipped objects, got %+v", len(keys), backup.Report().Totals)
+	}
+
+	var contents services.S3Contents
+	for _, key := range keys {
+		contents.Contents = append(contents.Contents, services.S3Object{
+			Key: strings.TrimPrefix(key, "backup-bucket/"), Size: int64(len(fake.objects[key])), StorageClass: "STANDARD",
+		})
+	}
+	listing, _ := json.Marshal(contents)
+	listingFile := filepath.Join(tmpDir, "restore.json")
+	if err := os.WriteFile(listingFile, listing, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	restore := services.NewRestoreService(cfg)
+	if err := restore.ProcessRestore(ctx, "backup-bucket", "backup", listingFile, filepath.Join(tmpDir, "nokey"), false, true, "bulk", 1, 0, true); err == nil {
+		t.Errorf("Expected restore without SSE-C key to fail")
+	}
+
+	sse, err := utils.NewCustomerKeyEncryption(ctx, "plain:"+customerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destination := filepath.Join(tmpDir, "restore")
+	restore = services.NewRestoreService(cfg)
+	restore.SetServerSideEncryption(sse)
+	if err := restore.ProcessRestore(ctx, "backup-bucket", "backup", listingFile, destination, false, false, "bulk", 1, 0, true); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	var found bool
+	filepath.Walk(destination, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Name() == "notes.txt" {
+			content, _ := os.ReadFile(path)
+			found = string(content) == "compliance notes"
+		}
+		return nil
+	})
+	if !found {
+		t.Errorf("Restored notes.txt not found")
+	}
+}
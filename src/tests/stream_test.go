@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"github.com/rtitz/aws-s3-backup/services"
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+// writeStreamInput writes an input file with a single task that backs up the given streams
+func writeStreamInput(t *testing.T, tmpDir string, streams []config.StreamSource) string {
+	t.Helper()
+	inputFile := filepath.Join(tmpDir, "input.json")
+	data, _ := json.Marshal(config.Tasks{Tasks: []config.Task{{
+		S3Bucket:                  "my-s3-backup-bucket",
+		S3Prefix:                  "backup",
+		TmpStorageToBuildArchives: filepath.Join(tmpDir, "tmp"),
+		CleanupTmpStorage:         config.NewBool(false),
+		Streams:                   streams,
+	}}})
+	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return inputFile
+}
+
+func TestStreamCommandBackup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stream tests use sh")
+	}
+	tmpDir := t.TempDir()
+	cfg, fake := newFakeS3Config(t)
+	marker := filepath.Join(tmpDir, "marker")
+	inputFile := writeStreamInput(t, tmpDir, []config.StreamSource{
+		{Type: "COMMAND", Command: "touch '" + marker + "'; printf 'dump data'", ObjectName: "databases/mydb.sql"},
+	})
+
+	// A dry-run does not run the command
+	backupService := services.NewBackupService(cfg)
+	if err := backupService.ProcessBackup(context.Background(), inputFile, true); err != nil {
+		t.Fatalf("Dry-run failed: %v", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("Stream command was run in dry-run mode")
+	}
+
+	backupService = services.NewBackupService(cfg)
+	if err := backupService.ProcessBackup(context.Background(), inputFile, false); err != nil {
+		t.Fatalf("ProcessBackup failed: %v", err)
+	}
+	if _, found := fake.objects["my-s3-backup-bucket/backup/databases/mydb.sql.gz"]; !found {
+		t.Errorf("Stream not uploaded: %v", backupObjectKeys(fake))
+	}
+
+	objects := backupService.Report().Tasks[0].Objects
+	if len(objects) != 1 || objects[0].Key != "backup/databases/mydb.sql.gz" {
+		t.Fatalf("Unexpected objects: %+v", objects)
+	}
+
+	restored := filepath.Join(tmpDir, "mydb.sql")
+	if err := utils.DecompressFile(filepath.Join(tmpDir, "tmp", "mydb.sql.gz"), restored); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(restored); string(data) != "dump data" {
+		t.Errorf("Restored stream = %q", data)
+	}
+}
+
+func TestStreamCommandFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stream tests use sh")
+	}
+	tmpDir := t.TempDir()
+	inputFile := writeStreamInput(t, tmpDir, []config.StreamSource{
+		{Type: config.StreamCommand, Command: "echo partial; echo 'connection refused' >&2; exit 2", ObjectName: "mydb.sql"},
+	})
+
+	cfg, _ := newFakeS3Config(t)
+	backupService := services.NewBackupService(cfg)
+	err := backupService.ProcessBackup(context.Background(), inputFile, false)
+	if err == nil {
+		t.Fatal("Expected failing stream command to fail the backup")
+	}
+	if report := backupService.Report(); report.Status != services.StatusFailed || len(report.Tasks[0].Objects) != 0 {
+		t.Errorf("Unexpected report: status %s, objects %+v", report.Status, report.Tasks[0].Objects)
+	}
+}
+
+func TestStreamValidation(t *testing.T) {
+	tmpDir := t.TempDir()
+	tests := map[string][]config.StreamSource{
+		"missing object name": {{Type: config.StreamCommand, Command: "true"}},
+		"missing command":     {{Type: config.StreamCommand, ObjectName: "dump"}},
+		"invalid type":        {{Type: "file", ObjectName: "dump"}},
+		"stdin twice":         {{Type: config.StreamStdin, ObjectName: "a"}, {Type: config.StreamStdin, ObjectName: "b"}},
+	}
+
+	for name, streams := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := config.LoadTasks(writeStreamInput(t, tmpDir, streams)); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+
+	// Types are not case-sensitive, like notification types
+	streams := []config.StreamSource{{Type: "Command", Command: "true", ObjectName: "a"}, {Type: "STDIN", ObjectName: "b"}}
+	if _, err := config.LoadTasks(writeStreamInput(t, tmpDir, streams)); err != nil {
+		t.Errorf("Unexpected validation error: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/rtitz/aws-s3-backup/utils"
+)
+
+func TestParseTransferWindows(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"22:00-06:00", 1, false},
+		{"22:00-06:00, 12:00-13:00", 2, false},
+		{"00:00-24:00", 1, false},
+		{"22:00", 0, true},
+		{"25:00-06:00", 0, true},
+		{"10:00-10:00", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			windows, err := utils.ParseTransferWindows(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseTransferWindows() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(windows) != tt.want {
+				t.Errorf("ParseTransferWindows() returned %d windows, want %d", len(windows), tt.want)
+			}
+		})
+	}
+}
+
+func TestInTransferWindow(t *testing.T) {
+	windows, err := utils.ParseTransferWindows("22:00-06:00,12:00-13:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		clock string
+		want  bool
+	}{
+		{"23:30", true},
+		{"02:00", true},
+		{"06:00", false},
+		{"12:30", true},
+		{"18:00", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.clock, func(t *testing.T) {
+			now, _ := time.Parse("15:04", tt.clock)
+			if got := utils.InTransferWindow(windows, now); got != tt.want {
+				t.Errorf("InTransferWindow(%s) = %v, want %v", tt.clock, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThrottledReaderKeepsData(t *testing.T) {
+	data := bytes.Repeat([]byte("backup"), 10000)
+	throttle := utils.NewThrottle(100000, nil)
+
+	reader := throttle.WrapReadSeeker(context.Background(), bytes.NewReader(data))
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("Throttled reader changed the data")
+	}
+
+	if utils.NewThrottle(0, nil) != nil {
+		t.Error("Expected nil throttle without limit and windows")
+	}
+}
+
+func TestChainedThrottlesShareGlobalLimit(t *testing.T) {
+	global := utils.NewThrottle(32, nil)
+	data := bytes.Repeat([]byte("x"), 32*utils.BytesPerKB)
+
+	// Each chained throttle allows much more, the second 32 KB wait for the shared global budget
+	start := time.Now()
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := io.ReadAll(global.Chain(1000, nil).WrapReader(context.Background(), bytes.NewReader(data)))
+			errs <- err
+		}()
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("Chained throttles did not share the global limit, reading took %s", elapsed)
+	}
+}
@@ -3,11 +3,15 @@ package utils
 import (
 	"archive/tar"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/klauspost/pgzip"
 )
@@ -35,8 +39,11 @@ func CreateArchive(files []string, outputPath string) error {
 	tw := tar.NewWriter(gw)
 	defer tw.Close()
 
+	progress := StartProgress("📦 Archiving "+filepath.Base(outputPath), calculatePathsSize(files))
+	defer progress.Finish()
+
 	for _, file := range files {
-		if err := addToArchive(tw, file); err != nil {
+		if err := addToArchive(tw, file, progress); err != nil {
 			return err
 		}
 	}
@@ -53,7 +60,14 @@ func ExtractArchive(archivePath, destDir string) error {
 	}
 	defer file.Close()
 
-	gzr, err := gzip.NewReader(file)
+	var archiveSize int64
+	if info, err := file.Stat(); err == nil {
+		archiveSize = info.Size()
+	}
+	progress := StartProgress("📎 Extracting "+filepath.Base(archivePath), archiveSize)
+	defer progress.Finish()
+
+	gzr, err := gzip.NewReader(progress.Reader(file))
 	if err != nil {
 		return err
 	}
@@ -103,7 +117,7 @@ func ExtractArchive(archivePath, destDir string) error {
 			// Preserve file timestamps immediately
 			if err := os.Chtimes(target, header.AccessTime, header.ModTime); err != nil {
 				// Don't fail extraction if timestamp setting fails
-				log.Printf("⚠️ Warning: Could not set timestamps for %s: %v", target, err)
+				slog.Warn(fmt.Sprintf("⚠️ Warning: Could not set timestamps for %s: %v", target, err), "event", EventExtract, "file", target, "error", err)
 			}
 		}
 	}
@@ -112,15 +126,123 @@ func ExtractArchive(archivePath, destDir string) error {
 	for dirPath, header := range dirTimestamps {
 		if err := os.Chtimes(dirPath, header.AccessTime, header.ModTime); err != nil {
 			// Don't fail extraction if timestamp setting fails
-			log.Printf("⚠️ Warning: Could not set timestamps for directory %s: %v", dirPath, err)
+			slog.Warn(fmt.Sprintf("⚠️ Warning: Could not set timestamps for directory %s: %v", dirPath, err), "event", EventExtract, "file", dirPath, "error", err)
 		}
 	}
 
 	return nil
 }
 
+// ArchiveRoot returns the first path element of the first entry, the directory or file the archive was built from
+func ArchiveRoot(archivePath string) (string, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	gzr, err := gzip.NewReader(file)
+	if err != nil {
+		return "", err
+	}
+	defer gzr.Close()
+
+	header, err := tar.NewReader(gzr).Next()
+	if err != nil {
+		return "", fmt.Errorf("❌ failed to read archive %s: %w", filepath.Base(archivePath), err)
+	}
+	root, _, _ := strings.Cut(path.Clean(header.Name), "/")
+	if !filepath.IsLocal(root) {
+		return "", fmt.Errorf("❌ archive %s has an invalid entry '%s'", filepath.Base(archivePath), header.Name)
+	}
+	return root, nil
+}
+
+// CompressStream compresses a stream into a gzip file with multi-core compression, returns the uncompressed size
+func CompressStream(r io.Reader, outputPath string) (int64, error) {
+	log.Printf("📦 Compressing stream: %s", filepath.Base(outputPath))
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return 0, err
+	}
+
+	cores := runtime.NumCPU()
+	maxCores := max(1, min(8, cores*3/4))
+
+	gw, err := pgzip.NewWriterLevel(out, pgzip.BestSpeed)
+	if err != nil {
+		out.Close()
+		return 0, err
+	}
+	gw.SetConcurrency(1<<20, maxCores)
+
+	progress := StartProgress("📦 Compressing "+filepath.Base(outputPath), 0)
+	written, err := io.Copy(gw, progress.Reader(r))
+	progress.Finish()
+	if err != nil {
+		gw.Close()
+		out.Close()
+		return written, err
+	}
+	if err := gw.Close(); err != nil {
+		out.Close()
+		return written, err
+	}
+
+	log.Printf("✅ Stream compressed successfully: %s (%s)", filepath.Base(outputPath), FormatBytes(written))
+	return written, out.Close()
+}
+
+// DecompressFile decompresses a gzip file created by CompressStream
+func DecompressFile(archivePath, outputPath string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var archiveSize int64
+	if info, err := file.Stat(); err == nil {
+		archiveSize = info.Size()
+	}
+	progress := StartProgress("📎 Decompressing "+filepath.Base(archivePath), archiveSize)
+	defer progress.Finish()
+
+	gzr, err := gzip.NewReader(progress.Reader(file))
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+
+	out, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, DefaultFilePerm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, gzr); err != nil {
+		out.Close()
+		os.Remove(outputPath)
+		return err
+	}
+	return out.Close()
+}
+
+// calculatePathsSize sums up the size of all regular files below the given paths
+func calculatePathsSize(paths []string) int64 {
+	var total int64
+	for _, path := range paths {
+		filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				total += info.Size()
+			}
+			return nil
+		})
+	}
+	return total
+}
+
 // addToArchive recursively adds files to tar archive
-func addToArchive(tw *tar.Writer, filePath string) error {
+func addToArchive(tw *tar.Writer, filePath string, progress *Progress) error {
 	return filepath.Walk(filePath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -129,7 +251,7 @@ func addToArchive(tw *tar.Writer, filePath string) error {
 			return nil
 		}
 
-		log.Printf("➕ Adding to archive: %s", path)
+		slog.Debug(fmt.Sprintf("➕ Adding to archive: %s", path), "path", path, "size", info.Size())
 		
 		file, err := os.Open(path)
 		if err != nil {
@@ -153,7 +275,7 @@ func addToArchive(tw *tar.Writer, filePath string) error {
 		if err := tw.WriteHeader(header); err != nil {
 			return err
 		}
-		_, err = io.Copy(tw, file)
+		_, err = io.Copy(tw, progress.Reader(file))
 		return err
 	})
 }
@@ -1,10 +1,14 @@
 package utils
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -31,12 +35,21 @@ func CreateAWSSession(ctx context.Context, profile, region string) (aws.Config,
 	return cfg, nil
 }
 
-// loadAWSConfig loads AWS configuration with profile and region
+// loadAWSConfig loads AWS configuration with profile and region, trusting the CA bundle of the S3 endpoint
 func loadAWSConfig(ctx context.Context, profile, region string) (aws.Config, error) {
-	cfg, err := config.LoadDefaultConfig(ctx,
+	options := []func(*config.LoadOptions) error{
 		config.WithRegion(region),
 		config.WithSharedConfigProfile(profile),
-	)
+	}
+	caBundle, err := caBundleOption()
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if caBundle != nil {
+		options = append(options, caBundle)
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
 	if err != nil {
 		return aws.Config{}, fmt.Errorf("❌ failed to load AWS config: %w", err)
 	}
@@ -45,7 +58,7 @@ func loadAWSConfig(ctx context.Context, profile, region string) (aws.Config, err
 
 // validateAWSCredentials tests AWS credentials by listing buckets
 func validateAWSCredentials(ctx context.Context, cfg aws.Config) error {
-	client := s3.NewFromConfig(cfg)
+	client := NewS3Client(cfg)
 	_, err := client.ListBuckets(ctx, &s3.ListBucketsInput{})
 	if err != nil {
 		return fmt.Errorf("❌ AWS credentials validation failed: %w", err)
@@ -55,21 +68,33 @@ func validateAWSCredentials(ctx context.Context, cfg aws.Config) error {
 
 // S3 file operations
 
-// UploadFile uploads a file to S3 with specified storage class
-func UploadFile(ctx context.Context, cfg aws.Config, filePath, bucket, key string, storageClass types.StorageClass) error {
+// UploadFile uploads a file to S3 with specified storage class, sse, lock and throttle may be nil
+func UploadFile(ctx context.Context, cfg aws.Config, filePath, bucket, key string, storageClass types.StorageClass, sse *ServerSideEncryption, lock *ObjectLock, throttle *Throttle) error {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file for upload: %w", err)
 	}
 	defer file.Close()
 
-	uploader := manager.NewUploader(s3.NewFromConfig(cfg))
-	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file for upload: %w", err)
+	}
+
+	progress := StartProgress("⬆️ Uploading "+filepath.Base(filePath), info.Size())
+	defer progress.Finish()
+
+	input := &s3.PutObjectInput{
 		Bucket:       &bucket,
 		Key:          &key,
-		Body:         file,
+		Body:         progress.ReadSeeker(throttle.WrapReadSeeker(ctx, file)),
 		StorageClass: storageClass,
-	})
+	}
+	sse.applyToPut(input)
+	lock.applyToPut(input)
+
+	uploader := manager.NewUploader(NewS3Client(cfg))
+	_, err = uploader.Upload(ctx, input)
 
 	if err != nil {
 		return fmt.Errorf("failed to upload file to S3: %w", err)
@@ -78,20 +103,132 @@ func UploadFile(ctx context.Context, cfg aws.Config, filePath, bucket, key strin
 	return nil
 }
 
-// DownloadFile downloads a file from S3
-func DownloadFile(ctx context.Context, cfg aws.Config, bucket, key, filePath string) error {
+// DownloadFile downloads a file from S3, sse and throttle may be nil
+func DownloadFile(ctx context.Context, cfg aws.Config, bucket, key, filePath string, sse *ServerSideEncryption, throttle *Throttle) error {
+	if err := throttle.WaitForWindow(ctx); err != nil {
+		return err
+	}
+
 	regionCfg, err := getRegionSpecificConfig(ctx, cfg, bucket)
 	if err != nil {
 		regionCfg = cfg // Fallback to original config
 	}
 
-	s3Object, err := getS3Object(ctx, regionCfg, bucket, key)
+	s3Object, err := getS3Object(ctx, regionCfg, bucket, key, sse)
 	if err != nil {
 		return err
 	}
 	defer s3Object.Body.Close()
 
-	return saveObjectToFile(s3Object.Body, filePath)
+	progress := StartProgress("⬇️ Downloading "+filepath.Base(filePath), aws.ToInt64(s3Object.ContentLength))
+	defer progress.Finish()
+
+	return saveObjectToFile(progress.Reader(throttle.WrapReader(ctx, s3Object.Body)), filePath)
+}
+
+// ListObjects lists all objects below a prefix
+func ListObjects(ctx context.Context, cfg aws.Config, bucket, prefix string) ([]types.Object, error) {
+	regionCfg, err := getRegionSpecificConfig(ctx, cfg, bucket)
+	if err != nil {
+		regionCfg = cfg
+	}
+
+	var objects []types.Object
+	paginator := s3.NewListObjectsV2Paginator(NewS3Client(regionCfg), &s3.ListObjectsV2Input{
+		Bucket: &bucket,
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		objects = append(objects, page.Contents...)
+	}
+	return objects, nil
+}
+
+// GetObjectData downloads an object into memory, sse and throttle may be nil
+func GetObjectData(ctx context.Context, cfg aws.Config, bucket, key string, sse *ServerSideEncryption, throttle *Throttle) ([]byte, error) {
+	if err := throttle.WaitForWindow(ctx); err != nil {
+		return nil, err
+	}
+
+	regionCfg, err := getRegionSpecificConfig(ctx, cfg, bucket)
+	if err != nil {
+		regionCfg = cfg
+	}
+
+	s3Object, err := getS3Object(ctx, regionCfg, bucket, key, sse)
+	if err != nil {
+		return nil, err
+	}
+	defer s3Object.Body.Close()
+
+	progress := StartProgress("⬇️ Downloading "+filepath.Base(key), aws.ToInt64(s3Object.ContentLength))
+	defer progress.Finish()
+
+	data, err := io.ReadAll(progress.Reader(throttle.WrapReader(ctx, s3Object.Body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read S3 object: %w", err)
+	}
+	return data, nil
+}
+
+// PutObjectData uploads data with its SHA-256 checksum, which S3 verifies before storing the object, sse, lock and throttle may be nil
+func PutObjectData(ctx context.Context, cfg aws.Config, bucket, key string, data []byte, storageClass types.StorageClass, sse *ServerSideEncryption, lock *ObjectLock, throttle *Throttle) error {
+	regionCfg, err := getRegionSpecificConfig(ctx, cfg, bucket)
+	if err != nil {
+		regionCfg = cfg
+	}
+
+	progress := StartProgress("⬆️ Uploading "+filepath.Base(key), int64(len(data)))
+	defer progress.Finish()
+
+	input := &s3.PutObjectInput{
+		Bucket:         &bucket,
+		Key:            &key,
+		Body:           progress.ReadSeeker(throttle.WrapReadSeeker(ctx, bytes.NewReader(data))),
+		ContentLength:  aws.Int64(int64(len(data))),
+		ChecksumSHA256: aws.String(SHA256Base64(data)),
+		StorageClass:   storageClass,
+	}
+	sse.applyToPut(input)
+	lock.applyToPut(input)
+
+	_, err = NewS3Client(regionCfg).PutObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to upload object to S3: %w", err)
+	}
+	return nil
+}
+
+// GetObjectChecksum returns size and SHA-256 checksum stored with an object, the checksum is empty if none was stored.
+// sse provides the SSE-C key and may be nil.
+func GetObjectChecksum(ctx context.Context, cfg aws.Config, bucket, key string, sse *ServerSideEncryption) (int64, string, error) {
+	regionCfg, err := getRegionSpecificConfig(ctx, cfg, bucket)
+	if err != nil {
+		regionCfg = cfg
+	}
+
+	input := &s3.HeadObjectInput{
+		Bucket:       &bucket,
+		Key:          &key,
+		ChecksumMode: types.ChecksumModeEnabled,
+	}
+	sse.applyToHead(input)
+
+	result, err := NewS3Client(regionCfg).HeadObject(ctx, input)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to check object: %w", err)
+	}
+	return aws.ToInt64(result.ContentLength), aws.ToString(result.ChecksumSHA256), nil
+}
+
+// SHA256Base64 returns the base64 encoded SHA-256 of data as used by S3 checksums
+func SHA256Base64(data []byte) string {
+	sum := sha256.Sum256(data)
+	return base64.StdEncoding.EncodeToString(sum[:])
 }
 
 // getRegionSpecificConfig gets AWS config for bucket's region
@@ -101,12 +238,15 @@ func getRegionSpecificConfig(ctx context.Context, cfg aws.Config, bucket string)
 }
 
 // getS3Object retrieves an object from S3
-func getS3Object(ctx context.Context, cfg aws.Config, bucket, key string) (*s3.GetObjectOutput, error) {
-	client := s3.NewFromConfig(cfg)
-	result, err := client.GetObject(ctx, &s3.GetObjectInput{
+func getS3Object(ctx context.Context, cfg aws.Config, bucket, key string, sse *ServerSideEncryption) (*s3.GetObjectOutput, error) {
+	input := &s3.GetObjectInput{
 		Bucket: &bucket,
 		Key:    &key,
-	})
+	}
+	sse.applyToGet(input)
+
+	client := NewS3Client(cfg)
+	result, err := client.GetObject(ctx, input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get S3 object: %w", err)
 	}
@@ -114,7 +254,7 @@ func getS3Object(ctx context.Context, cfg aws.Config, bucket, key string) (*s3.G
 }
 
 // saveObjectToFile saves S3 object body to local file
-func saveObjectToFile(body io.ReadCloser, filePath string) error {
+func saveObjectToFile(body io.Reader, filePath string) error {
 	incompleteSuffix := "_INCOMPL"
 	tmpFilePath := filePath + incompleteSuffix
 
@@ -148,18 +288,21 @@ func saveObjectToFile(body io.ReadCloser, filePath string) error {
 	return nil
 }
 
-// CheckObjectExists checks if an object exists in S3
-func CheckObjectExists(ctx context.Context, cfg aws.Config, bucket, key string) (bool, error) {
+// CheckObjectExists checks if an object exists in S3, sse provides the SSE-C key and may be nil
+func CheckObjectExists(ctx context.Context, cfg aws.Config, bucket, key string, sse *ServerSideEncryption) (bool, error) {
 	regionCfg, err := getRegionSpecificConfig(ctx, cfg, bucket)
 	if err != nil {
 		regionCfg = cfg
 	}
 
-	client := s3.NewFromConfig(regionCfg)
-	_, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+	input := &s3.HeadObjectInput{
 		Bucket: &bucket,
 		Key:    &key,
-	})
+	}
+	sse.applyToHead(input)
+
+	client := NewS3Client(regionCfg)
+	_, err = client.HeadObject(ctx, input)
 
 	if err != nil {
 		if isNotFoundError(err) {
@@ -186,7 +329,7 @@ func RestoreObject(ctx context.Context, cfg aws.Config, bucket, key string, retr
 		regionCfg = cfg
 	}
 
-	client := s3.NewFromConfig(regionCfg)
+	client := NewS3Client(regionCfg)
 	tier := mapRetrievalModeToTier(retrievalMode)
 
 	_, err = client.RestoreObject(ctx, &s3.RestoreObjectInput{
@@ -221,18 +364,21 @@ func mapRetrievalModeToTier(retrievalMode string) types.Tier {
 	}
 }
 
-// CheckObjectRestoreStatus checks if an object is restored and available for download
-func CheckObjectRestoreStatus(ctx context.Context, cfg aws.Config, bucket, key string) (bool, error) {
+// CheckObjectRestoreStatus checks if an object is restored and available for download, sse provides the SSE-C key and may be nil
+func CheckObjectRestoreStatus(ctx context.Context, cfg aws.Config, bucket, key string, sse *ServerSideEncryption) (bool, error) {
 	regionCfg, err := getRegionSpecificConfig(ctx, cfg, bucket)
 	if err != nil {
 		regionCfg = cfg
 	}
 
-	client := s3.NewFromConfig(regionCfg)
-	result, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+	input := &s3.HeadObjectInput{
 		Bucket: &bucket,
 		Key:    &key,
-	})
+	}
+	sse.applyToHead(input)
+
+	client := NewS3Client(regionCfg)
+	result, err := client.HeadObject(ctx, input)
 	if err != nil {
 		return false, fmt.Errorf("failed to check object status: %w", err)
 	}
@@ -285,9 +431,10 @@ func checkGlacierRestoreStatus(restore *string) bool {
 
 // Bucket management operations
 
-// ValidateBucketExistsWithRegion checks if bucket exists and returns its region and updated config
-func ValidateBucketExistsWithRegion(ctx context.Context, cfg aws.Config, bucket string) (string, aws.Config, error) {
-	client := s3.NewFromConfig(cfg)
+// ValidateBucketExistsWithRegion checks if bucket exists and returns its region and updated config,
+// a bucket created on request gets Object Lock if objectLock is set
+func ValidateBucketExistsWithRegion(ctx context.Context, cfg aws.Config, bucket string, objectLock bool) (string, aws.Config, error) {
+	client := NewS3Client(cfg)
 
 	// First validate bucket exists
 	_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{
@@ -295,28 +442,35 @@ func ValidateBucketExistsWithRegion(ctx context.Context, cfg aws.Config, bucket
 	})
 
 	if err != nil {
-		return handleBucketCreation(ctx, cfg, bucket)
+		return handleBucketCreation(ctx, cfg, bucket, objectLock)
 	}
 
 	// Get existing bucket region
-	return getBucketRegionAndConfig(ctx, cfg, bucket)
+	return GetBucketRegionWithConfig(ctx, cfg, bucket)
 }
 
 // handleBucketCreation manages bucket creation workflow
-func handleBucketCreation(ctx context.Context, cfg aws.Config, bucket string) (string, aws.Config, error) {
+func handleBucketCreation(ctx context.Context, cfg aws.Config, bucket string, objectLock bool) (string, aws.Config, error) {
 	fmt.Printf("\n❌ S3 bucket '%s' does not exist.\n", bucket)
+	if objectLock {
+		fmt.Printf("🔏 It will be created with Object Lock, which cannot be disabled later.\n")
+	}
 
 	if !confirmBucketCreation() {
 		return "", aws.Config{}, fmt.Errorf("bucket '%s' does not exist", bucket)
 	}
 
-	selectedRegion := selectBucketRegion()
-	if !isValidRegion(selectedRegion) {
+	// S3-compatible storage has its own regions, the bucket is created in the configured one
+	selectedRegion := cfg.Region
+	if !CustomS3Endpoint() {
+		selectedRegion = selectBucketRegion()
+	}
+	if !CustomS3Endpoint() && !isValidRegion(selectedRegion) {
 		return "", aws.Config{}, fmt.Errorf("invalid region: %s", selectedRegion)
 	}
 
 	fmt.Printf("\n🏗️ Creating bucket '%s' in region %s...\n", bucket, selectedRegion)
-	if err := CreateBucket(ctx, cfg, bucket, selectedRegion); err != nil {
+	if err := CreateBucket(ctx, cfg, bucket, selectedRegion, objectLock); err != nil {
 		handleBucketCreationError(bucket, err)
 		return "", aws.Config{}, fmt.Errorf("bucket creation failed: %w", err)
 	}
@@ -385,30 +539,11 @@ func handleBucketCreationError(bucket string, err error) {
 	}
 }
 
-// getBucketRegionAndConfig gets existing bucket's region and config
-func getBucketRegionAndConfig(ctx context.Context, cfg aws.Config, bucket string) (string, aws.Config, error) {
-	client := s3.NewFromConfig(cfg)
-	result, err := client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
-		Bucket: &bucket,
-	})
-	if err != nil {
-		return "", aws.Config{}, fmt.Errorf("failed to get bucket location: %w", err)
-	}
-
-	// Handle default region (us-east-1 returns empty string)
-	region := string(result.LocationConstraint)
-	if region == "" {
-		region = config_app.DefaultAWSRegion
-	}
-
-	return region, cfg, nil
-}
+// CreateBucket creates an S3 bucket with security best practices, optionally with Object Lock (WORM)
+func CreateBucket(ctx context.Context, cfg aws.Config, bucketName, region string, objectLock bool) error {
+	client := NewS3Client(cfg)
 
-// CreateBucket creates an S3 bucket with security best practices
-func CreateBucket(ctx context.Context, cfg aws.Config, bucketName, region string) error {
-	client := s3.NewFromConfig(cfg)
-
-	if err := createS3Bucket(ctx, client, bucketName, region); err != nil {
+	if err := createS3Bucket(ctx, client, bucketName, region, objectLock); err != nil {
 		return err
 	}
 
@@ -416,17 +551,20 @@ func CreateBucket(ctx context.Context, cfg aws.Config, bucketName, region string
 	if region != cfg.Region {
 		regionCfg := cfg.Copy()
 		regionCfg.Region = region
-		client = s3.NewFromConfig(regionCfg)
+		client = NewS3Client(regionCfg)
 	}
 
 	return configureBucketSecurity(ctx, client, bucketName)
 }
 
-// createS3Bucket creates the S3 bucket in specified region
-func createS3Bucket(ctx context.Context, client *s3.Client, bucketName, region string) error {
+// createS3Bucket creates the S3 bucket in specified region, Object Lock can only be enabled on creation
+func createS3Bucket(ctx context.Context, client *s3.Client, bucketName, region string, objectLock bool) error {
 	createInput := &s3.CreateBucketInput{
 		Bucket: &bucketName,
 	}
+	if objectLock {
+		createInput.ObjectLockEnabledForBucket = aws.Bool(true)
+	}
 
 	// Add location constraint for regions other than us-east-1
 	if region != config_app.DefaultAWSRegion {
@@ -444,22 +582,19 @@ func createS3Bucket(ctx context.Context, client *s3.Client, bucketName, region s
 	return nil
 }
 
-// configureBucketSecurity applies security settings to bucket
+// configureBucketSecurity applies security settings to bucket, S3-compatible storage may not
+// support all of them so they only cause warnings there
 func configureBucketSecurity(ctx context.Context, client *s3.Client, bucketName string) error {
-	if err := enableBucketVersioning(ctx, client, bucketName); err != nil {
-		return err
-	}
-
-	if err := blockPublicAccess(ctx, client, bucketName); err != nil {
-		return err
-	}
-
-	if err := enableBucketEncryption(ctx, client, bucketName); err != nil {
-		return err
-	}
-
-	if err := configureBucketLifecycle(ctx, client, bucketName); err != nil {
-		return err
+	steps := []func(context.Context, *s3.Client, string) error{
+		enableBucketVersioning, blockPublicAccess, enableBucketEncryption, configureBucketLifecycle,
+	}
+	for _, step := range steps {
+		if err := step(ctx, client, bucketName); err != nil {
+			if !CustomS3Endpoint() {
+				return err
+			}
+			fmt.Printf("⚠️  %v (not supported by the S3 endpoint?)\n", err)
+		}
 	}
 
 	fmt.Printf("🎉 Bucket configuration completed successfully!\n")
@@ -559,9 +694,13 @@ func configureBucketLifecycle(ctx context.Context, client *s3.Client, bucketName
 
 // Utility functions for bucket operations
 
-// GetBucketRegion gets the region of an existing bucket without offering to create it
+// GetBucketRegion gets the region of an existing bucket without offering to create it,
+// without region discovery it is the configured region
 func GetBucketRegion(ctx context.Context, cfg aws.Config, bucket string) (string, error) {
-	client := s3.NewFromConfig(cfg)
+	if s3Endpoint.NoRegionDiscovery {
+		return cfg.Region, nil
+	}
+	client := NewS3Client(cfg)
 
 	result, err := client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
 		Bucket: &bucket,
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	config_app "github.com/rtitz/aws-s3-backup/config"
+	"golang.org/x/term"
+)
+
+const (
+	// roleSessionName identifies the sessions of assumed roles in CloudTrail
+	roleSessionName = "aws-s3-backup"
+	// roleSessionDuration is the longest duration every role allows, the default of 15 minutes is short for uploads
+	roleSessionDuration = time.Hour
+)
+
+// stdinIsTerminal reports whether MFA codes can be asked for, scheduled runs must not wait for input
+var stdinIsTerminal = term.IsTerminal(int(os.Stdin.Fd()))
+
+// bucketAccess identifies a bucket accessed with the credentials settings of a task
+type bucketAccess struct {
+	bucket string
+	access config_app.Access
+}
+
+// ConfigCache holds the AWS configs of a run by credentials settings and by bucket, so every bucket is
+// accessed in its region with the credentials of its task and each role is assumed (and MFA asked for) once
+type ConfigCache struct {
+	mu       sync.Mutex
+	base     aws.Config
+	sessions map[config_app.Access]aws.Config
+	buckets  map[bucketAccess]aws.Config
+}
+
+// NewConfigCache creates a cache for configs derived from the config of the run
+func NewConfigCache(base aws.Config) *ConfigCache {
+	return &ConfigCache{
+		base:     base,
+		sessions: make(map[config_app.Access]aws.Config),
+		buckets:  make(map[bucketAccess]aws.Config),
+	}
+}
+
+// Session returns the config with the profile, region and role of the credentials settings
+func (c *ConfigCache) Session(ctx context.Context, access config_app.Access) (aws.Config, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cfg, found := c.sessions[access]; found {
+		return cfg, nil
+	}
+	cfg, err := newSessionConfig(ctx, c.base, access)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	c.sessions[access] = cfg
+	return cfg, nil
+}
+
+// SetBucket stores the config a bucket is accessed with, usually the region-specific config of its validation
+func (c *ConfigCache) SetBucket(bucket string, access config_app.Access, cfg aws.Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buckets[bucketAccess{bucket, access}] = cfg
+}
+
+// Bucket returns the config of a bucket and credentials settings, the config of the run if none was stored
+func (c *ConfigCache) Bucket(bucket string, access config_app.Access) aws.Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cfg, found := c.buckets[bucketAccess{bucket, access}]; found {
+		return cfg
+	}
+	return c.base
+}
+
+// newSessionConfig loads the profile of the credentials settings (or copies the base config) and assumes its role
+func newSessionConfig(ctx context.Context, base aws.Config, access config_app.Access) (aws.Config, error) {
+	region := base.Region
+	if access.Region != "" {
+		region = access.Region
+	}
+
+	cfg := base.Copy()
+	cfg.Region = region
+	if access.Profile != "" {
+		var err error
+		if cfg, err = loadAWSConfig(ctx, access.Profile, region); err != nil {
+			return aws.Config{}, err
+		}
+	}
+	if access.RoleArn == "" {
+		return cfg, nil
+	}
+
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), access.RoleArn, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = roleSessionName
+		o.Duration = roleSessionDuration
+		if access.ExternalId != "" {
+			o.ExternalID = aws.String(access.ExternalId)
+		}
+		if access.MFASerial != "" {
+			o.SerialNumber = aws.String(access.MFASerial)
+			o.TokenProvider = mfaTokenProvider(ctx, access)
+		}
+	})
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+
+	// Assume the role now, so wrong settings fail before the first upload
+	if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+		return aws.Config{}, fmt.Errorf("❌ failed to assume role %s: %w", access.RoleArn, err)
+	}
+	return cfg, nil
+}
+
+// mfaTokenProvider returns the MFA code of MFAToken or asks for it, whenever the role is assumed. An expired
+// session is only renewed with a fresh code, which a fixed token cannot provide (cmd: references can).
+func mfaTokenProvider(ctx context.Context, access config_app.Access) func() (string, error) {
+	return func() (string, error) {
+		if access.MFAToken != "" {
+			return ResolveSecret(ctx, access.MFAToken)
+		}
+		if !stdinIsTerminal {
+			return "", fmt.Errorf("❌ MFA code required to assume role %s, but stdin is not a terminal: set MFAToken, e.g. to a cmd: reference", access.RoleArn)
+		}
+		fmt.Printf("🔐 MFA code for %s (%s): ", access.MFASerial, access.RoleArn)
+		var code string
+		fmt.Scanln(&code)
+		if code == "" {
+			return "", fmt.Errorf("❌ MFA code required to assume role %s", access.RoleArn)
+		}
+		return code, nil
+	}
+}
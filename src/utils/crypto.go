@@ -1,15 +1,27 @@
 package utils
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hkdf"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/rtitz/aws-s3-backup/config"
 	"golang.org/x/crypto/scrypt"
@@ -17,24 +29,91 @@ import (
 
 // Encryption constants
 const (
-	SaltSize         = 32
-	MinEncryptedSize = SaltSize + 12 + 16 // salt + nonce + tag
-	NewScryptN       = 131072             // N=128K (stronger)
-	LegacyScryptN    = 32768              // N=32K (backward compatibility)
-	ScryptR          = 8
-	KeySize          = 32
+	SaltSize           = 32
+	MinEncryptedSize   = SaltSize + 12 + 16 // salt + nonce + tag
+	NewScryptN         = 131072             // N=128K (stronger)
+	LegacyScryptN      = 32768              // N=32K (backward compatibility)
+	ScryptR            = 8
+	maxLegacyScryptP   = 6
+	KeySize            = 32
+	FileKeySize        = 32
+	VersionedMagic     = "ENC2"
+	HeaderVersion      = 1
+	keyCheckSize       = 8
+	keyCheckInfo       = "aws-s3-backup key check"
+	passwordSubkeyInfo = "aws-s3-backup password file key"
+	maxHeaderSize      = 1 << 20
 )
 
+// encryptionHeader is the authenticated header of the ENC2 format
+type encryptionHeader struct {
+	Version int         `json:"version,omitempty"`
+	Stanzas []keyStanza `json:"stanzas"`
+}
+
+// keyStanza holds the file key wrapped for one recipient or password, KeyID names the key that can unwrap it.
+// Password stanzas record the KDF parameters, stanzas without them use scrypt N=131072, r=8, p=1.
+type keyStanza struct {
+	Type       string     `json:"type"`
+	KeyID      string     `json:"keyId,omitempty"`
+	Ephemeral  []byte     `json:"ephemeral,omitempty"`
+	KDF        *KDFParams `json:"kdf,omitempty"`
+	Salt       []byte     `json:"salt,omitempty"`
+	KeyCheck   []byte     `json:"keyCheck,omitempty"`
+	SubkeySalt []byte     `json:"subkeySalt,omitempty"`
+	WrappedKey []byte     `json:"wrappedKey"`
+}
+
+// Encryptor encrypts files with a password or for X25519 recipients.
+// The password is derived into a master key once, each file key is wrapped with an HKDF subkey of it.
+type Encryptor struct {
+	password   string
+	keyID      string
+	kdf        KDFParams
+	recipients []*Recipient
+
+	masterOnce sync.Once
+	master     []byte
+	salt       []byte
+	masterErr  error
+}
+
+// DecryptionKeys are the passwords and identities tried to decrypt a file.
+// KeyedPasswords are looked up by the key ID of a file and only tried on all files without a matching ID.
+type DecryptionKeys struct {
+	Passwords      []string
+	KeyedPasswords map[string]string
+	Identities     []*Identity
+	masterKeys     *masterKeyCache // Shared by copies of the keys, without it every master key is derived again
+}
+
+// NewPasswordEncryptor returns an encryptor that derives the key from a password with the KDF parameters.
+// The key ID is recorded in the header for restores with a keyring.
+func NewPasswordEncryptor(password, keyID string, kdf KDFParams) *Encryptor {
+	return &Encryptor{password: password, keyID: keyID, kdf: kdf}
+}
+
+// NewRecipientEncryptor returns an encryptor for X25519 recipients, only their identities can decrypt the files
+func NewRecipientEncryptor(recipients []*Recipient) *Encryptor {
+	return &Encryptor{recipients: recipients}
+}
+
 // EncryptFile encrypts a file with AES-256-GCM and saves it with .enc extension
 func EncryptFile(inputPath, password string) (string, error) {
-	log.Printf("🔒 Encrypting file: %s", filepath.Base(inputPath))
+	kdf, _ := DefaultKDF(config.KDFScrypt)
+	return NewPasswordEncryptor(password, "", kdf).EncryptFile(inputPath)
+}
+
+// EncryptFile encrypts a file with AES-256-GCM and saves it with .enc extension
+func (e *Encryptor) EncryptFile(inputPath string) (string, error) {
+	slog.Info(fmt.Sprintf("🔒 Encrypting file: %s", filepath.Base(inputPath)), "event", EventEncrypt, "file", inputPath)
 
 	data, err := readFileForEncryption(inputPath)
 	if err != nil {
 		return "", err
 	}
 
-	encrypted, err := encryptData(data, []byte(password))
+	encrypted, err := e.EncryptData(data)
 	if err != nil {
 		return "", err
 	}
@@ -48,14 +127,46 @@ func EncryptFile(inputPath, password string) (string, error) {
 	return outputPath, nil
 }
 
+// EncryptData encrypts data in memory in the ENC2 format
+func (e *Encryptor) EncryptData(data []byte) ([]byte, error) {
+	return encryptVersioned(data, e.wrap)
+}
+
+// DecryptData decrypts data in memory with the first matching password or identity
+func DecryptData(data []byte, keys DecryptionKeys) ([]byte, error) {
+	return decryptData(data, keys)
+}
+
+// IsEncryptedWith reports whether data is in the ENC2 format and can be decrypted with the keys
+func IsEncryptedWith(data []byte, keys DecryptionKeys) bool {
+	if !isVersionedFormat(data) {
+		return false
+	}
+	header, _, _, err := parseVersionedFormat(data)
+	if err != nil {
+		return false
+	}
+	for _, stanza := range header.Stanzas {
+		if _, err := keys.unwrap(stanza); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
 // DecryptFile decrypts a file encrypted with AES-256-GCM
 func DecryptFile(inputPath, password string) (string, error) {
+	return DecryptFileWithKeys(inputPath, DecryptionKeys{Passwords: []string{password}})
+}
+
+// DecryptFileWithKeys decrypts a file with the first matching password or identity
+func DecryptFileWithKeys(inputPath string, keys DecryptionKeys) (string, error) {
 	data, err := readEncryptedFile(inputPath)
 	if err != nil {
 		return "", err
 	}
 
-	decrypted, err := decryptData(data, []byte(password))
+	decrypted, err := decryptData(data, keys)
 	if err != nil {
 		return "", err
 	}
@@ -71,7 +182,7 @@ func DecryptFile(inputPath, password string) (string, error) {
 // File I/O helpers
 // readFileForEncryption reads a file for encryption
 func readFileForEncryption(inputPath string) ([]byte, error) {
-	data, err := os.ReadFile(inputPath)
+	data, err := readFileWithProgress(inputPath, "🔒 Encrypting "+filepath.Base(inputPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file for encryption: %w", err)
 	}
@@ -80,13 +191,37 @@ func readFileForEncryption(inputPath string) ([]byte, error) {
 
 // readEncryptedFile reads an encrypted file
 func readEncryptedFile(inputPath string) ([]byte, error) {
-	data, err := os.ReadFile(inputPath)
+	data, err := readFileWithProgress(inputPath, "🔓 Decrypting "+filepath.Base(inputPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read encrypted file: %w", err)
 	}
 	return data, nil
 }
 
+// readFileWithProgress reads a whole file while reporting progress
+func readFileWithProgress(inputPath, label string) ([]byte, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	progress := StartProgress(label, info.Size())
+	defer progress.Finish()
+
+	data := make([]byte, 0, info.Size())
+	buffer := bytes.NewBuffer(data)
+	if _, err := buffer.ReadFrom(progress.Reader(file)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
 // writeEncryptedFile writes encrypted data to file
 func writeEncryptedFile(outputPath string, data []byte) error {
 	return os.WriteFile(outputPath, data, 0644)
@@ -108,58 +243,33 @@ func buildDecryptedFilePath(inputPath string) string {
 }
 
 // Core encryption/decryption functions
-// encryptData encrypts data using AES-256-GCM
-func encryptData(data, password []byte) ([]byte, error) {
-	if err := validateEncryptionInput(data, password); err != nil {
-		return nil, err
-	}
-
-	key, salt, err := deriveEncryptionKey(password, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	gcm, err := createGCMCipher(key)
-	if err != nil {
-		return nil, err
-	}
-
-	nonce, err := generateNonce(gcm.NonceSize())
-	if err != nil {
-		return nil, err
-	}
-
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
-	return append(ciphertext, salt...), nil
-}
-
 // decryptData decrypts AES-256-GCM encrypted data
-func decryptData(data, password []byte) ([]byte, error) {
+func decryptData(data []byte, keys DecryptionKeys) ([]byte, error) {
 	if err := validateDecryptionInput(data); err != nil {
 		return nil, err
 	}
 
-	// Check for versioned format (future-proofing)
+	// Versioned format with wrapped file keys
 	if isVersionedFormat(data) {
-		return decryptVersionedFormat(data, password)
+		return decryptVersionedFormat(data, keys)
 	}
 
-	// Current format (v1)
-	return decryptCurrentFormat(data, password)
-}
-
-// Validation helpers
-// validateEncryptionInput validates data and password for encryption
-func validateEncryptionInput(data, password []byte) error {
-	if len(data) == 0 {
-		return fmt.Errorf("cannot encrypt empty data")
+	// Legacy format (v1) has no header, all passwords and parameter sets are tried
+	passwords := keys.passwordsFor("")
+	if len(passwords) == 0 {
+		return nil, fmt.Errorf("password required for password-encrypted file")
 	}
-	if len(password) == 0 {
-		return fmt.Errorf("password cannot be empty")
+	var err error
+	for _, password := range passwords {
+		var plaintext []byte
+		if plaintext, err = decryptCurrentFormat(data, []byte(password)); err == nil {
+			return plaintext, nil
+		}
 	}
-	return nil
+	return nil, err
 }
 
+// Validation helpers
 // validateDecryptionInput validates encrypted data format
 func validateDecryptionInput(data []byte) error {
 	if len(data) < MinEncryptedSize {
@@ -169,31 +279,6 @@ func validateDecryptionInput(data []byte) error {
 }
 
 // Cryptographic helpers
-// deriveEncryptionKey derives encryption key using scrypt
-func deriveEncryptionKey(password, salt []byte) ([]byte, []byte, error) {
-	if len(password) == 0 {
-		return nil, nil, fmt.Errorf("password cannot be empty")
-	}
-
-	if salt == nil {
-		var err error
-		salt, err = generateSalt()
-		if err != nil {
-			return nil, nil, err
-		}
-	} else if len(salt) != SaltSize {
-		return nil, nil, fmt.Errorf("invalid salt length: expected %d, got %d", SaltSize, len(salt))
-	}
-
-	p := calculateScryptP()
-	key, err := scrypt.Key(password, salt, NewScryptN, ScryptR, p, KeySize)
-	if err != nil {
-		return nil, nil, fmt.Errorf("key derivation failed: %w", err)
-	}
-
-	return key, salt, nil
-}
-
 // createGCMCipher creates AES-GCM cipher from key
 func createGCMCipher(key []byte) (cipher.AEAD, error) {
 	block, err := aes.NewCipher(key)
@@ -227,41 +312,360 @@ func generateSalt() ([]byte, error) {
 	return salt, nil
 }
 
-// calculateScryptP calculates scrypt parallelization parameter
-func calculateScryptP() int {
-	cores := runtime.NumCPU()
-	return max(1, min(6, cores/2))
+// legacyScryptPs returns the scrypt p values v1 files may have been written with, the value
+// of this host first. v1 derived p from the CPU count of the encrypting host and did not record it.
+func legacyScryptPs() []int {
+	local := max(1, min(maxLegacyScryptP, runtime.NumCPU()/2))
+	ps := []int{local}
+	for p := 1; p <= maxLegacyScryptP; p++ {
+		if p != local {
+			ps = append(ps, p)
+		}
+	}
+	return ps
 }
 
 // Decryption format handlers
 // isVersionedFormat checks if data uses versioned encryption format
 func isVersionedFormat(data []byte) bool {
-	return len(data) >= 4 && string(data[:4]) == "ENC2"
+	return len(data) >= 4 && string(data[:4]) == VersionedMagic
+}
+
+// wrap wraps the file key for all recipients or for the password of the encryptor
+func (e *Encryptor) wrap(fileKey []byte) ([]keyStanza, error) {
+	if len(e.recipients) == 0 {
+		stanza, err := e.wrapWithPassword(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		return []keyStanza{stanza}, nil
+	}
+
+	stanzas := make([]keyStanza, 0, len(e.recipients))
+	for _, recipient := range e.recipients {
+		stanza, err := recipient.wrap(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		stanzas = append(stanzas, stanza)
+	}
+	return stanzas, nil
+}
+
+// encryptVersioned encrypts data with a random file key that is wrapped into the header stanzas (ENC2 format).
+// Layout: magic | header length (uint32) | JSON header | nonce | ciphertext, magic to header are authenticated.
+func encryptVersioned(data []byte, wrap func(fileKey []byte) ([]keyStanza, error)) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("cannot encrypt empty data")
+	}
+
+	fileKey := make([]byte, FileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, fmt.Errorf("failed to generate file key: %w", err)
+	}
+
+	stanzas, err := wrap(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	headerData, err := json.Marshal(encryptionHeader{Version: HeaderVersion, Stanzas: stanzas})
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, 0, 8+len(headerData))
+	prefix = append(prefix, VersionedMagic...)
+	prefix = binary.BigEndian.AppendUint32(prefix, uint32(len(headerData)))
+	prefix = append(prefix, headerData...)
+
+	gcm, err := createGCMCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := generateNonce(gcm.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(append(prefix, nonce...), nonce, data, prefix), nil
+}
+
+// masterKey derives the key of the password once per encryptor, all files of a run share its KDF salt
+func (e *Encryptor) masterKey() ([]byte, []byte, error) {
+	e.masterOnce.Do(func() {
+		if e.password == "" {
+			e.masterErr = fmt.Errorf("password cannot be empty")
+			return
+		}
+		if e.salt, e.masterErr = generateSalt(); e.masterErr != nil {
+			return
+		}
+		e.master, e.masterErr = e.kdf.deriveKey([]byte(e.password), e.salt)
+	})
+	return e.master, e.salt, e.masterErr
+}
+
+// wrapWithPassword wraps a file key with a subkey of the master key, derived with a random per-file salt
+func (e *Encryptor) wrapWithPassword(fileKey []byte) (keyStanza, error) {
+	master, salt, err := e.masterKey()
+	if err != nil {
+		return keyStanza{}, err
+	}
+	subkeySalt, err := generateSalt()
+	if err != nil {
+		return keyStanza{}, err
+	}
+	wrapKey, err := hkdf.Key(sha256.New, master, subkeySalt, passwordSubkeyInfo, KeySize)
+	if err != nil {
+		return keyStanza{}, err
+	}
+	keyCheck, err := keyCheckValue(master)
+	if err != nil {
+		return keyStanza{}, err
+	}
+	wrapped, err := sealKey(wrapKey, fileKey)
+	if err != nil {
+		return keyStanza{}, err
+	}
+
+	kdf := e.kdf
+	return keyStanza{Type: passwordStanzaType, KeyID: e.keyID, KDF: &kdf, Salt: salt, KeyCheck: keyCheck,
+		SubkeySalt: subkeySalt, WrappedKey: wrapped}, nil
+}
+
+// unwrapWithPassword unwraps the file key of a password stanza with the KDF parameters recorded in it.
+// Stanzas without subkey salt wrap the file key with the master key directly.
+func unwrapWithPassword(stanza keyStanza, password string, masterKeys *masterKeyCache) ([]byte, error) {
+	if stanza.Type != passwordStanzaType {
+		return nil, errStanzaMismatch
+	}
+	kdf, _ := DefaultKDF(config.KDFScrypt)
+	if stanza.KDF != nil {
+		kdf = *stanza.KDF
+	}
+	master, err := masterKeys.get(kdf, []byte(password), stanza.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stanza.KeyCheck) > 0 {
+		keyCheck, err := keyCheckValue(master)
+		if err != nil {
+			return nil, err
+		}
+		if subtle.ConstantTimeCompare(keyCheck, stanza.KeyCheck) != 1 {
+			return nil, errStanzaMismatch
+		}
+	}
+
+	wrapKey := master
+	if len(stanza.SubkeySalt) > 0 {
+		if wrapKey, err = hkdf.Key(sha256.New, master, stanza.SubkeySalt, passwordSubkeyInfo, KeySize); err != nil {
+			return nil, err
+		}
+	}
+	fileKey, err := openKey(wrapKey, stanza.WrappedKey)
+	if err != nil {
+		if len(stanza.KeyCheck) > 0 {
+			return nil, fmt.Errorf("the password matches but the file key cannot be unwrapped, the file is damaged")
+		}
+		return nil, errStanzaMismatch
+	}
+	return fileKey, nil
+}
+
+// keyCheckValue identifies the master key of a password without revealing it
+func keyCheckValue(master []byte) ([]byte, error) {
+	return hkdf.Key(sha256.New, master, nil, keyCheckInfo, keyCheckSize)
+}
+
+// masterKeyCache holds derived master keys, so the parts of a backup run are decrypted with one KDF run
+type masterKeyCache struct {
+	sync.Mutex
+	keys map[[sha256.Size]byte][]byte
+}
+
+// WithMasterKeyCache returns the keys with a cache of derived master keys. The cache is shared by copies
+// of the returned keys and is released with them, so it lives as long as the service holding the keys.
+func (k DecryptionKeys) WithMasterKeyCache() DecryptionKeys {
+	if k.masterKeys == nil {
+		k.masterKeys = &masterKeyCache{keys: map[[sha256.Size]byte][]byte{}}
+	}
+	return k
+}
+
+// get derives the master key of a password, KDF parameters and salt or returns it from the cache, a nil
+// cache derives the key every time
+func (c *masterKeyCache) get(kdf KDFParams, password, salt []byte) ([]byte, error) {
+	if c == nil {
+		return kdf.deriveKey(password, salt)
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s/%d/%d/%d/%d/%d/%d/%d:%x:", kdf.Algorithm, kdf.N, kdf.R, kdf.P, kdf.Time, kdf.MemoryKiB, kdf.Threads, len(salt), salt)
+	hash.Write(password)
+	var id [sha256.Size]byte
+	hash.Sum(id[:0])
+
+	c.Lock()
+	master, found := c.keys[id]
+	c.Unlock()
+	if found {
+		return master, nil
+	}
+
+	master, err := kdf.deriveKey(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	c.Lock()
+	c.keys[id] = master
+	c.Unlock()
+	return master, nil
+}
+
+// passwordsFor returns the keyring password of a key ID, or all passwords if the ID is unknown
+func (k DecryptionKeys) passwordsFor(keyID string) []string {
+	if password, found := k.KeyedPasswords[keyID]; found && keyID != "" {
+		return []string{password}
+	}
+	passwords := append([]string{}, k.Passwords...)
+	for _, id := range slices.Sorted(maps.Keys(k.KeyedPasswords)) {
+		passwords = append(passwords, k.KeyedPasswords[id])
+	}
+	return passwords
+}
+
+// IsEmpty reports whether no password or identity is available
+func (k DecryptionKeys) IsEmpty() bool {
+	return len(k.Passwords) == 0 && len(k.KeyedPasswords) == 0 && len(k.Identities) == 0
+}
+
+// unwrap returns the file key of a stanza if one of the keys matches it
+func (k DecryptionKeys) unwrap(stanza keyStanza) ([]byte, error) {
+	switch stanza.Type {
+	case x25519StanzaType:
+		for _, identity := range k.Identities {
+			if stanza.KeyID != "" && stanza.KeyID != identity.Recipient().KeyID() {
+				continue
+			}
+			fileKey, err := identity.unwrap(stanza)
+			if !errors.Is(err, errStanzaMismatch) {
+				return fileKey, err
+			}
+		}
+	case passwordStanzaType:
+		for _, password := range k.passwordsFor(stanza.KeyID) {
+			fileKey, err := unwrapWithPassword(stanza, password, k.masterKeys)
+			if !errors.Is(err, errStanzaMismatch) {
+				return fileKey, err
+			}
+		}
+	}
+	return nil, errStanzaMismatch
+}
+
+// decryptVersionedFormat unwraps the file key of the ENC2 format with the first matching password or identity
+func decryptVersionedFormat(data []byte, keys DecryptionKeys) ([]byte, error) {
+	header, prefix, payload, err := parseVersionedFormat(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyIDs []string
+	for _, stanza := range header.Stanzas {
+		fileKey, err := keys.unwrap(stanza)
+		if errors.Is(err, errStanzaMismatch) {
+			if stanza.KeyID != "" {
+				keyIDs = append(keyIDs, stanza.KeyID)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return openPayload(fileKey, prefix, payload)
+	}
+
+	if keys.IsEmpty() {
+		return nil, fmt.Errorf("file is encrypted, a password, identity or keyring is required")
+	}
+	if len(keyIDs) > 0 {
+		return nil, fmt.Errorf("no password or identity matches the file (key IDs: %s)", strings.Join(keyIDs, ", "))
+	}
+	return nil, fmt.Errorf("no password or identity matches the file")
 }
 
-// decryptVersionedFormat decrypts future versioned format (not implemented)
-func decryptVersionedFormat(data, password []byte) ([]byte, error) {
-	// Future implementation for enhanced security
-	_ = data
-	_ = password
-	return nil, fmt.Errorf("v2 encryption format not yet implemented")
+// parseVersionedFormat splits ENC2 data into header, authenticated prefix and payload
+func parseVersionedFormat(data []byte) (encryptionHeader, []byte, []byte, error) {
+	var header encryptionHeader
+	if len(data) < 8 {
+		return header, nil, nil, fmt.Errorf("invalid encrypted data: header too short")
+	}
+	headerSize := binary.BigEndian.Uint32(data[4:8])
+	if headerSize > maxHeaderSize || int(headerSize) > len(data)-8 {
+		return header, nil, nil, fmt.Errorf("invalid encrypted data: header size %d", headerSize)
+	}
+
+	prefix := data[:8+headerSize]
+	if err := json.Unmarshal(prefix[8:], &header); err != nil {
+		return header, nil, nil, fmt.Errorf("invalid encryption header: %w", err)
+	}
+	if header.Version > HeaderVersion {
+		return header, nil, nil, fmt.Errorf("encryption header version %d is not supported, update aws-s3-backup", header.Version)
+	}
+	return header, prefix, data[len(prefix):], nil
+}
+
+// openPayload decrypts the ENC2 payload with the file key
+func openPayload(fileKey, prefix, payload []byte) ([]byte, error) {
+	gcm, err := createGCMCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, fmt.Errorf("invalid encrypted data: payload too short")
+	}
+
+	nonce, ciphertext := payload[:gcm.NonceSize()], payload[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("GCM decryption failed: %w", err)
+	}
+	return plaintext, nil
 }
 
-// decryptCurrentFormat decrypts current v1 format
+// decryptCurrentFormat decrypts the v1 format, which does not record its scrypt parameters
 func decryptCurrentFormat(data, password []byte) ([]byte, error) {
 	salt, cipherData := extractSaltAndData(data)
 
-	// Try new parameters first (N=128K)
-	if result, err := tryDecryptWithScryptParams(cipherData, password, salt, NewScryptN); err == nil {
-		return result, nil
+	// Try the parameters of the last v1 file first, then new (N=128K) and legacy (N=32K) ones with every p of v1
+	for _, params := range legacyScryptParams() {
+		if result, err := tryDecryptWithScryptParams(cipherData, password, salt, params[0], params[1]); err == nil {
+			lastLegacyParams.Store(&params)
+			return result, nil
+		}
 	}
 
-	// Fall back to legacy parameters (N=32K) for backward compatibility
-	if result, err := tryDecryptWithScryptParams(cipherData, password, salt, LegacyScryptN); err == nil {
-		return result, nil
-	}
+	return nil, fmt.Errorf("decryption failed with all v1 parameter sets")
+}
+
+// lastLegacyParams holds N and p of the last decrypted v1 file, the files of one backup share them
+var lastLegacyParams atomic.Pointer[[2]int]
 
-	return nil, fmt.Errorf("decryption failed with both parameter sets")
+// legacyScryptParams returns the N and p combinations to try for a v1 file
+func legacyScryptParams() [][2]int {
+	var candidates [][2]int
+	last := lastLegacyParams.Load()
+	if last != nil {
+		candidates = append(candidates, *last)
+	}
+	for _, n := range []int{NewScryptN, LegacyScryptN} {
+		for _, p := range legacyScryptPs() {
+			if last == nil || *last != [2]int{n, p} {
+				candidates = append(candidates, [2]int{n, p})
+			}
+		}
+	}
+	return candidates
 }
 
 // extractSaltAndData separates salt from encrypted data
@@ -272,8 +676,7 @@ func extractSaltAndData(data []byte) ([]byte, []byte) {
 }
 
 // tryDecryptWithScryptParams attempts decryption with specific scrypt parameters
-func tryDecryptWithScryptParams(data, password, salt []byte, N int) ([]byte, error) {
-	p := calculateScryptP()
+func tryDecryptWithScryptParams(data, password, salt []byte, N, p int) ([]byte, error) {
 	key, err := scrypt.Key(password, salt, N, ScryptR, p, KeySize)
 	if err != nil {
 		return nil, fmt.Errorf("scrypt key derivation failed: %w", err)
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Endpoint describes an S3-compatible storage such as MinIO, Ceph, Wasabi or Garage used instead of AWS S3
+type S3Endpoint struct {
+	URL               string
+	PathStyle         bool
+	CABundle          string
+	NoRegionDiscovery bool
+}
+
+var s3Endpoint S3Endpoint
+
+// SetS3Endpoint sets the endpoint all S3 clients use, the zero value uses AWS S3
+func SetS3Endpoint(endpoint S3Endpoint) {
+	s3Endpoint = endpoint
+}
+
+// CustomS3Endpoint reports whether an S3-compatible endpoint is used instead of AWS S3
+func CustomS3Endpoint() bool {
+	return s3Endpoint.URL != ""
+}
+
+// NewS3Client creates an S3 client for cfg that uses the configured endpoint and addressing style
+func NewS3Client(cfg aws.Config) *s3.Client {
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.UsePathStyle = s3Endpoint.PathStyle
+		if s3Endpoint.URL != "" {
+			o.BaseEndpoint = aws.String(s3Endpoint.URL)
+		}
+	})
+}
+
+// caBundleOption returns the config option that trusts the certificates of the CA bundle, nil without bundle
+func caBundleOption() (func(*config.LoadOptions) error, error) {
+	if s3Endpoint.CABundle == "" {
+		return nil, nil
+	}
+	bundle, err := os.ReadFile(s3Endpoint.CABundle)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read CA bundle: %w", err)
+	}
+	return config.WithCustomCABundle(bytes.NewReader(bundle)), nil
+}
+
+// RegionDescription describes the region of a bucket for logs, with flag and GDPR status for AWS regions
+func RegionDescription(region string) string {
+	if CustomS3Endpoint() {
+		return fmt.Sprintf("region: %s, endpoint: %s", region, s3Endpoint.URL)
+	}
+	regionInfo := GetRegionInfo(region)
+	gdprStatus := "⚠️  Non-GDPR"
+	if regionInfo.GDPRCompliant {
+		gdprStatus = "🔒 GDPR"
+	}
+	return fmt.Sprintf("region: %s %s %s %s", region, regionInfo.Flag, regionInfo.Country, gdprStatus)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -12,6 +13,7 @@ import (
 	"runtime"
 	"sort"
 	"strconv"
+	"strings"
 )
 
 // File splitting constants
@@ -79,6 +81,72 @@ func CombineFiles(downloadDir string) error {
 	return combineSplitGroups(splitGroups, downloadDir)
 }
 
+// CombineParts combines the given parts of split files next to them and returns the combined files.
+// Parts are grouped by their base name, every group must be complete and the parts are kept.
+func CombineParts(parts []string) ([]string, error) {
+	partPattern := regexp.MustCompile(`^(.+)-part(\d{5})$`)
+	groups := make(map[string][]string)
+	for _, part := range parts {
+		matches := partPattern.FindStringSubmatch(filepath.Base(part))
+		if matches == nil {
+			return nil, fmt.Errorf("❌ %s is not a part of a split file (expected <name>-part00001)", part)
+		}
+		outputPath := filepath.Join(filepath.Dir(part), matches[1])
+		groups[outputPath] = append(groups[outputPath], part)
+	}
+
+	outputPaths := make([]string, 0, len(groups))
+	for outputPath := range groups {
+		outputPaths = append(outputPaths, outputPath)
+	}
+	sort.Strings(outputPaths)
+
+	for _, outputPath := range outputPaths {
+		if err := combineCompleteGroup(groups[outputPath], outputPath); err != nil {
+			return nil, err
+		}
+	}
+	return outputPaths, nil
+}
+
+// combineCompleteGroup checks that no part is missing and writes the combined file without removing the parts
+func combineCompleteGroup(parts []string, outputPath string) error {
+	sortPartsByNumber(parts)
+	for i, part := range parts {
+		if extractPartNumber(part) != i+1 {
+			return fmt.Errorf("❌ part %05d of %s is missing", i+1, filepath.Base(outputPath))
+		}
+	}
+	if _, err := os.Stat(outputPath); err == nil {
+		return fmt.Errorf("❌ %s already exists", outputPath)
+	}
+
+	totalSize := calculateTotalSize(parts)
+	log.Printf("🔗 Combining %d parts: %s (%s) -> 1 file", len(parts), filepath.Base(outputPath), FormatBytes(totalSize))
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create combined file: %w", err)
+	}
+	progress := StartProgress("🔗 Combining "+filepath.Base(outputPath), totalSize)
+	for _, part := range parts {
+		if err = copyPartToOutput(part, output, progress); err != nil {
+			break
+		}
+	}
+	progress.Finish()
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		return err
+	}
+
+	log.Printf("✅ Successfully combined: %s (%s)", filepath.Base(outputPath), FormatBytes(totalSize))
+	return nil
+}
+
 // GetFileChecksum calculates SHA256 checksum of a file
 func GetFileChecksum(filePath string) (string, error) {
 	file, err := os.Open(filePath)
@@ -231,7 +299,7 @@ func createFileParts(file *os.File, filePath string, chunkSize int64, numParts i
 		}
 
 		partPath := fmt.Sprintf(PartNumFormat, filePath, partNum)
-		log.Printf("📦 Creating part %d/%d: %s", partNum, numParts, filepath.Base(partPath))
+		slog.Debug(fmt.Sprintf("📦 Creating part %d/%d: %s", partNum, numParts, filepath.Base(partPath)))
 
 		if err := os.WriteFile(partPath, buffer[:n], DefaultFilePerm); err != nil {
 			return nil, fmt.Errorf("failed to write part file: %w", err)
@@ -270,7 +338,7 @@ func processPotentialSplitFile(path string, info os.FileInfo, downloadDir string
 	if len(matches) == 3 {
 		baseName := matches[1]
 		key := buildSplitGroupKey(path, downloadDir, baseName)
-		log.Printf("🔍 Found split file: %s (group: %s)", filename, key)
+		slog.Debug(fmt.Sprintf("🔍 Found split file: %s (group: %s)", filename, key))
 		splitGroups[key] = append(splitGroups[key], path)
 	}
 
@@ -306,7 +374,7 @@ func combineSingleGroup(baseName string, parts []string, downloadDir string) err
 
 	sortPartsByNumber(parts)
 
-	if err := combineFileParts(parts, baseName, downloadDir); err != nil {
+	if err := combineFileParts(parts, baseName, downloadDir, totalSize); err != nil {
 		return err
 	}
 
@@ -346,7 +414,7 @@ func extractPartNumber(filename string) int {
 }
 
 // combineFileParts combines parts into single file
-func combineFileParts(parts []string, baseName, downloadDir string) error {
+func combineFileParts(parts []string, baseName, downloadDir string, totalSize int64) error {
 	outputPath := filepath.Join(downloadDir, baseName)
 
 	if err := os.MkdirAll(filepath.Dir(outputPath), DefaultDirPerm); err != nil {
@@ -359,34 +427,37 @@ func combineFileParts(parts []string, baseName, downloadDir string) error {
 	}
 	defer output.Close()
 
-	return copyAndCleanupParts(parts, output)
+	progress := StartProgress("🔗 Combining "+filepath.Base(baseName), totalSize)
+	defer progress.Finish()
+
+	return copyAndCleanupParts(parts, output, progress)
 }
 
 // copyAndCleanupParts copies parts to output and removes them
-func copyAndCleanupParts(parts []string, output *os.File) error {
+func copyAndCleanupParts(parts []string, output *os.File, progress *Progress) error {
 	for i, partPath := range parts {
-		log.Printf("🔗 Processing part %d/%d: %s", i+1, len(parts), filepath.Base(partPath))
+		slog.Debug(fmt.Sprintf("🔗 Processing part %d/%d: %s", i+1, len(parts), filepath.Base(partPath)))
 
-		if err := copyPartToOutput(partPath, output); err != nil {
+		if err := copyPartToOutput(partPath, output, progress); err != nil {
 			return err
 		}
 
 		if err := os.Remove(partPath); err != nil {
-			log.Printf("⚠️ Warning: Could not remove part file %s: %v", partPath, err)
+			slog.Warn(fmt.Sprintf("⚠️ Warning: Could not remove part file %s: %v", partPath, err), "event", EventCombine, "file", partPath, "error", err)
 		}
 	}
 	return nil
 }
 
 // copyPartToOutput copies single part to output file
-func copyPartToOutput(partPath string, output *os.File) error {
+func copyPartToOutput(partPath string, output *os.File, progress *Progress) error {
 	part, err := os.Open(partPath)
 	if err != nil {
 		return fmt.Errorf("failed to open part file: %w", err)
 	}
 	defer part.Close()
 
-	if _, err := io.Copy(output, part); err != nil {
+	if _, err := io.Copy(output, progress.Reader(part)); err != nil {
 		return fmt.Errorf("failed to copy part data: %w", err)
 	}
 
@@ -401,3 +472,38 @@ func cleanupAfterCombine(baseName, downloadDir string) {
 		log.Printf("🗑️ Removed instruction file: %s-HowToBuild.txt", filepath.Base(baseName))
 	}
 }
+
+// HowToBuildSuffix is appended to the name of a split archive for its instructions file
+const HowToBuildSuffix = "-HowToBuild.txt"
+
+// howToBuildParts starts the line of an instructions file that holds the number of parts
+const howToBuildParts = "Parts: "
+
+// WriteHowToBuild writes the instructions file of an archive split into the given number of parts
+func WriteHowToBuild(archivePath string, parts int) (string, error) {
+	howToFile := archivePath + HowToBuildSuffix
+	content := fmt.Sprintf("Use 'cat parts > combined' to rebuild\n%s%d\n", howToBuildParts, parts)
+	if err := os.WriteFile(howToFile, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to create how-to file: %w", err)
+	}
+	return howToFile, nil
+}
+
+// ReadHowToBuildParts returns the number of parts recorded in an instructions file,
+// 0 if the file was written by an older version that did not record it
+func ReadHowToBuildParts(howToFile string) (int, error) {
+	data, err := os.ReadFile(howToFile)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, found := strings.CutPrefix(strings.TrimSpace(line), howToBuildParts); found {
+			parts, err := strconv.Atoi(value)
+			if err != nil || parts < 1 {
+				return 0, fmt.Errorf("❌ invalid number of parts in %s: %q", filepath.Base(howToFile), value)
+			}
+			return parts, nil
+		}
+	}
+	return 0, nil
+}
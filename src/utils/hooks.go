@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// Hook names and environment
+const (
+	HookPre          = "pre"
+	HookPost         = "post"
+	HookOnError      = "on-error"
+	HookEnvPrefix    = "AWS_S3_BACKUP_"
+	HookKillWaitTime = 5 * time.Second
+)
+
+// ShellCommand creates a command that runs through the shell of the operating system
+func ShellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// RunHook runs a hook command with a timeout, env holds variables that are added with HookEnvPrefix.
+// The output is logged line by line, a non-zero exit code is returned as error.
+func RunHook(ctx context.Context, hook, command string, timeout time.Duration, env map[string]string) error {
+	if command == "" {
+		return nil
+	}
+
+	hookCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		hookCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := ShellCommand(hookCtx, command)
+	cmd.Env = append(os.Environ(), HookEnvPrefix+"HOOK="+hook)
+	for name, value := range env {
+		cmd.Env = append(cmd.Env, HookEnvPrefix+name+"="+value)
+	}
+	cmd.WaitDelay = HookKillWaitTime
+	setProcessGroup(cmd)
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	slog.Info(fmt.Sprintf("🪝 Running %s hook: %s", hook, command), "event", EventHook, "hook", hook)
+	start := time.Now()
+	err := cmd.Run()
+
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		slog.Info(fmt.Sprintf("🪝 [%s] %s", hook, scanner.Text()), "event", EventHook, "hook", hook)
+	}
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// Killed because the run was interrupted (SIGINT/SIGTERM), the run is cancelled and not failed
+		return fmt.Errorf("❌ %s hook cancelled: %w", hook, ctx.Err())
+	case errors.Is(hookCtx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("❌ %s hook timed out after %v", hook, timeout)
+	case err != nil:
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("❌ %s hook failed with exit code %d", hook, exitErr.ExitCode())
+		}
+		return fmt.Errorf("❌ %s hook failed: %w", hook, err)
+	}
+
+	slog.Info(fmt.Sprintf("✅ %s hook finished in %v", hook, time.Since(start).Round(time.Millisecond)),
+		"event", EventHook, "hook", hook, "exitCode", 0)
+	return nil
+}
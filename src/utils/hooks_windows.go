@@ -0,0 +1,8 @@
+//go:build windows
+
+package utils
+
+import "os/exec"
+
+// setProcessGroup keeps the default behaviour on Windows, only the shell is killed on cancellation
+func setProcessGroup(cmd *exec.Cmd) {}
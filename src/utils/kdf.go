@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/rtitz/aws-s3-backup/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDF defaults and the limits accepted from file headers
+const (
+	DefaultArgon2Time      = 3
+	DefaultArgon2MemoryKiB = 64 * 1024
+	DefaultArgon2Threads   = 4
+	maxScryptN             = 1 << 22
+	maxScryptR             = 32
+	maxScryptP             = 16
+	maxArgon2Time          = 16
+	maxArgon2MemoryKiB     = 4 * 1024 * 1024
+	minKDFSaltSize         = 16
+)
+
+// KDFParams describes how a key is derived from a password, they are stored in the header of every file
+type KDFParams struct {
+	Algorithm string `json:"algorithm"`
+	N         int    `json:"n,omitempty"`
+	R         int    `json:"r,omitempty"`
+	P         int    `json:"p,omitempty"`
+	Time      uint32 `json:"time,omitempty"`
+	MemoryKiB uint32 `json:"memoryKiB,omitempty"`
+	Threads   uint8  `json:"threads,omitempty"`
+}
+
+// DefaultKDF returns the default parameters of a KDF algorithm, an empty algorithm selects scrypt
+func DefaultKDF(algorithm string) (KDFParams, error) {
+	switch algorithm {
+	case "", config.KDFScrypt:
+		return KDFParams{Algorithm: config.KDFScrypt, N: NewScryptN, R: ScryptR, P: 1}, nil
+	case config.KDFArgon2id:
+		return KDFParams{Algorithm: config.KDFArgon2id, Time: DefaultArgon2Time, MemoryKiB: DefaultArgon2MemoryKiB, Threads: DefaultArgon2Threads}, nil
+	default:
+		return KDFParams{}, fmt.Errorf("❌ unknown KDF '%s', must be '%s' or '%s'", algorithm, config.KDFScrypt, config.KDFArgon2id)
+	}
+}
+
+// deriveKey derives a key from the password and salt with the recorded parameters
+func (k KDFParams) deriveKey(password, salt []byte) ([]byte, error) {
+	if err := k.validate(); err != nil {
+		return nil, err
+	}
+	if len(salt) < minKDFSaltSize {
+		return nil, fmt.Errorf("invalid KDF salt length %d", len(salt))
+	}
+
+	switch k.Algorithm {
+	case config.KDFScrypt:
+		key, err := scrypt.Key(password, salt, k.N, k.R, k.P, KeySize)
+		if err != nil {
+			return nil, fmt.Errorf("key derivation failed: %w", err)
+		}
+		return key, nil
+	default:
+		return argon2.IDKey(password, salt, k.Time, k.MemoryKiB, k.Threads, KeySize), nil
+	}
+}
+
+// validate rejects unknown algorithms and parameters that would exhaust memory or time when read from a header
+func (k KDFParams) validate() error {
+	switch k.Algorithm {
+	case config.KDFScrypt:
+		if k.N < 2 || k.N > maxScryptN || k.N&(k.N-1) != 0 || k.R < 1 || k.R > maxScryptR || k.P < 1 || k.P > maxScryptP {
+			return fmt.Errorf("unsupported scrypt parameters N=%d, r=%d, p=%d", k.N, k.R, k.P)
+		}
+	case config.KDFArgon2id:
+		if k.Time < 1 || k.Time > maxArgon2Time || k.MemoryKiB < 8*uint32(k.Threads) || k.MemoryKiB > maxArgon2MemoryKiB || k.Threads < 1 {
+			return fmt.Errorf("unsupported argon2id parameters time=%d, memory=%d KiB, threads=%d", k.Time, k.MemoryKiB, k.Threads)
+		}
+	default:
+		return fmt.Errorf("unsupported KDF '%s'", k.Algorithm)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadKeyring reads a keyring file with the keys of several backups. Each line is either an identity
+// (S3BK-SECRET-KEY-1...) or "<key ID> <password or secret reference>", empty lines and lines starting
+// with # are ignored. Secret references are resolved when the keyring is loaded.
+func LoadKeyring(ctx context.Context, path string) (DecryptionKeys, error) {
+	keys := DecryptionKeys{KeyedPasswords: map[string]string{}}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return keys, fmt.Errorf("❌ failed to read keyring file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(text, SecretKeyPrefix) {
+			identity, err := ParseIdentity(text)
+			if err != nil {
+				return keys, fmt.Errorf("❌ %s line %d: %w", path, line, err)
+			}
+			keys.Identities = append(keys.Identities, identity)
+			continue
+		}
+
+		keyID, secret, found := strings.Cut(text, " ")
+		secret = strings.TrimSpace(secret)
+		if !found || secret == "" {
+			return keys, fmt.Errorf("❌ %s line %d: expected '<key ID> <password>' or an identity", path, line)
+		}
+		if _, exists := keys.KeyedPasswords[keyID]; exists {
+			return keys, fmt.Errorf("❌ %s line %d: duplicate key ID '%s'", path, line, keyID)
+		}
+		password, err := ResolveSecret(ctx, secret)
+		if err != nil {
+			return keys, fmt.Errorf("❌ %s line %d: %w", path, line, err)
+		}
+		keys.KeyedPasswords[keyID] = password
+	}
+	if err := scanner.Err(); err != nil {
+		return keys, fmt.Errorf("❌ failed to read keyring file: %w", err)
+	}
+	if keys.IsEmpty() {
+		return keys, fmt.Errorf("❌ no keys found in %s", path)
+	}
+	return keys, nil
+}
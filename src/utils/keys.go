@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// X25519 key encoding and key wrapping constants
+const (
+	PublicKeyPrefix    = "s3bk1"
+	SecretKeyPrefix    = "S3BK-SECRET-KEY-1"
+	x25519StanzaType   = "X25519"
+	passwordStanzaType = "scrypt"
+	x25519WrapInfo     = "aws-s3-backup X25519 file key"
+)
+
+// keyEncoding encodes X25519 keys after their prefix
+var keyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// errStanzaMismatch is returned if a stanza was not created for a key
+var errStanzaMismatch = errors.New("stanza does not match key")
+
+// Recipient is an X25519 public key that files are encrypted to
+type Recipient struct {
+	key *ecdh.PublicKey
+}
+
+// Identity is an X25519 private key that decrypts files encrypted to its recipient
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// GenerateIdentity creates a new random identity
+func GenerateIdentity() (*Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate X25519 key: %w", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+// ParseRecipient parses a public key in the form s3bk1...
+func ParseRecipient(s string) (*Recipient, error) {
+	encoded, found := strings.CutPrefix(strings.TrimSpace(s), PublicKeyPrefix)
+	if !found {
+		return nil, fmt.Errorf("❌ invalid recipient '%s': must start with %s", s, PublicKeyPrefix)
+	}
+	raw, err := keyEncoding.DecodeString(strings.ToUpper(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("❌ invalid recipient '%s': %w", s, err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("❌ invalid recipient '%s': %w", s, err)
+	}
+	return &Recipient{key: key}, nil
+}
+
+// ParseRecipients parses a list of public keys
+func ParseRecipients(keys []string) ([]*Recipient, error) {
+	recipients := make([]*Recipient, 0, len(keys))
+	for _, key := range keys {
+		recipient, err := ParseRecipient(key)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// ParseIdentity parses a private key in the form S3BK-SECRET-KEY-1...
+func ParseIdentity(s string) (*Identity, error) {
+	encoded, found := strings.CutPrefix(strings.TrimSpace(s), SecretKeyPrefix)
+	if !found {
+		return nil, fmt.Errorf("❌ invalid identity: must start with %s", SecretKeyPrefix)
+	}
+	raw, err := keyEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("❌ invalid identity: %w", err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("❌ invalid identity: %w", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+// String returns the encoded public key
+func (r *Recipient) String() string {
+	return PublicKeyPrefix + strings.ToLower(keyEncoding.EncodeToString(r.key.Bytes()))
+}
+
+// String returns the encoded private key
+func (i *Identity) String() string {
+	return SecretKeyPrefix + keyEncoding.EncodeToString(i.key.Bytes())
+}
+
+// KeyID returns the fingerprint of the public key recorded in the stanzas wrapped for it
+func (r *Recipient) KeyID() string {
+	sum := sha256.Sum256(r.key.Bytes())
+	return hex.EncodeToString(sum[:4])
+}
+
+// Recipient returns the public key of the identity
+func (i *Identity) Recipient() *Recipient {
+	return &Recipient{key: i.key.PublicKey()}
+}
+
+// LoadIdentities reads all identities of an identity file, empty lines and lines starting with # are ignored
+func LoadIdentities(path string) ([]*Identity, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read identity file: %w", err)
+	}
+	defer file.Close()
+
+	var identities []*Identity
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		identity, err := ParseIdentity(text)
+		if err != nil {
+			return nil, fmt.Errorf("❌ %s line %d: %w", path, line, err)
+		}
+		identities = append(identities, identity)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("❌ failed to read identity file: %w", err)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("❌ no identities found in %s", path)
+	}
+	return identities, nil
+}
+
+// WriteIdentityFile writes a new identity file readable only by the owner, an existing file is not overwritten
+func WriteIdentityFile(path string, identity *Identity) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("❌ failed to create identity file: %w", err)
+	}
+	defer file.Close()
+
+	content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n",
+		time.Now().Format(time.RFC3339), identity.Recipient(), identity)
+	if _, err := file.WriteString(content); err != nil {
+		return fmt.Errorf("❌ failed to write identity file: %w", err)
+	}
+	return nil
+}
+
+// wrap encrypts a file key to the recipient with an ephemeral X25519 key
+func (r *Recipient) wrap(fileKey []byte) (keyStanza, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return keyStanza{}, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	shared, err := ephemeral.ECDH(r.key)
+	if err != nil {
+		return keyStanza{}, err
+	}
+
+	wrapKey, err := x25519WrapKey(shared, ephemeral.PublicKey().Bytes(), r.key.Bytes())
+	if err != nil {
+		return keyStanza{}, err
+	}
+	wrapped, err := sealKey(wrapKey, fileKey)
+	if err != nil {
+		return keyStanza{}, err
+	}
+	return keyStanza{Type: x25519StanzaType, KeyID: r.KeyID(), Ephemeral: ephemeral.PublicKey().Bytes(), WrappedKey: wrapped}, nil
+}
+
+// unwrap decrypts the file key of a stanza created for the recipient of the identity
+func (i *Identity) unwrap(stanza keyStanza) ([]byte, error) {
+	if stanza.Type != x25519StanzaType {
+		return nil, errStanzaMismatch
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(stanza.Ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+	shared, err := i.key.ECDH(ephemeral)
+	if err != nil {
+		return nil, errStanzaMismatch
+	}
+
+	wrapKey, err := x25519WrapKey(shared, stanza.Ephemeral, i.key.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := openKey(wrapKey, stanza.WrappedKey)
+	if err != nil {
+		return nil, errStanzaMismatch
+	}
+	return fileKey, nil
+}
+
+// x25519WrapKey derives the key wrapping key from the shared secret and both public keys
+func x25519WrapKey(shared, ephemeral, recipient []byte) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	return hkdf.Key(sha256.New, shared, salt, x25519WrapInfo, KeySize)
+}
+
+// sealKey encrypts a key with a single-use wrapping key, the zero nonce is safe as the wrapping key is never reused
+func sealKey(wrapKey, key []byte) ([]byte, error) {
+	gcm, err := createGCMCipher(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nil, make([]byte, gcm.NonceSize()), key, nil), nil
+}
+
+// openKey decrypts a key encrypted with sealKey
+func openKey(wrapKey, wrapped []byte) ([]byte, error) {
+	gcm, err := createGCMCipher(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, make([]byte, gcm.NonceSize()), wrapped, nil)
+}
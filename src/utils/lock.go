@@ -0,0 +1,303 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// Repository lock constants
+const (
+	LockObjectName         = ".aws-s3-backup.lock"
+	lockPreconditionFailed = "PreconditionFailed"
+	lockConditionConflict  = "ConditionalRequestConflict"
+	// lockMaxRefreshFailures is the number of failed refreshes in a row after which a lock is given up,
+	// well before it becomes stale for other writers
+	lockMaxRefreshFailures = 3
+)
+
+// LockHeartbeatInterval is the interval the lock object is refreshed in, shorter in tests
+var LockHeartbeatInterval = 1 * time.Minute
+
+// ErrLocked is returned if another writer holds the lock of a prefix
+var ErrLocked = errors.New("prefix is locked by another writer")
+
+// ErrLockLost is the cause of the lock context if the lock was taken over or could not be refreshed
+var ErrLockLost = errors.New("lock lost")
+
+// LockInfo is the content of a lock object
+type LockInfo struct {
+	ID        string    `json:"id"`
+	Host      string    `json:"host"`
+	PID       int       `json:"pid"`
+	Mode      string    `json:"mode"`
+	Created   time.Time `json:"created"`
+	Heartbeat time.Time `json:"heartbeat"`
+}
+
+// Lock is a held lock object in a bucket, the heartbeat keeps it from becoming stale
+type Lock struct {
+	cfg    aws.Config
+	bucket string
+	key    string
+	info   LockInfo
+	mu     sync.Mutex
+	etag   string
+	stop   chan struct{}
+	done   chan struct{}
+	cancel context.CancelCauseFunc
+}
+
+// Bucket returns the bucket of the lock object
+func (l *Lock) Bucket() string {
+	return l.bucket
+}
+
+// Key returns the key of the lock object
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// LockKey returns the key of the lock object for a prefix
+func LockKey(prefix string) string {
+	if prefix == "" {
+		return LockObjectName
+	}
+	return NormalizePath(prefix) + "/" + LockObjectName
+}
+
+// IsStale reports whether the lock holder has not sent a heartbeat within staleAfter
+func (l LockInfo) IsStale(now time.Time, staleAfter time.Duration) bool {
+	return now.Sub(l.Heartbeat) > staleAfter
+}
+
+// String describes the lock holder
+func (l LockInfo) String() string {
+	return fmt.Sprintf("%s (PID %d, %s) since %s, last heartbeat %s", l.Host, l.PID, l.Mode,
+		l.Created.Format(time.RFC3339), l.Heartbeat.Format(time.RFC3339))
+}
+
+// AcquireLock creates the lock object of a prefix with a conditional write. A stale lock is taken over,
+// a lock held by another writer results in an error wrapping ErrLocked. Work under the lock must use the
+// returned context, it is cancelled with a cause wrapping ErrLockLost if the lock is taken over or cannot be refreshed.
+func AcquireLock(ctx context.Context, cfg aws.Config, bucket, prefix, mode string, staleAfter time.Duration) (*Lock, context.Context, error) {
+	regionCfg, err := getRegionSpecificConfig(ctx, cfg, bucket)
+	if err != nil {
+		regionCfg = cfg
+	}
+
+	host, _ := os.Hostname()
+	now := time.Now().UTC()
+	lock := &Lock{
+		cfg:    regionCfg,
+		bucket: bucket,
+		key:    LockKey(prefix),
+		info:   LockInfo{ID: newLockID(), Host: host, PID: os.Getpid(), Mode: mode, Created: now, Heartbeat: now},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	// Create the lock only if no lock object exists
+	err = lock.put(ctx, &s3.PutObjectInput{IfNoneMatch: aws.String("*")})
+	if isConditionFailed(err) {
+		err = lock.takeOverStale(ctx, staleAfter)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("🔒 Lock acquired: s3://%s/%s", bucket, lock.key)
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	lock.cancel = cancel
+	go lock.heartbeat()
+	return lock, lockCtx, nil
+}
+
+// takeOverStale replaces the existing lock if it is stale, the write only succeeds if the lock is unchanged
+func (l *Lock) takeOverStale(ctx context.Context, staleAfter time.Duration) error {
+	existing, etag, err := ReadLock(ctx, l.cfg, l.bucket, l.key)
+	if err != nil {
+		return err
+	}
+	if !existing.IsStale(time.Now(), staleAfter) {
+		return fmt.Errorf("❌ s3://%s/%s: %w: %s", l.bucket, l.key, ErrLocked, existing)
+	}
+
+	slog.Warn(fmt.Sprintf("⚠️ Taking over stale lock s3://%s/%s held by %s", l.bucket, l.key, existing),
+		"event", EventLock, "bucket", l.bucket, "key", l.key, "host", existing.Host, "pid", existing.PID)
+	err = l.put(ctx, &s3.PutObjectInput{IfMatch: aws.String(etag)})
+	if isConditionFailed(err) {
+		return fmt.Errorf("❌ s3://%s/%s: %w: lock changed while taking over a stale lock", l.bucket, l.key, ErrLocked)
+	}
+	return err
+}
+
+// Release stops the heartbeat and deletes the lock object if it is still held by this process
+func (l *Lock) Release(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	close(l.stop)
+	<-l.done
+	l.cancel(nil)
+
+	l.mu.Lock()
+	etag := l.etag
+	l.mu.Unlock()
+
+	client := NewS3Client(l.cfg)
+	_, err := client.DeleteObject(context.WithoutCancel(ctx), &s3.DeleteObjectInput{
+		Bucket:  &l.bucket,
+		Key:     &l.key,
+		IfMatch: aws.String(etag),
+	})
+	if isConditionFailed(err) {
+		return fmt.Errorf("❌ lock s3://%s/%s was taken over by another writer", l.bucket, l.key)
+	}
+	if err != nil {
+		return fmt.Errorf("❌ failed to release lock s3://%s/%s: %w", l.bucket, l.key, err)
+	}
+
+	log.Printf("🔓 Lock released: s3://%s/%s", l.bucket, l.key)
+	return nil
+}
+
+// heartbeat refreshes the lock periodically until Release is called. If another writer took the lock over
+// or it failed to refresh too often, the lock context is cancelled and the heartbeat stops.
+func (l *Lock) heartbeat() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(LockHeartbeatInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			etag := l.etag
+			l.info.Heartbeat = time.Now().UTC()
+			l.mu.Unlock()
+
+			ctx, cancel := context.WithTimeout(context.Background(), LockHeartbeatInterval)
+			err := l.put(ctx, &s3.PutObjectInput{IfMatch: aws.String(etag)})
+			cancel()
+			if err == nil {
+				failures = 0
+				continue
+			}
+
+			failures++
+			slog.Error(fmt.Sprintf("❌ Failed to refresh lock s3://%s/%s (%d/%d): %v", l.bucket, l.key, failures, lockMaxRefreshFailures, err),
+				"event", EventLock, "bucket", l.bucket, "key", l.key, "error", err)
+			if isConditionFailed(err) {
+				l.cancel(fmt.Errorf("❌ %w: s3://%s/%s was taken over by another writer", ErrLockLost, l.bucket, l.key))
+				return
+			}
+			if failures >= lockMaxRefreshFailures {
+				l.cancel(fmt.Errorf("❌ %w: s3://%s/%s could not be refreshed: %w", ErrLockLost, l.bucket, l.key, err))
+				return
+			}
+		}
+	}
+}
+
+// put writes the lock info with the conditions of input and remembers the new ETag
+func (l *Lock) put(ctx context.Context, input *s3.PutObjectInput) error {
+	l.mu.Lock()
+	data, err := json.MarshalIndent(l.info, "", "  ")
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	input.Bucket = &l.bucket
+	input.Key = &l.key
+	input.Body = bytes.NewReader(data)
+	input.ContentType = aws.String("application/json")
+
+	client := NewS3Client(l.cfg)
+	result, err := client.PutObject(ctx, input)
+	if err != nil {
+		if isConditionFailed(err) {
+			return err
+		}
+		return fmt.Errorf("❌ failed to write lock s3://%s/%s: %w", l.bucket, l.key, err)
+	}
+
+	l.mu.Lock()
+	l.etag = aws.ToString(result.ETag)
+	l.mu.Unlock()
+	return nil
+}
+
+// ReadLock reads the lock object with its ETag
+func ReadLock(ctx context.Context, cfg aws.Config, bucket, key string) (LockInfo, string, error) {
+	var info LockInfo
+
+	result, err := NewS3Client(cfg).GetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key})
+	if err != nil {
+		return info, "", fmt.Errorf("❌ failed to read lock s3://%s/%s: %w", bucket, key, err)
+	}
+	defer result.Body.Close()
+
+	data, err := io.ReadAll(result.Body)
+	if err != nil {
+		return info, "", fmt.Errorf("❌ failed to read lock s3://%s/%s: %w", bucket, key, err)
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, "", fmt.Errorf("❌ invalid lock object s3://%s/%s: %w", bucket, key, err)
+	}
+	return info, aws.ToString(result.ETag), nil
+}
+
+// ForceUnlock deletes the lock object of a prefix regardless of its holder
+func ForceUnlock(ctx context.Context, cfg aws.Config, bucket, prefix string) error {
+	regionCfg, err := getRegionSpecificConfig(ctx, cfg, bucket)
+	if err != nil {
+		regionCfg = cfg
+	}
+
+	key := LockKey(prefix)
+	if info, _, err := ReadLock(ctx, regionCfg, bucket, key); err == nil {
+		log.Printf("🔓 Removing lock held by %s", info)
+	}
+
+	if _, err := NewS3Client(regionCfg).DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &bucket, Key: &key}); err != nil {
+		return fmt.Errorf("❌ failed to remove lock s3://%s/%s: %w", bucket, key, err)
+	}
+	log.Printf("🔓 Lock removed: s3://%s/%s", bucket, key)
+	return nil
+}
+
+// isConditionFailed checks if a conditional write failed because the object exists or changed
+func isConditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode() == lockPreconditionFailed || apiErr.ErrorCode() == lockConditionConflict
+	}
+	return false
+}
+
+// newLockID creates a random identifier for a lock holder
+func newLockID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
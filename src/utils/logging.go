@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Log format names
+const (
+	LogFormatPlain = "plain"
+	LogFormatText  = "text"
+	LogFormatJSON  = "json"
+)
+
+// Structured log event names
+const (
+	EventUpload   = "upload"
+	EventDownload = "download"
+	EventSkip     = "skip"
+	EventRetry    = "retry"
+	EventDecrypt  = "decrypt"
+	EventEncrypt  = "encrypt"
+	EventRestore  = "restore"
+	EventHook     = "hook"
+	EventLock     = "lock"
+	EventCombine  = "combine"
+	EventExtract  = "extract"
+	EventSchedule = "schedule"
+	EventSecret   = "secret"
+	EventMetrics  = "metrics"
+	EventReport   = "report"
+)
+
+// plainHandler writes records in the classic "date time message" format without fields
+type plainHandler struct {
+	out   io.Writer
+	level slog.Leveler
+	mu    *sync.Mutex
+}
+
+// SetupLogging configures the default logger, returns a function to close the log file
+func SetupLogging(level, format, logFile string) (func() error, error) {
+	logLevel, err := ParseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	var out io.Writer = &terminalLogWriter{out: os.Stderr}
+	closeLog := func() error { return nil }
+
+	if logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, DefaultFilePerm)
+		if err != nil {
+			return nil, fmt.Errorf("❌ failed to open log file: %w", err)
+		}
+		out = io.MultiWriter(out, file)
+		closeLog = file.Close
+	}
+
+	handler, err := newLogHandler(out, logLevel, format)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return closeLog, nil
+}
+
+// ParseLogLevel converts a level name into a slog level
+func ParseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("❌ invalid log level '%s', must be debug, info, warn or error", level)
+	}
+}
+
+// newLogHandler creates the slog handler for the requested format
+func newLogHandler(out io.Writer, level slog.Level, format string) (slog.Handler, error) {
+	options := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(format) {
+	case "", LogFormatPlain:
+		return &plainHandler{out: out, level: level, mu: &sync.Mutex{}}, nil
+	case LogFormatText:
+		return slog.NewTextHandler(out, options), nil
+	case LogFormatJSON:
+		return slog.NewJSONHandler(out, options), nil
+	default:
+		return nil, fmt.Errorf("❌ invalid log format '%s', must be plain, text or json", format)
+	}
+}
+
+// Enabled reports whether the handler handles records at the given level
+func (h *plainHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle writes the record as a plain log line
+func (h *plainHandler) Handle(_ context.Context, record slog.Record) error {
+	line := record.Time.Format("2006/01/02 15:04:05") + " " + record.Message + "\n"
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.out, line)
+	return err
+}
+
+// WithAttrs returns the handler unchanged, plain output has no fields
+func (h *plainHandler) WithAttrs(_ []slog.Attr) slog.Handler {
+	return h
+}
+
+// WithGroup returns the handler unchanged, plain output has no fields
+func (h *plainHandler) WithGroup(_ string) slog.Handler {
+	return h
+}
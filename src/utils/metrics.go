@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric types of the Prometheus text exposition format
+const (
+	MetricCounter = "counter"
+	MetricGauge   = "gauge"
+)
+
+// Metric names exported by the application
+const (
+	MetricPrefix             = "aws_s3_backup_"
+	MetricRetriesTotal       = MetricPrefix + "retries_total"
+	MetricObjectsTotal       = MetricPrefix + "objects_total"
+	MetricBytesTotal         = MetricPrefix + "bytes_total"
+	MetricRunDuration        = MetricPrefix + "run_duration_seconds"
+	MetricRunSuccess         = MetricPrefix + "run_success"
+	MetricRunTimestamp       = MetricPrefix + "run_timestamp_seconds"
+	MetricTaskLastSuccess    = MetricPrefix + "task_last_success_timestamp_seconds"
+	MetricTaskDuration       = MetricPrefix + "task_duration_seconds"
+	MetricTaskBytes          = MetricPrefix + "task_bytes"
+	MetricTaskParts          = MetricPrefix + "task_parts"
+	MetricTaskFailures       = MetricPrefix + "task_failures"
+	metricsReadHeaderTimeout = 10 * time.Second
+)
+
+// Metrics is a minimal registry that renders the Prometheus text exposition format
+type Metrics struct {
+	mu       sync.Mutex
+	families map[string]*metricFamily
+}
+
+// metricFamily holds all samples of one metric name
+type metricFamily struct {
+	help    string
+	kind    string
+	samples map[string]float64
+}
+
+// DefaultMetrics is the registry used by the application
+var DefaultMetrics = NewMetrics()
+
+// NewMetrics creates an empty registry
+func NewMetrics() *Metrics {
+	return &Metrics{families: make(map[string]*metricFamily)}
+}
+
+// SetGauge sets a gauge sample, labels are given as name/value pairs
+func (m *Metrics) SetGauge(name, help string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.family(name, help, MetricGauge).samples[formatLabels(labels)] = value
+}
+
+// AddCounter adds a value to a counter sample, labels are given as name/value pairs
+func (m *Metrics) AddCounter(name, help string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.family(name, help, MetricCounter).samples[formatLabels(labels)] += value
+}
+
+// family returns the metric family, creating it if needed (caller holds the lock)
+func (m *Metrics) family(name, help, kind string) *metricFamily {
+	family, exists := m.families[name]
+	if !exists {
+		family = &metricFamily{help: help, kind: kind, samples: make(map[string]float64)}
+		m.families[name] = family
+	}
+	return family
+}
+
+// WriteText writes all metrics in the Prometheus text exposition format
+func (m *Metrics) WriteText(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		family := m.families[name]
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, family.help, name, family.kind)
+
+		labelSets := make([]string, 0, len(family.samples))
+		for labels := range family.samples {
+			labelSets = append(labelSets, labels)
+		}
+		sort.Strings(labelSets)
+		for _, labels := range labelSets {
+			fmt.Fprintf(bw, "%s%s %s\n", name, labels, strconv.FormatFloat(family.samples[labels], 'f', -1, 64))
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteTextfile atomically writes the metrics for the node_exporter textfile collector
+func (m *Metrics) WriteTextfile(path string) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("❌ failed to create metrics file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if err := m.WriteText(tmpFile); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("❌ failed to write metrics file: %w", err)
+	}
+	if err := tmpFile.Chmod(DefaultFilePerm); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("❌ failed to write metrics file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("❌ failed to write metrics file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		return fmt.Errorf("❌ failed to write metrics file: %w", err)
+	}
+	return nil
+}
+
+// KeepPreviousSamples copies samples of the given metrics from an existing textfile
+// when they are not set in this run (e.g. the last success timestamp of a failed task)
+func (m *Metrics) KeepPreviousSamples(path string, names ...string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Families this run did not create (e.g. no task succeeded) are created with the HELP and TYPE
+	// of the textfile, which precede their samples
+	helps := make(map[string]string)
+	kinds := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for _, name := range names {
+			if help, found := strings.CutPrefix(line, "# HELP "+name+" "); found {
+				helps[name] = help
+				continue
+			}
+			if kind, found := strings.CutPrefix(line, "# TYPE "+name+" "); found {
+				kinds[name] = kind
+				continue
+			}
+			if !strings.HasPrefix(line, name) {
+				continue
+			}
+
+			labelsAndValue := strings.TrimPrefix(line, name)
+			separator := strings.LastIndex(labelsAndValue, " ")
+			if separator < 0 || (labelsAndValue[0] != '{' && labelsAndValue[0] != ' ') {
+				continue
+			}
+			labels, value := labelsAndValue[:separator], labelsAndValue[separator+1:]
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			family := m.family(name, helps[name], cmp.Or(kinds[name], MetricGauge))
+			if _, set := family.samples[labels]; !set {
+				family.samples[labels] = parsed
+			}
+		}
+	}
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteText(w)
+}
+
+// StartMetricsServer serves DefaultMetrics on /metrics in the background, an address that cannot be bound is an error
+func StartMetricsServer(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("❌ failed to listen on metrics address %s: %w", address, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", DefaultMetrics)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: metricsReadHeaderTimeout}
+
+	log.Printf("📈 Serving metrics on http://%s/metrics", listener.Addr())
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error(fmt.Sprintf("❌ Metrics endpoint failed: %v", err), "event", EventMetrics, "address", address, "error", err)
+		}
+	}()
+	return nil
+}
+
+// formatLabels renders name/value pairs as a Prometheus label set
+func formatLabels(labels []string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+
+	var parts []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, labels[i], value))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
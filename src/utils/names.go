@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rtitz/aws-s3-backup/config"
+)
+
+// Obfuscated object names and the manifest that maps them to the original keys
+const (
+	ManifestPrefix       = "aws-s3-backup-manifest-"
+	ManifestVersion      = 1
+	obfuscatedNameLength = 32
+	nameKeyLength        = 32
+	objectNamesSaltInfo  = "aws-s3-backup object names"
+)
+
+// ObjectNamer derives obfuscated object names with the HMAC name key of a prefix
+type ObjectNamer struct {
+	key    []byte
+	prefix string
+}
+
+// Manifest maps the obfuscated keys of a backup run to the original keys, it is stored encrypted.
+// The name key is kept in the manifest, so the names of a prefix do not change when it is rekeyed.
+type Manifest struct {
+	Version int               `json:"version"`
+	Created time.Time         `json:"created"`
+	NameKey []byte            `json:"nameKey,omitempty"`
+	Objects map[string]string `json:"objects"`
+}
+
+// NewObjectNamer returns the namer of the objects below a prefix. The key is the same in every run of
+// a task, so existing objects are still recognized.
+func NewObjectNamer(key []byte, prefix string) *ObjectNamer {
+	namer := &ObjectNamer{key: key}
+	if prefix != "" {
+		namer.prefix = NormalizePath(prefix) + "/"
+	}
+	return namer
+}
+
+// NewNameKey returns a random name key for the first run of a prefix
+func NewNameKey() ([]byte, error) {
+	key := make([]byte, nameKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("❌ failed to generate object name key: %w", err)
+	}
+	return key, nil
+}
+
+// DeriveNameKey derives the name key of manifests without a stored key from the secret they were written
+// with. The salt depends on bucket and prefix.
+func DeriveNameKey(password string, kdf KDFParams, bucket, prefix string) ([]byte, error) {
+	salt := sha256.Sum256([]byte(objectNamesSaltInfo + "\x00" + bucket + "\x00" + prefix))
+	key, err := kdf.deriveKey([]byte(password), salt[:])
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to derive object name key: %w", err)
+	}
+	return key, nil
+}
+
+// ObjectKey returns the obfuscated key of an object below the prefix, the encryption extension is kept
+func (n *ObjectNamer) ObjectKey(key string) string {
+	mac := hmac.New(sha256.New, n.key)
+	mac.Write([]byte(key))
+	return n.prefix + hex.EncodeToString(mac.Sum(nil))[:obfuscatedNameLength] + "." + config.EncryptionExt
+}
+
+// NewManifest creates an empty manifest
+func NewManifest() *Manifest {
+	return &Manifest{Version: ManifestVersion, Created: time.Now().UTC(), Objects: map[string]string{}}
+}
+
+// ManifestKey returns the key of the manifest of a run below the prefix
+func ManifestKey(prefix string, created time.Time) string {
+	return ManifestKeyPrefix(prefix) + created.UTC().Format("20060102T150405.000000000Z") + "." + config.EncryptionExt
+}
+
+// ManifestKeyPrefix returns the common start of the manifest keys below the prefix, they sort by creation time
+func ManifestKeyPrefix(prefix string) string {
+	if prefix == "" {
+		return ManifestPrefix
+	}
+	return NormalizePath(prefix) + "/" + ManifestPrefix
+}
+
+// IsManifestKey reports whether an object key is a manifest
+func IsManifestKey(key string) bool {
+	name := path.Base(key)
+	return strings.HasPrefix(name, ManifestPrefix) && strings.HasSuffix(name, "."+config.EncryptionExt)
+}
+
+// EncryptManifest encodes and encrypts the manifest
+func EncryptManifest(manifest *Manifest, encryptor *Encryptor) ([]byte, error) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return encryptor.EncryptData(data)
+}
+
+// DecryptManifest decrypts and decodes a manifest
+func DecryptManifest(data []byte, keys DecryptionKeys) (*Manifest, error) {
+	plaintext, err := DecryptData(data, keys)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(plaintext, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.Version > ManifestVersion {
+		return nil, fmt.Errorf("manifest version %d is not supported, update %s", manifest.Version, config.AppName)
+	}
+	return &manifest, nil
+}
@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	config_app "github.com/rtitz/aws-s3-backup/config"
+)
+
+// Notification constants
+const (
+	NotificationTimeout     = 30 * time.Second
+	SMTPImplicitTLSPort     = 465
+	DefaultWebhookBody      = `{"text": {{json .Text}}}`
+	maxWebhookErrorBodySize = 1024
+)
+
+// NotificationMessage holds the data of a notification, it is available in webhook body templates
+type NotificationMessage struct {
+	Event    string
+	Mode     string
+	Status   string
+	ExitCode int
+	Error    string
+	Subject  string
+	Summary  string
+	Host     string
+	Time     time.Time
+}
+
+// Text returns subject and summary as a single message
+func (m NotificationMessage) Text() string {
+	if m.Summary == "" {
+		return m.Subject
+	}
+	return m.Subject + "\n" + m.Summary
+}
+
+// SendNotifications sends the message to all notifiers configured for its event, failures are logged only
+func SendNotifications(ctx context.Context, notifications []config_app.Notification, message NotificationMessage) {
+	if message.Host == "" {
+		message.Host, _ = os.Hostname()
+	}
+	if message.Time.IsZero() {
+		message.Time = time.Now()
+	}
+
+	// Notify even if the run was interrupted
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), NotificationTimeout)
+	defer cancel()
+
+	for _, notification := range notifications {
+		if !notification.NotifiesOn(message.Event) {
+			continue
+		}
+
+		var err error
+		switch strings.ToLower(notification.Type) {
+		case config_app.NotifyWebhook:
+			err = sendWebhook(ctx, notification, message)
+		case config_app.NotifySMTP:
+			err = sendEmail(ctx, notification, message)
+		default:
+			err = fmt.Errorf("unknown notification type '%s'", notification.Type)
+		}
+
+		if err != nil {
+			slog.Warn(fmt.Sprintf("⚠️ Failed to send %s notification: %v", notification.Type, err),
+				"event", "notify", "type", notification.Type, "error", err.Error())
+			continue
+		}
+		log.Printf("📣 Sent %s notification (%s)", notification.Type, message.Event)
+	}
+}
+
+// StripURLCredentials removes the user and password of a URL, a URL that cannot be parsed is removed completely
+func StripURLCredentials(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+// RenderNotificationBody renders a webhook body template, the json function quotes a value as JSON
+func RenderNotificationBody(bodyTemplate string, message NotificationMessage) (string, error) {
+	if bodyTemplate == "" {
+		bodyTemplate = DefaultWebhookBody
+	}
+
+	tmpl, err := template.New("body").Funcs(template.FuncMap{
+		"json": func(value any) (string, error) {
+			data, err := json.Marshal(value)
+			return string(data), err
+		},
+	}).Parse(bodyTemplate)
+	if err != nil {
+		return "", fmt.Errorf("❌ invalid body template: %w", err)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, message); err != nil {
+		return "", fmt.Errorf("❌ failed to render body template: %w", err)
+	}
+	return body.String(), nil
+}
+
+// sendWebhook posts the rendered body to the webhook URL
+func sendWebhook(ctx context.Context, notification config_app.Notification, message NotificationMessage) error {
+	body, err := RenderNotificationBody(notification.BodyTemplate, message)
+	if err != nil {
+		return err
+	}
+
+	method := notification.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	webhookURL, err := ResolveSecret(ctx, notification.URL)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), webhookURL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range notification.Headers {
+		value, err := ResolveSecret(ctx, value)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookErrorBodySize))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// sendEmail sends the message as plain text email, STARTTLS is used if offered, port 465 uses implicit TLS
+func sendEmail(ctx context.Context, notification config_app.Notification, message NotificationMessage) error {
+	port := notification.SMTPPort.Or(config_app.DefaultSMTPPort)
+	address := net.JoinHostPort(notification.SMTPHost, strconv.FormatInt(port, 10))
+
+	var auth smtp.Auth
+	if notification.SMTPUsername != "" {
+		password, err := ResolveSecret(ctx, notification.SMTPPassword)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", notification.SMTPUsername, password, notification.SMTPHost)
+	}
+
+	mail := buildEmail(notification.From, notification.To, message)
+	if port != SMTPImplicitTLSPort {
+		return smtp.SendMail(address, auth, notification.From, notification.To, mail)
+	}
+
+	dialer := &net.Dialer{Timeout: NotificationTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: notification.SMTPHost})
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, notification.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(notification.From); err != nil {
+		return err
+	}
+	for _, recipient := range notification.To {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(mail); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildEmail creates the email headers and body
+func buildEmail(from string, to []string, message NotificationMessage) []byte {
+	var mail strings.Builder
+	fmt.Fprintf(&mail, "From: %s\r\n", from)
+	fmt.Fprintf(&mail, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&mail, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&mail, "Date: %s\r\n", message.Time.Format(time.RFC1123Z))
+	mail.WriteString("MIME-Version: 1.0\r\n")
+	mail.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	mail.WriteString(strings.ReplaceAll(message.Summary, "\n", "\r\n"))
+	mail.WriteString("\r\n")
+	return []byte(mail.String())
+}
@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/rtitz/aws-s3-backup/config"
+)
+
+// objectLockNotFound is the error code of a bucket without Object Lock
+const objectLockNotFound = "ObjectLockConfigurationNotFoundError"
+
+// ObjectLock holds the retention and legal hold of uploaded objects (WORM). The retention ends
+// RetentionDays after the upload or at RetainUntil. A nil value uploads objects without lock.
+type ObjectLock struct {
+	Mode          types.ObjectLockMode
+	RetentionDays int64
+	RetainUntil   time.Time
+	LegalHold     bool
+}
+
+// NewObjectLock creates the object lock of a task, nil without retention mode and legal hold
+func NewObjectLock(mode string, retentionDays int64, retainUntil string, legalHold bool) (*ObjectLock, error) {
+	if mode == "" && !legalHold {
+		return nil, nil
+	}
+	lock := &ObjectLock{Mode: types.ObjectLockMode(mode), RetentionDays: retentionDays, LegalHold: legalHold}
+	if retainUntil != "" {
+		date, err := time.Parse(config.ObjectLockDateFormat, retainUntil)
+		if err != nil {
+			return nil, fmt.Errorf("❌ invalid ObjectLockRetainUntil '%s', use YYYY-MM-DD", retainUntil)
+		}
+		if !date.After(time.Now()) {
+			return nil, fmt.Errorf("❌ ObjectLockRetainUntil %s is in the past", retainUntil)
+		}
+		lock.RetainUntil = date
+	}
+	return lock, nil
+}
+
+// retainUntilDate returns the end of the retention of an object uploaded at now
+func (l *ObjectLock) retainUntilDate(now time.Time) time.Time {
+	if !l.RetainUntil.IsZero() {
+		return l.RetainUntil
+	}
+	return now.UTC().Add(time.Duration(l.RetentionDays) * 24 * time.Hour)
+}
+
+// String describes the lock for logs and reports
+func (l *ObjectLock) String() string {
+	var parts []string
+	switch {
+	case l == nil:
+		return "none"
+	case l.Mode != "" && !l.RetainUntil.IsZero():
+		parts = append(parts, fmt.Sprintf("%s until %s", l.Mode, l.RetainUntil.UTC().Format(time.RFC3339)))
+	case l.Mode != "":
+		parts = append(parts, fmt.Sprintf("%s for %d days", l.Mode, l.RetentionDays))
+	}
+	if l.LegalHold {
+		parts = append(parts, "legal hold")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// applyToPut sets the retention and legal hold headers of an upload
+func (l *ObjectLock) applyToPut(input *s3.PutObjectInput) {
+	if l == nil {
+		return
+	}
+	if l.Mode != "" {
+		input.ObjectLockMode = l.Mode
+		input.ObjectLockRetainUntilDate = aws.Time(l.retainUntilDate(time.Now()))
+	}
+	if l.LegalHold {
+		input.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatusOn
+	}
+}
+
+// GetObjectLock returns the active retention and legal hold of an object, nil if it is not locked.
+// sse provides the SSE-C key and may be nil.
+func GetObjectLock(ctx context.Context, cfg aws.Config, bucket, key string, sse *ServerSideEncryption) (*ObjectLock, error) {
+	regionCfg, err := getRegionSpecificConfig(ctx, cfg, bucket)
+	if err != nil {
+		regionCfg = cfg
+	}
+
+	input := &s3.HeadObjectInput{Bucket: &bucket, Key: &key}
+	sse.applyToHead(input)
+	result, err := NewS3Client(regionCfg).HeadObject(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check object lock: %w", err)
+	}
+
+	lock := &ObjectLock{LegalHold: result.ObjectLockLegalHoldStatus == types.ObjectLockLegalHoldStatusOn}
+	if until := aws.ToTime(result.ObjectLockRetainUntilDate); result.ObjectLockMode != "" && until.After(time.Now()) {
+		lock.Mode = types.ObjectLockMode(result.ObjectLockMode)
+		lock.RetainUntil = until
+	}
+	if lock.Mode == "" && !lock.LegalHold {
+		return nil, nil
+	}
+	return lock, nil
+}
+
+// SetLegalHold sets or clears the legal hold of an object, which must be in a bucket with Object Lock
+func SetLegalHold(ctx context.Context, cfg aws.Config, bucket, key string, on bool) error {
+	regionCfg, err := getRegionSpecificConfig(ctx, cfg, bucket)
+	if err != nil {
+		regionCfg = cfg
+	}
+
+	status := types.ObjectLockLegalHoldStatusOff
+	if on {
+		status = types.ObjectLockLegalHoldStatusOn
+	}
+	_, err = NewS3Client(regionCfg).PutObjectLegalHold(ctx, &s3.PutObjectLegalHoldInput{
+		Bucket:    &bucket,
+		Key:       &key,
+		LegalHold: &types.ObjectLockLegalHold{Status: status},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set legal hold: %w", err)
+	}
+	return nil
+}
+
+// SetLegalHolds sets or clears the legal hold of all objects below a prefix, the repository lock object is skipped
+func SetLegalHolds(ctx context.Context, cfg aws.Config, bucket, prefix string, on, dryRun bool) error {
+	objects, err := ListObjects(ctx, cfg, bucket, prefix)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	verb, done := "clear", "cleared"
+	if on {
+		verb, done = "set", "set"
+	}
+	changed, failed := 0, 0
+	for _, obj := range objects {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		key := aws.ToString(obj.Key)
+		if path.Base(key) == LockObjectName {
+			continue
+		}
+		if dryRun {
+			log.Printf("⚖️ [DRY-RUN] Would %s the legal hold of %s", verb, key)
+			changed++
+			continue
+		}
+		if err := SetLegalHold(ctx, cfg, bucket, key, on); err != nil {
+			failed++
+			slog.Error(fmt.Sprintf("❌ Failed to %s the legal hold of %s: %v", verb, key, err),
+				"event", EventLock, "bucket", bucket, "key", key, "error", err)
+			continue
+		}
+		changed++
+		log.Printf("⚖️ Legal hold %s: %s", done, key)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("❌ failed to %s the legal hold of %d of %d objects in s3://%s/%s", verb, failed, changed+failed, bucket, prefix)
+	}
+	log.Printf("⚖️ Legal hold %s for %d objects in s3://%s/%s", done, changed, bucket, prefix)
+	return nil
+}
+
+// BucketObjectLockEnabled reports whether Object Lock is enabled for a bucket
+func BucketObjectLockEnabled(ctx context.Context, cfg aws.Config, bucket string) (bool, error) {
+	regionCfg, err := getRegionSpecificConfig(ctx, cfg, bucket)
+	if err != nil {
+		regionCfg = cfg
+	}
+
+	result, err := NewS3Client(regionCfg).GetObjectLockConfiguration(ctx, &s3.GetObjectLockConfigurationInput{Bucket: &bucket})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == objectLockNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get Object Lock configuration: %w", err)
+	}
+	return result.ObjectLockConfiguration != nil && result.ObjectLockConfiguration.ObjectLockEnabled == types.ObjectLockEnabledEnabled, nil
+}
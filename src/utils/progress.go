@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Progress reporting constants
+const (
+	ProgressBarWidth       = 30
+	ProgressRedrawInterval = 250 * time.Millisecond
+	ProgressLogInterval    = 10 * time.Second
+)
+
+// Progress reports byte-level progress with throughput and ETA of a long running operation.
+// On a terminal it draws a progress bar, otherwise it logs a plain line periodically.
+type Progress struct {
+	label    string
+	total    int64
+	current  atomic.Int64
+	start    time.Time
+	terminal bool
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// progressReader counts bytes read through it
+type progressReader struct {
+	reader   io.Reader
+	progress *Progress
+}
+
+// progressReadSeeker keeps the Seek method of the wrapped reader available, seeking to a position
+// sets the count to it so that bytes read again after a retry are not counted twice
+type progressReadSeeker struct {
+	progressReader
+	seeker io.Seeker
+}
+
+// terminalLogWriter clears an active progress bar before log output and redraws it afterwards,
+// the logging setup writes all log output through it
+type terminalLogWriter struct {
+	out io.Writer
+}
+
+var (
+	progressEnabled = true
+	progressMutex   sync.Mutex
+	// activeProgress holds the running progress bars in start order, concurrent runs (e.g. scheduled
+	// tasks in daemon mode) share the terminal, only the most recent bar is drawn until it finishes
+	activeProgress    []*Progress
+	stderrIsTerminal            = term.IsTerminal(int(os.Stderr.Fd()))
+	progressBarOutput io.Writer = os.Stderr
+)
+
+// SetProgressEnabled turns progress reporting on or off
+func SetProgressEnabled(enabled bool) {
+	progressEnabled = enabled
+}
+
+// StartProgress starts reporting progress for an operation, returns nil if progress is disabled
+func StartProgress(label string, total int64) *Progress {
+	if !progressEnabled {
+		return nil
+	}
+
+	p := &Progress{
+		label:    label,
+		total:    total,
+		start:    time.Now(),
+		terminal: stderrIsTerminal,
+		done:     make(chan struct{}),
+	}
+
+	if p.terminal {
+		progressMutex.Lock()
+		activeProgress = append(activeProgress, p)
+		progressMutex.Unlock()
+	}
+
+	p.wg.Add(1)
+	go p.run()
+	return p
+}
+
+// Add records n processed bytes
+func (p *Progress) Add(n int64) {
+	if p == nil {
+		return
+	}
+	p.current.Add(n)
+}
+
+// Bytes returns the number of bytes processed so far
+func (p *Progress) Bytes() int64 {
+	if p == nil {
+		return 0
+	}
+	return p.current.Load()
+}
+
+// Reader wraps a reader so that all bytes read are counted
+func (p *Progress) Reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &progressReader{reader: r, progress: p}
+}
+
+// ReadSeeker wraps a seekable reader so that all bytes read are counted, Seek is passed through
+func (p *Progress) ReadSeeker(r io.ReadSeeker) io.ReadSeeker {
+	if p == nil {
+		return r
+	}
+	return &progressReadSeeker{progressReader{reader: r, progress: p}, r}
+}
+
+// Finish stops progress reporting and prints the final state on a terminal
+func (p *Progress) Finish() {
+	if p == nil {
+		return
+	}
+
+	close(p.done)
+	p.wg.Wait()
+
+	if p.terminal {
+		progressMutex.Lock()
+		fmt.Fprintf(progressBarOutput, "\r\033[K%s\n", p.render())
+		activeProgress = slices.DeleteFunc(activeProgress, func(active *Progress) bool { return active == p })
+		progressMutex.Unlock()
+	}
+}
+
+// run redraws the progress bar or logs a plain progress line until Finish is called
+func (p *Progress) run() {
+	defer p.wg.Done()
+
+	interval := ProgressLogInterval
+	if p.terminal {
+		interval = ProgressRedrawInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			if p.terminal {
+				progressMutex.Lock()
+				if drawnProgress() == p {
+					fmt.Fprintf(progressBarOutput, "\r\033[K%s", p.render())
+				}
+				progressMutex.Unlock()
+			} else {
+				slog.Info(p.render(), "event", "progress", "bytes", p.current.Load(), "total", p.total)
+			}
+		}
+	}
+}
+
+// render formats the current progress state
+func (p *Progress) render() string {
+	current := p.current.Load()
+	elapsed := time.Since(p.start)
+	rate := BytesPerSecond(current, elapsed)
+
+	if p.total <= 0 {
+		return fmt.Sprintf("%s %s %s/s", p.label, FormatBytes(current), FormatBytes(rate))
+	}
+
+	percent := min(100, float64(current)*100/float64(p.total))
+	eta := EstimateRemaining(current, p.total, rate)
+
+	if !p.terminal {
+		return fmt.Sprintf("%s %.1f%% %s/%s %s/s ETA %v", p.label, percent,
+			FormatBytes(current), FormatBytes(p.total), FormatBytes(rate), eta)
+	}
+
+	filled := int(percent / 100 * ProgressBarWidth)
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", ProgressBarWidth-filled)
+	return fmt.Sprintf("%s [%s] %5.1f%% %s/%s %s/s ETA %v", p.label, bar, percent,
+		FormatBytes(current), FormatBytes(p.total), FormatBytes(rate), eta)
+}
+
+// BytesPerSecond calculates the throughput for the given bytes and duration
+func BytesPerSecond(bytes int64, elapsed time.Duration) int64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(bytes) / elapsed.Seconds())
+}
+
+// EstimateRemaining calculates the remaining time from progress and throughput
+func EstimateRemaining(current, total, bytesPerSecond int64) time.Duration {
+	if bytesPerSecond <= 0 || current >= total {
+		return 0
+	}
+	seconds := float64(total-current) / float64(bytesPerSecond)
+	return (time.Duration(seconds) * time.Second).Round(time.Second)
+}
+
+// Read reads from the wrapped reader and counts the bytes
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.progress.Add(int64(n))
+	return n, err
+}
+
+// Seek seeks the wrapped reader, seeking from the start (e.g. to retry an upload) sets the count to the new position
+func (r *progressReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	position, err := r.seeker.Seek(offset, whence)
+	if err == nil && whence == io.SeekStart {
+		r.progress.current.Store(position)
+	}
+	return position, err
+}
+
+// drawnProgress returns the progress bar drawn on the terminal, the caller holds progressMutex
+func drawnProgress() *Progress {
+	if len(activeProgress) == 0 {
+		return nil
+	}
+	return activeProgress[len(activeProgress)-1]
+}
+
+// Write writes log output without mixing it into an active progress bar
+func (w *terminalLogWriter) Write(p []byte) (int, error) {
+	progressMutex.Lock()
+	defer progressMutex.Unlock()
+
+	drawn := drawnProgress()
+	if drawn == nil {
+		return w.out.Write(p)
+	}
+
+	fmt.Fprint(w.out, "\r\033[K")
+	n, err := w.out.Write(p)
+	fmt.Fprintf(w.out, "\r\033[K%s", drawn.render())
+	return n, err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"math"
 	"strings"
 	"time"
@@ -36,6 +37,7 @@ func RetryWithBackoff(ctx context.Context, operation func() error, operationName
 
 		attempt++
 		elapsed := time.Since(startTime)
+		DefaultMetrics.AddCounter(MetricRetriesTotal, "Retried operations after network errors", 1)
 
 		// Check if we've exceeded max retry duration
 		if elapsed >= MaxRetryDuration {
@@ -80,7 +82,8 @@ func IsNetworkError(err error) bool {
 // logSuccessAfterRetries logs success message after retry attempts
 func logSuccessAfterRetries(operationName string, attempt int) {
 	if attempt > 0 {
-		log.Printf("✅ %s succeeded after %d attempts", operationName, attempt+1)
+		slog.Info(fmt.Sprintf("✅ %s succeeded after %d attempts", operationName, attempt+1),
+			"event", EventRetry, "operation", operationName, "attempt", attempt+1, "status", "success")
 	}
 }
 
@@ -95,7 +98,8 @@ func calculateNextDelay(currentDelay time.Duration, attempt int) time.Duration {
 
 // logRetryAttempt logs retry attempt with error and next delay
 func logRetryAttempt(operationName string, attempt int, err error, nextDelay time.Duration) {
-	log.Printf("⚠️ %s failed (attempt %d): %v", operationName, attempt, err)
+	slog.Warn(fmt.Sprintf("⚠️ %s failed (attempt %d): %v", operationName, attempt, err),
+		"event", EventRetry, "operation", operationName, "attempt", attempt, "delay", nextDelay.String(), "error", err)
 	log.Printf("🔄 Retrying in %v... (Press Ctrl+C to cancel, will retry for up to 12 hours)", nextDelay)
 }
 
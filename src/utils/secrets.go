@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Secret reference prefixes and defaults
+const (
+	SecretEnvPrefix      = "env:"
+	SecretFilePrefix     = "file:"
+	SecretCmdPrefix      = "cmd:"
+	SecretKeyringPrefix  = "keyring:"
+	SecretPlainPrefix    = "plain:"
+	SecretKeyringService = "aws-s3-backup"
+	SecretCommandTimeout = 1 * time.Minute
+)
+
+// IsSecretReference reports whether a secret refers to its value instead of containing it
+func IsSecretReference(secret string) bool {
+	for _, prefix := range []string{SecretEnvPrefix, SecretFilePrefix, SecretCmdPrefix, SecretKeyringPrefix, SecretPlainPrefix} {
+		if strings.HasPrefix(secret, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIndirectSecret reports whether a secret is a reference that does not contain its value, unlike plain:
+func IsIndirectSecret(secret string) bool {
+	return IsSecretReference(secret) && !strings.HasPrefix(secret, SecretPlainPrefix)
+}
+
+// ValidateSecretReference checks the syntax of a secret reference without resolving it
+func ValidateSecretReference(secret string) error {
+	if !IsSecretReference(secret) {
+		return nil
+	}
+	prefix, value, _ := strings.Cut(secret, ":")
+	if value == "" {
+		return fmt.Errorf("❌ secret reference '%s:' is missing its value", prefix)
+	}
+	return nil
+}
+
+// ResolveSecret returns the value of a secret reference: env:VAR, file:/path, cmd:command,
+// keyring:[service/]account or plain:value. A secret without a known prefix is returned as is.
+func ResolveSecret(ctx context.Context, secret string) (string, error) {
+	if err := ValidateSecretReference(secret); err != nil {
+		return "", err
+	}
+
+	switch {
+	case strings.HasPrefix(secret, SecretEnvPrefix):
+		name := strings.TrimPrefix(secret, SecretEnvPrefix)
+		value, exists := os.LookupEnv(name)
+		if !exists || value == "" {
+			return "", fmt.Errorf("❌ environment variable %s of secret reference is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(secret, SecretFilePrefix):
+		return readSecretFile(strings.TrimPrefix(secret, SecretFilePrefix))
+	case strings.HasPrefix(secret, SecretCmdPrefix):
+		return runSecretCommand(ctx, strings.TrimPrefix(secret, SecretCmdPrefix))
+	case strings.HasPrefix(secret, SecretKeyringPrefix):
+		return readKeyring(strings.TrimPrefix(secret, SecretKeyringPrefix))
+	case strings.HasPrefix(secret, SecretPlainPrefix):
+		return strings.TrimPrefix(secret, SecretPlainPrefix), nil
+	default:
+		return secret, nil
+	}
+}
+
+// readSecretFile reads a secret from the first line of a file, a file readable by others is warned about
+func readSecretFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("❌ failed to read secret file: %w", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		slog.Warn(fmt.Sprintf("⚠️ Secret file %s is accessible by other users (mode %v), use chmod 600", path, info.Mode().Perm()),
+			"event", EventSecret, "file", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("❌ failed to read secret file: %w", err)
+	}
+	secret, _, _ := strings.Cut(string(data), "\n")
+	secret = strings.TrimSuffix(secret, "\r")
+	if secret == "" {
+		return "", fmt.Errorf("❌ secret file %s is empty", path)
+	}
+	return secret, nil
+}
+
+// runSecretCommand returns the first line of the output of a command, e.g. a password manager
+func runSecretCommand(ctx context.Context, command string) (string, error) {
+	cmdCtx, cancel := context.WithTimeout(ctx, SecretCommandTimeout)
+	defer cancel()
+
+	cmd := ShellCommand(cmdCtx, command)
+	var stdout bytes.Buffer
+	stderr := &limitedBuffer{limit: maxStreamStderrSize}
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("❌ secret command failed with exit code %d: %s", exitErr.ExitCode(), bytes.TrimSpace(stderr.Bytes()))
+		}
+		return "", fmt.Errorf("❌ secret command failed: %w", err)
+	}
+
+	secret, _, _ := strings.Cut(stdout.String(), "\n")
+	secret = strings.TrimSuffix(secret, "\r")
+	if secret == "" {
+		return "", fmt.Errorf("❌ secret command returned no output")
+	}
+	return secret, nil
+}
+
+// readKeyring reads a secret from the OS keyring, the service defaults to SecretKeyringService
+func readKeyring(reference string) (string, error) {
+	service, account, found := strings.Cut(reference, "/")
+	if !found {
+		service, account = SecretKeyringService, reference
+	}
+
+	secret, err := keyring.Get(service, account)
+	if err != nil {
+		return "", fmt.Errorf("❌ failed to read secret '%s' of service '%s' from keyring: %w", account, service, err)
+	}
+	return secret, nil
+}
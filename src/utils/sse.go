@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rtitz/aws-s3-backup/config"
+)
+
+// sseCustomerAlgorithm is the only algorithm S3 supports for customer-provided keys
+const sseCustomerAlgorithm = "AES256"
+
+// ServerSideEncryption holds the S3 server-side encryption of objects. A nil value leaves the
+// encryption to the bucket default. The customer key of SSE-C is also needed to read objects.
+type ServerSideEncryption struct {
+	Mode        string
+	KMSKeyID    string
+	BucketKey   bool
+	customerKey string // base64 encoded
+	customerMD5 string // base64 encoded
+}
+
+// NewServerSideEncryption resolves the settings of a task, nil if mode is empty
+func NewServerSideEncryption(ctx context.Context, mode, kmsKeyID string, bucketKey bool, customerKey string) (*ServerSideEncryption, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case config.SSECustomer:
+		return NewCustomerKeyEncryption(ctx, customerKey)
+	default:
+		return &ServerSideEncryption{Mode: mode, KMSKeyID: kmsKeyID, BucketKey: bucketKey}, nil
+	}
+}
+
+// NewCustomerKeyEncryption resolves an SSE-C key reference, the key is 32 bytes encoded in base64
+func NewCustomerKeyEncryption(ctx context.Context, customerKey string) (*ServerSideEncryption, error) {
+	secret, err := ResolveSecret(ctx, customerKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(secret))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("❌ SSE-C key must be 32 bytes encoded in base64 (e.g. openssl rand -base64 32)")
+	}
+	sum := md5.Sum(key)
+	return &ServerSideEncryption{
+		Mode:        config.SSECustomer,
+		customerKey: base64.StdEncoding.EncodeToString(key),
+		customerMD5: base64.StdEncoding.EncodeToString(sum[:]),
+	}, nil
+}
+
+// String describes the encryption for log messages
+func (e *ServerSideEncryption) String() string {
+	switch {
+	case e == nil:
+		return "bucket default"
+	case e.KMSKeyID != "":
+		return e.Mode + " (" + e.KMSKeyID + ")"
+	default:
+		return e.Mode
+	}
+}
+
+// customerKeyHeaders returns algorithm, key and key MD5 of SSE-C, all nil without a customer key
+func (e *ServerSideEncryption) customerKeyHeaders() (*string, *string, *string) {
+	if e == nil || e.customerKey == "" {
+		return nil, nil, nil
+	}
+	return aws.String(sseCustomerAlgorithm), aws.String(e.customerKey), aws.String(e.customerMD5)
+}
+
+// applyToPut sets the encryption headers of an upload
+func (e *ServerSideEncryption) applyToPut(input *s3.PutObjectInput) {
+	if e == nil {
+		return
+	}
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = e.customerKeyHeaders()
+	if e.Mode == config.SSECustomer {
+		return
+	}
+	input.ServerSideEncryption = types.ServerSideEncryption(e.Mode)
+	if e.Mode == config.SSEKMS {
+		if e.KMSKeyID != "" {
+			input.SSEKMSKeyId = aws.String(e.KMSKeyID)
+		}
+		if e.BucketKey {
+			input.BucketKeyEnabled = aws.Bool(true)
+		}
+	}
+}
+
+// applyToGet sets the SSE-C headers needed to download an object
+func (e *ServerSideEncryption) applyToGet(input *s3.GetObjectInput) {
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = e.customerKeyHeaders()
+}
+
+// applyToHead sets the SSE-C headers needed to read the metadata of an object
+func (e *ServerSideEncryption) applyToHead(input *s3.HeadObjectInput) {
+	input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5 = e.customerKeyHeaders()
+}
+
+// GetObjectEncryption returns the server-side encryption an object is stored with, so it can be written
+// again with the same settings. sse provides the SSE-C key and may be nil.
+func GetObjectEncryption(ctx context.Context, cfg aws.Config, bucket, key string, sse *ServerSideEncryption) (*ServerSideEncryption, error) {
+	regionCfg, err := getRegionSpecificConfig(ctx, cfg, bucket)
+	if err != nil {
+		regionCfg = cfg
+	}
+
+	input := &s3.HeadObjectInput{Bucket: &bucket, Key: &key}
+	sse.applyToHead(input)
+	result, err := NewS3Client(regionCfg).HeadObject(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check object encryption: %w", err)
+	}
+
+	switch {
+	case result.SSECustomerAlgorithm != nil:
+		return sse, nil
+	case result.ServerSideEncryption == types.ServerSideEncryptionAwsKms || result.ServerSideEncryption == types.ServerSideEncryptionAwsKmsDsse:
+		return &ServerSideEncryption{
+			Mode:      string(result.ServerSideEncryption),
+			KMSKeyID:  aws.ToString(result.SSEKMSKeyId),
+			BucketKey: aws.ToBool(result.BucketKeyEnabled),
+		}, nil
+	case result.ServerSideEncryption == types.ServerSideEncryptionAes256:
+		return &ServerSideEncryption{Mode: config.SSEAES256}, nil
+	default:
+		return nil, nil
+	}
+}
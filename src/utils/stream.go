@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	config_app "github.com/rtitz/aws-s3-backup/config"
+)
+
+// maxStreamStderrSize limits the command error output kept for error messages
+const maxStreamStderrSize = 4096
+
+// limitedBuffer keeps the first bytes written to it and discards the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+// CompressStreamSource compresses the output of a command or stdin into a gzip file.
+// A command that exits with a non-zero exit code fails the backup, its stderr is part of the error.
+func CompressStreamSource(ctx context.Context, source config_app.StreamSource, outputPath string) (int64, error) {
+	if strings.EqualFold(source.Type, config_app.StreamStdin) {
+		return CompressStream(os.Stdin, outputPath)
+	}
+
+	cmd := ShellCommand(ctx, source.Command)
+	stderr := &limitedBuffer{limit: maxStreamStderrSize}
+	cmd.Stderr = stderr
+	setProcessGroup(cmd)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("❌ failed to start stream command: %w", err)
+	}
+
+	written, copyErr := CompressStream(stdout, outputPath)
+	if copyErr != nil {
+		// Drain the pipe so the command does not block on a full pipe
+		io.Copy(io.Discard, stdout)
+	}
+	waitErr := cmd.Wait()
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(waitErr, &exitErr):
+		return written, fmt.Errorf("❌ stream command failed with exit code %d: %s", exitErr.ExitCode(), bytes.TrimSpace(stderr.Bytes()))
+	case waitErr != nil:
+		return written, fmt.Errorf("❌ stream command failed: %w", waitErr)
+	case copyErr != nil:
+		return written, fmt.Errorf("❌ failed to compress stream: %w", copyErr)
+	}
+	return written, nil
+}
+
+// Write keeps data up to the limit and reports everything as written
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.Len(); remaining > 0 {
+		b.Buffer.Write(p[:min(len(p), remaining)])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,265 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Bandwidth limiting constants
+const (
+	BytesPerKB         = 1024
+	MinThrottleBurst   = 32 * BytesPerKB
+	MinutesPerDay      = 24 * 60
+	TransferWindowSep  = ","
+	TransferWindowSpan = "-"
+)
+
+// TransferWindow is a daily time range (local time) in which transfers are allowed
+type TransferWindow struct {
+	StartMinute int
+	EndMinute   int
+}
+
+// Throttle limits transfer bandwidth and restricts transfers to allowed time windows.
+// A throttle chained under another one also waits for the limiters of its parents.
+type Throttle struct {
+	limiter *rate.Limiter
+	windows []TransferWindow
+	parent  *Throttle
+}
+
+// throttledReader wraps a reader with bandwidth limiting and optional window checks
+type throttledReader struct {
+	ctx         context.Context
+	reader      io.Reader
+	throttle    *Throttle
+	checkWindow bool
+}
+
+// NewThrottle creates a throttle, returns nil if no limit and no window is configured
+func NewThrottle(limitKBps int64, windows []TransferWindow) *Throttle {
+	if limitKBps <= 0 && len(windows) == 0 {
+		return nil
+	}
+
+	return &Throttle{limiter: newLimiter(limitKBps), windows: windows}
+}
+
+// Chain creates a throttle with its own bandwidth limit below t, all throttles chained under t share its
+// bandwidth (e.g. the global limit of concurrent tasks). Windows replace those of t, without windows t's apply.
+func (t *Throttle) Chain(limitKBps int64, windows []TransferWindow) *Throttle {
+	if t == nil {
+		return NewThrottle(limitKBps, windows)
+	}
+	if len(windows) == 0 {
+		windows = t.windows
+	}
+	return &Throttle{limiter: newLimiter(limitKBps), windows: windows, parent: t}
+}
+
+// newLimiter creates the rate limiter for a bandwidth limit, nil if there is no limit
+func newLimiter(limitKBps int64) *rate.Limiter {
+	if limitKBps <= 0 {
+		return nil
+	}
+	bytesPerSecond := limitKBps * BytesPerKB
+	burst := max(MinThrottleBurst, int(bytesPerSecond))
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), burst)
+}
+
+// limiters returns the limiters of the throttle and its parents
+func (t *Throttle) limiters() []*rate.Limiter {
+	var limiters []*rate.Limiter
+	for ; t != nil; t = t.parent {
+		if t.limiter != nil {
+			limiters = append(limiters, t.limiter)
+		}
+	}
+	return limiters
+}
+
+// WrapReader returns a reader that honours the bandwidth limit.
+// Windows are not checked while reading: pausing an open HTTP response would let the
+// connection time out, so callers check the window before starting a transfer.
+func (t *Throttle) WrapReader(ctx context.Context, r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &throttledReader{ctx: ctx, reader: r, throttle: t}
+}
+
+// WrapReadSeeker returns a seekable reader that honours the bandwidth limit and transfer windows.
+// The S3 uploader reads each part completely before sending it, so pausing here only delays the next part.
+func (t *Throttle) WrapReadSeeker(ctx context.Context, r io.ReadSeeker) io.ReadSeeker {
+	if t == nil {
+		return r
+	}
+	return &throttledReadSeeker{throttledReader{ctx: ctx, reader: r, throttle: t, checkWindow: true}, r}
+}
+
+// WaitForWindow blocks until the current time is inside an allowed transfer window
+func (t *Throttle) WaitForWindow(ctx context.Context) error {
+	if t == nil || len(t.windows) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if InTransferWindow(t.windows, now) {
+		return nil
+	}
+
+	wait := durationUntilNextWindow(t.windows, now)
+	log.Printf("⏸️ Outside transfer window, pausing transfers until %s", now.Add(wait).Format("15:04"))
+	if err := waitWithContext(ctx, wait); err != nil {
+		return err
+	}
+	log.Printf("▶️ Transfer window open, resuming transfers")
+	return nil
+}
+
+// Read reads from the wrapped reader within the bandwidth limit
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if r.checkWindow {
+		if err := r.throttle.WaitForWindow(r.ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	limiters := r.throttle.limiters()
+	if len(limiters) == 0 {
+		return r.reader.Read(p)
+	}
+
+	for _, limiter := range limiters {
+		if len(p) > limiter.Burst() {
+			p = p[:limiter.Burst()]
+		}
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		for _, limiter := range limiters {
+			if waitErr := limiter.WaitN(r.ctx, n); waitErr != nil {
+				return n, waitErr
+			}
+		}
+	}
+	return n, err
+}
+
+// throttledReadSeeker keeps the Seek method of the wrapped reader available
+type throttledReadSeeker struct {
+	throttledReader
+	seeker io.Seeker
+}
+
+// Seek seeks the wrapped reader
+func (r *throttledReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.seeker.Seek(offset, whence)
+}
+
+// ParseTransferWindows parses a comma separated list of daily windows like "22:00-06:00,12:00-13:00"
+func ParseTransferWindows(value string) ([]TransferWindow, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	var windows []TransferWindow
+	for _, spec := range strings.Split(value, TransferWindowSep) {
+		window, err := parseTransferWindow(strings.TrimSpace(spec))
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// parseTransferWindow parses a single "HH:MM-HH:MM" window
+func parseTransferWindow(spec string) (TransferWindow, error) {
+	start, end, found := strings.Cut(spec, TransferWindowSpan)
+	if !found {
+		return TransferWindow{}, fmt.Errorf("❌ invalid transfer window '%s', expected HH:MM-HH:MM", spec)
+	}
+
+	startMinute, err := parseClockTime(start)
+	if err != nil {
+		return TransferWindow{}, fmt.Errorf("❌ invalid transfer window '%s': %w", spec, err)
+	}
+	endMinute, err := parseClockTime(end)
+	if err != nil {
+		return TransferWindow{}, fmt.Errorf("❌ invalid transfer window '%s': %w", spec, err)
+	}
+	if startMinute == endMinute {
+		return TransferWindow{}, fmt.Errorf("❌ invalid transfer window '%s': start and end are equal", spec)
+	}
+
+	return TransferWindow{StartMinute: startMinute, EndMinute: endMinute}, nil
+}
+
+// parseClockTime converts "HH:MM" into minutes since midnight
+func parseClockTime(value string) (int, error) {
+	hours, minutes, found := strings.Cut(strings.TrimSpace(value), ":")
+	if !found {
+		return 0, fmt.Errorf("invalid time '%s'", value)
+	}
+
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid hour in '%s'", value)
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid minute in '%s'", value)
+	}
+
+	return h*60 + m, nil
+}
+
+// InTransferWindow checks if the given time is inside one of the windows
+func InTransferWindow(windows []TransferWindow, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	for _, window := range windows {
+		if window.contains(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains checks if a minute of the day is inside the window (handles windows across midnight)
+func (w TransferWindow) contains(minute int) bool {
+	if w.StartMinute < w.EndMinute {
+		return minute >= w.StartMinute && minute < w.EndMinute
+	}
+	return minute >= w.StartMinute || minute < w.EndMinute
+}
+
+// durationUntilNextWindow computes the wait time until the next window opens
+func durationUntilNextWindow(windows []TransferWindow, now time.Time) time.Duration {
+	minute := now.Hour()*60 + now.Minute()
+	shortest := MinutesPerDay
+
+	for _, window := range windows {
+		diff := (window.StartMinute - minute + MinutesPerDay) % MinutesPerDay
+		if diff == 0 {
+			diff = MinutesPerDay
+		}
+		shortest = min(shortest, diff)
+	}
+
+	wait := time.Duration(shortest)*time.Minute - time.Duration(now.Second())*time.Second
+	return max(wait, time.Second)
+}